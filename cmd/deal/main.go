@@ -0,0 +1,211 @@
+// Command deal plays the contracts without generated code, run `deal serve -h` to see
+// the options of the stub server.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+	"github.com/faunists/deal-go/stub"
+)
+
+// unmatchedBehaviors maps the values of the `unmatched` flag to the runtime behaviors.
+var unmatchedBehaviors = map[string]runtime.UnmatchedBehavior{
+	"strict":     runtime.UnmatchedStrict,
+	"permissive": runtime.UnmatchedPermissive,
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "serve" {
+		fmt.Fprintln(os.Stderr, "usage: deal serve [options]")
+		os.Exit(2)
+	}
+
+	if err := serve(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var contractFiles, contractDirs, protoPaths stringList
+	flags.Var(&contractFiles, "contract-file", "Path or glob pattern of your contract files")
+	flags.Var(&contractDirs, "contract-dir", "Directory containing your contract files")
+	flags.Var(&protoPaths, "proto-path", "Directory where the proto files are looked up")
+	descriptorSet := flags.String(
+		"descriptor-set", "", "FileDescriptorSet of the protos, built with every import",
+	)
+	address := flags.String("address", ":50051", "TCP address the stub server listens on")
+	socket := flags.String("socket", "", "Unix socket the stub server listens on, over the address")
+	unmatched := flags.String(
+		"unmatched", "strict", "What happens when a request matches no case: strict or permissive",
+	)
+	discovery := flags.Bool(
+		"discovery", false, "Register the server reflection and health services along the stubs",
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *descriptorSet == "" {
+		return errors.New("the descriptor set is required, use -descriptor-set")
+	}
+	if len(protoPaths) == 0 {
+		protoPaths = stringList{"."}
+	}
+
+	unmatchedBehavior, valid := unmatchedBehaviors[*unmatched]
+	if !valid {
+		return fmt.Errorf("invalid unmatched option '%s', use strict or permissive", *unmatched)
+	}
+
+	stubServer, loadErr := newServer(
+		*descriptorSet, contractFiles, contractDirs, protoPaths, unmatchedBehavior, *discovery,
+	)
+	// Once discovery is enabled the server runs anyway, reporting it's not serving
+	if loadErr != nil && !*discovery {
+		return loadErr
+	}
+
+	listener, err := listen(*address, *socket)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer()
+	services := registerServices(grpcServer, stubServer, loadErr, *discovery)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("serving [%s] on %s", strings.Join(services, ", "), listener.Addr())
+
+	return grpcServer.Serve(listener)
+}
+
+// listen opens the Unix socket when it's given, otherwise the TCP address.
+func listen(address string, socket string) (net.Listener, error) {
+	if socket != "" {
+		return net.Listen("unix", socket)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// registerServices registers the stub services, when the contracts were loaded, and the
+// discovery services when they're enabled. It returns the name of the stub services.
+func registerServices(
+	grpcServer *grpc.Server,
+	stubServer *stub.Server,
+	loadErr error,
+	discovery bool,
+) []string {
+	var services []string
+	if loadErr == nil {
+		stubServer.Register(grpcServer)
+		services = stubServer.Services()
+	} else {
+		log.Printf("failed to load the contracts: %v", loadErr)
+	}
+
+	if discovery {
+		runtime.RegisterDiscovery(grpcServer, loadErr)
+	}
+
+	return services
+}
+
+// newServer reads the descriptors and merges the contracts, the contracts written through
+// the proto options are merged as well. With discovery the files are registered so the
+// server reflection can describe them.
+func newServer(
+	descriptorSet string,
+	contractFiles []string,
+	contractDirs []string,
+	protoPaths []string,
+	unmatched runtime.UnmatchedBehavior,
+	discovery bool,
+) (*stub.Server, error) {
+	files, err := processors.ReadDescriptorSet(descriptorSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if discovery {
+		if err = stub.RegisterFiles(files); err != nil {
+			return nil, err
+		}
+	}
+
+	contract, err := mergeContracts(files, contractFiles, contractDirs, protoPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := processors.NewMessageRegistry(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return stub.NewServer(files, registry, contract, unmatched)
+}
+
+// mergeContracts merges the contract files with the contracts written through the proto
+// options, like the plugin does.
+func mergeContracts(
+	files []*protogen.File,
+	contractFiles []string,
+	contractDirs []string,
+	protoPaths []string,
+) (entities.Contract, error) {
+	contracts := processors.NewContractMerger(files)
+	if len(contractFiles) > 0 || len(contractDirs) > 0 {
+		contractFilePaths, err := processors.FindContractFiles(contractFiles, contractDirs)
+		if err != nil {
+			return entities.Contract{}, err
+		}
+
+		if err = contracts.MergeFiles(contractFilePaths); err != nil {
+			return entities.Contract{}, err
+		}
+	}
+
+	for _, file := range files {
+		if err := contracts.MergeOptions(file, protoPaths); err != nil {
+			return entities.Contract{}, err
+		}
+	}
+
+	return contracts.Contract(), nil
+}
+
+// stringList is a flag that can be given more than once, every value is kept in order.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
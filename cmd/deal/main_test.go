@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+const testContract = `
+services:
+  grpc.testing.TestService:
+    EmptyCall:
+      successCases:
+        - description: Should answer
+          request: {}
+          response: {}
+`
+
+// writeTestFiles writes the descriptor set of grpc.testing.TestService and its contract.
+func writeTestFiles(t *testing.T) (string, string) {
+	t.Helper()
+
+	descriptorSet := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(testpb.File_grpc_testing_empty_proto),
+			protodesc.ToFileDescriptorProto(testpb.File_grpc_testing_messages_proto),
+			protodesc.ToFileDescriptorProto(testpb.File_grpc_testing_test_proto),
+		},
+	}
+	data, err := proto.Marshal(descriptorSet)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	descriptorSetPath := filepath.Join(dir, "descriptors.pb")
+	if err = ioutil.WriteFile(descriptorSetPath, data, 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	contractPath := filepath.Join(dir, "contract.yml")
+	if err = ioutil.WriteFile(contractPath, []byte(testContract), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return descriptorSetPath, contractPath
+}
+
+// listServices asks the server reflection for the name of every service, through the
+// given version of the reflection service.
+func listServices(t *testing.T, conn grpc.ClientConnInterface, reflectionService string) []string {
+	t.Helper()
+
+	stream, err := conn.NewStream(
+		context.Background(),
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		"/"+reflectionService+"/ServerReflectionInfo",
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every version of the reflection has the same messages on the wire
+	err = stream.SendMsg(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response := &reflectionpb.ServerReflectionResponse{}
+	if err = stream.RecvMsg(response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var services []string
+	for _, service := range response.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	sort.Strings(services)
+
+	return services
+}
+
+func TestServe_Discovery(t *testing.T) {
+	t.Parallel()
+
+	descriptorSet, contract := writeTestFiles(t)
+	stubServer, err := newServer(
+		descriptorSet, []string{contract}, nil, []string{"."}, runtime.UnmatchedDefault, true,
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	conn := runtime.StartStub(t, func(grpcServer *grpc.Server) {
+		registerServices(grpcServer, stubServer, nil, true)
+	})
+
+	expectedServices := []string{
+		"grpc.health.v1.Health",
+		"grpc.reflection.v1.ServerReflection",
+		"grpc.reflection.v1alpha.ServerReflection",
+		"grpc.testing.TestService",
+	}
+	for _, reflectionService := range []string{
+		"grpc.reflection.v1.ServerReflection",
+		"grpc.reflection.v1alpha.ServerReflection",
+	} {
+		services := listServices(t, conn, reflectionService)
+		if strings.Join(services, ",") != strings.Join(expectedServices, ",") {
+			t.Errorf(
+				"Wrong services through %s, given: %v expected: %v",
+				reflectionService, services, expectedServices,
+			)
+		}
+	}
+
+	_, err = testpb.NewTestServiceClient(conn).EmptyCall(context.Background(), &testpb.Empty{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -4,8 +4,9 @@ import "fmt"
 
 // Contract represents the root of everything that will be generated
 type Contract struct {
-	Name     string             `json:"name" yaml:"name"`
-	Services map[string]Service `json:"services" yaml:"services"`
+	Name      string             `json:"name" yaml:"name"`
+	Services  map[string]Service `json:"services" yaml:"services"`
+	Scenarios []Scenario         `json:"scenarios" yaml:"scenarios"`
 }
 
 // Service is a named type of a map[string]Method
@@ -20,25 +21,142 @@ type Method struct {
 	FailureCases []FailureCase `json:"failureCases" yaml:"failureCases"`
 }
 
-// SuccessCase handles the information about the request and response of a method
+// SuccessCase handles the information about the request and response of a method,
+// server-streaming methods use Responses to describe the ordered stream of messages,
+// client-streaming methods use Requests for the messages sent by the client and
+// bidirectional streaming methods use Steps to describe the whole conversation.
+// RequestMetadata must be sent by the client for the case to match, while ResponseHeaders
+// and ResponseTrailers are the metadata sent back by the server
 type SuccessCase struct {
-	Description string      `json:"description" yaml:"description"`
-	Request     interface{} `json:"request" yaml:"request"`
-	Response    interface{} `json:"response" yaml:"response"`
+	Description      string                  `json:"description" yaml:"description"`
+	Request          interface{}             `json:"request" yaml:"request"`
+	Requests         []interface{}           `json:"requests" yaml:"requests"`
+	RequestMatchers  map[string]FieldMatcher `json:"requestMatchers" yaml:"requestMatchers"`
+	RequestMetadata  map[string]string       `json:"requestMetadata" yaml:"requestMetadata"`
+	Response         interface{}             `json:"response" yaml:"response"`
+	Responses        []interface{}           `json:"responses" yaml:"responses"`
+	ResponseHeaders  map[string]string       `json:"responseHeaders" yaml:"responseHeaders"`
+	ResponseTrailers map[string]string       `json:"responseTrailers" yaml:"responseTrailers"`
+	Steps            []Step                  `json:"steps" yaml:"steps"`
+	Given            *ProviderState          `json:"given" yaml:"given"`
+	Source           Source                  `json:"-" yaml:"-"`
 }
 
 // FailureCase handles the information about the request and the error that should be returned
-// for a given request
+// for a given request, server-streaming methods may send Responses before the error and
+// bidirectional streaming methods fail right after the last step.
+// The metadata fields behave exactly like the ones from SuccessCase
 type FailureCase struct {
-	Description string      `json:"description" yaml:"description"`
-	Request     interface{} `json:"request" yaml:"request"`
-	Error       GRPCError   `json:"error" yaml:"error"`
+	Description      string                  `json:"description" yaml:"description"`
+	Request          interface{}             `json:"request" yaml:"request"`
+	Requests         []interface{}           `json:"requests" yaml:"requests"`
+	RequestMatchers  map[string]FieldMatcher `json:"requestMatchers" yaml:"requestMatchers"`
+	RequestMetadata  map[string]string       `json:"requestMetadata" yaml:"requestMetadata"`
+	Responses        []interface{}           `json:"responses" yaml:"responses"`
+	ResponseHeaders  map[string]string       `json:"responseHeaders" yaml:"responseHeaders"`
+	ResponseTrailers map[string]string       `json:"responseTrailers" yaml:"responseTrailers"`
+	Steps            []Step                  `json:"steps" yaml:"steps"`
+	Error            GRPCError               `json:"error" yaml:"error"`
+	Given            *ProviderState          `json:"given" yaml:"given"`
+	Source           Source                  `json:"-" yaml:"-"`
 }
 
-// GRPCError handles the information about the error code and the string message of a GRPC error
+// ProviderState handles the state the provider must be in before a case runs, the generated
+// contract test calls the handler registered for the Name passing the Params to it
+type ProviderState struct {
+	Name   string                 `json:"name" yaml:"name"`
+	Params map[string]interface{} `json:"params" yaml:"params"`
+}
+
+// Source tells where a case was written, it isn't part of the contract itself
+// but it's used to point out the case when something goes wrong
+type Source struct {
+	File   string
+	Line   int
+	Column int
+
+	// Index is the position of the case in its list inside the file, the cases of
+	// every file are merged into the same list, so their positions change
+	Index int
+}
+
+func (s Source) String() string {
+	if s.Line == 0 {
+		return s.File
+	}
+
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Column)
+}
+
+// CasePath returns the path of the case inside the file it was written, e.g.
+// `successCases[1]`. Without a source the given index, of the merged list, is used.
+func (s Source) CasePath(list string, index int) string {
+	if s.File != "" {
+		index = s.Index
+	}
+
+	return fmt.Sprintf("%s[%d]", list, index)
+}
+
+// Scenario handles a named state machine of a service, every scenario starts in the
+// `Started` state and its Steps move it from one state to another
+type Scenario struct {
+	Name    string         `json:"name" yaml:"name"`
+	Service string         `json:"service" yaml:"service"`
+	Steps   []ScenarioStep `json:"steps" yaml:"steps"`
+}
+
+// ScenarioStep handles a call to a unary method that only matches while the scenario is
+// in State, once it matches the scenario moves to NextState. An empty State matches any
+// state and an empty NextState keeps the current one. The Error is returned when it's
+// set, otherwise the Response is returned
+type ScenarioStep struct {
+	Description     string                  `json:"description" yaml:"description"`
+	Method          string                  `json:"method" yaml:"method"`
+	State           string                  `json:"state" yaml:"state"`
+	NextState       string                  `json:"nextState" yaml:"nextState"`
+	Request         interface{}             `json:"request" yaml:"request"`
+	RequestMatchers map[string]FieldMatcher `json:"requestMatchers" yaml:"requestMatchers"`
+	RequestMetadata map[string]string       `json:"requestMetadata" yaml:"requestMetadata"`
+	Response        interface{}             `json:"response" yaml:"response"`
+	Error           *GRPCError              `json:"error" yaml:"error"`
+	Source          Source                  `json:"-" yaml:"-"`
+}
+
+// Step handles a single interaction of a bidirectional streaming case,
+// the client sends the Request and the server answers with the Responses
+type Step struct {
+	Request         interface{}             `json:"request" yaml:"request"`
+	RequestMatchers map[string]FieldMatcher `json:"requestMatchers" yaml:"requestMatchers"`
+	Responses       []interface{}           `json:"responses" yaml:"responses"`
+}
+
+// FieldMatcher relaxes how a request field is matched by the generated client and stub
+// server, the key used in `requestMatchers` is the field path, e.g. `user.id`.
+// Only one of the rules must be set.
+type FieldMatcher struct {
+	Present  bool          `json:"present" yaml:"present"`
+	Any      bool          `json:"any" yaml:"any"`
+	Regex    string        `json:"regex" yaml:"regex"`
+	Prefix   string        `json:"prefix" yaml:"prefix"`
+	Range    *NumericRange `json:"range" yaml:"range"`
+	OneOf    []interface{} `json:"oneOf" yaml:"oneOf"`
+	SubsetOf []interface{} `json:"subsetOf" yaml:"subsetOf"`
+}
+
+// NumericRange handles the inclusive boundaries of a range matcher,
+// a missing boundary means the range is open on that side.
+type NumericRange struct {
+	Min *float64 `json:"min" yaml:"min"`
+	Max *float64 `json:"max" yaml:"max"`
+}
+
+// GRPCError handles the information about the error code and the string message of a GRPC error,
+// Details are the messages sent along with the status, written as a google.protobuf.Any
 type GRPCError struct {
-	ErrorCode string `json:"errorCode" yaml:"errorCode"`
-	Message   string `json:"message" yaml:"message"`
+	ErrorCode string        `json:"errorCode" yaml:"errorCode"`
+	Message   string        `json:"message" yaml:"message"`
+	Details   []interface{} `json:"details" yaml:"details"`
 }
 
 func (e GRPCError) String() string {
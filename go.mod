@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	google.golang.org/genproto v0.0.0-20210708141623-e76da96a951f
+	google.golang.org/grpc v1.43.0
 	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -3,9 +3,13 @@ package processors
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	"google.golang.org/protobuf/compiler/protogen"
 	"gopkg.in/yaml.v3"
 
 	"github.com/faunists/deal-go/entities"
@@ -13,6 +17,16 @@ import (
 
 // ReadContractFile reads a JSON File and try to parse it to a entities.Contract object
 func ReadContractFile(filePath string) (entities.Contract, error) {
+	rawContract := entities.Contract{}
+	if err := readContractData(filePath, &rawContract); err != nil {
+		return entities.Contract{}, err
+	}
+
+	return rawContract, nil
+}
+
+// readContractData parses a JSON or YAML file into `out`, based on the file extension.
+func readContractData(filePath string, out interface{}) error {
 	splitFilePath := strings.Split(filePath, ".")
 	extension := splitFilePath[len(splitFilePath)-1]
 
@@ -23,20 +37,370 @@ func ReadContractFile(filePath string) (entities.Contract, error) {
 	case "yaml", "yml":
 		unmarshaler = yaml.Unmarshal
 	default:
-		return entities.Contract{}, errors.New(
-			"invalid contract extension, supported formats are json and yaml",
-		)
+		return errors.New("invalid contract extension, supported formats are json and yaml")
 	}
 
 	fileData, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return entities.Contract{}, err
+		return err
 	}
 
-	rawContract := entities.Contract{}
-	if err = unmarshaler(fileData, &rawContract); err != nil {
+	if err = unmarshaler(fileData, out); err != nil {
+		return err
+	}
+
+	setSources(out, fileData, filePath)
+
+	return nil
+}
+
+// FindContractFiles returns the contract files matching the given glob patterns, besides
+// every JSON and YAML file inside the given directories (subdirectories are not included).
+// The files are returned in the given order, each pattern and directory sorted by name,
+// a file found more than once is only returned the first time.
+func FindContractFiles(patterns []string, dirs []string) ([]string, error) {
+	filePaths := make([]string, 0, len(patterns))
+	found := make(map[string]bool)
+	appendFiles := func(paths []string) {
+		for _, path := range paths {
+			cleanPath := filepath.Clean(path)
+			if !found[cleanPath] {
+				found[cleanPath] = true
+				filePaths = append(filePaths, path)
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid contract file pattern '%s': %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no contract file matches '%s'", pattern)
+		}
+
+		appendFiles(matches)
+	}
+
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the contract dir: %w", err)
+		}
+
+		dirFilePaths := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".json", ".yaml", ".yml":
+				if !entry.IsDir() {
+					dirFilePaths = append(dirFilePaths, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+		if len(dirFilePaths) == 0 {
+			return nil, fmt.Errorf("no contract file found in '%s'", dir)
+		}
+
+		sort.Strings(dirFilePaths)
+		appendFiles(dirFilePaths)
+	}
+
+	return filePaths, nil
+}
+
+// ReadContractFiles reads every contract file and merges them into a single contract,
+// see ContractMerger for how the cases are merged.
+func ReadContractFiles(filePaths []string) (entities.Contract, error) {
+	merger := NewContractMerger(nil)
+	if err := merger.MergeFiles(filePaths); err != nil {
 		return entities.Contract{}, err
 	}
 
-	return rawContract, nil
+	return merger.Contract(), nil
+}
+
+// ContractMerger merges contracts from many sources into a single one. The cases of a
+// method are merged in the given order, cases repeated across the sources are kept only
+// once and cases matching the same request with different outcomes are reported as conflicts.
+// The services are keyed by their fully-qualified proto names, see NewContractMerger.
+type ContractMerger struct {
+	contract   entities.Contract
+	origins    map[string][]caseOrigin
+	scenarios  map[string]scenarioOrigin
+	methods    map[string][]string
+	shortNames map[string][]string
+}
+
+// NewContractMerger returns a merger without any contract. The services of the files are
+// used to resolve the service keys of the contracts, a key can be the fully-qualified name
+// of the service (`acme.users.v1.UserService`) or its short name when it's unique.
+// Keys that don't match any service are kept as they are.
+func NewContractMerger(files []*protogen.File) *ContractMerger {
+	merger := &ContractMerger{
+		contract:   entities.Contract{Services: make(map[string]entities.Service)},
+		origins:    make(map[string][]caseOrigin),
+		scenarios:  make(map[string]scenarioOrigin),
+		methods:    make(map[string][]string),
+		shortNames: make(map[string][]string),
+	}
+
+	for _, file := range files {
+		for _, service := range file.Services {
+			fullName := string(service.Desc.FullName())
+
+			methods := make([]string, 0, len(service.Methods))
+			for _, method := range service.Methods {
+				methods = append(methods, method.GoName)
+			}
+			merger.methods[fullName] = methods
+
+			merger.shortNames[service.GoName] = append(merger.shortNames[service.GoName], fullName)
+			if shortName := string(service.Desc.Name()); shortName != service.GoName {
+				merger.shortNames[shortName] = append(merger.shortNames[shortName], fullName)
+			}
+		}
+	}
+
+	return merger
+}
+
+// serviceKey returns the fully-qualified name of the service referenced by the key.
+func (m *ContractMerger) serviceKey(key string) (string, error) {
+	if _, exists := m.methods[key]; exists {
+		return key, nil
+	}
+
+	fullNames := m.shortNames[key]
+	switch len(fullNames) {
+	case 0:
+		return key, nil
+	case 1:
+		return fullNames[0], nil
+	default:
+		sort.Strings(fullNames)
+		return "", fmt.Errorf(
+			"service '%s' is ambiguous, use one of its fully-qualified names: %s",
+			key, strings.Join(fullNames, ", "),
+		)
+	}
+}
+
+// Merge adds the contract to the merged one, source tells where the contract came from
+// and it's used to report conflicts.
+func (m *ContractMerger) Merge(source string, contract entities.Contract) error {
+	if m.contract.Name == "" {
+		m.contract.Name = contract.Name
+	}
+
+	for key, service := range contract.Services {
+		serviceName, err := m.serviceKey(key)
+		if err != nil {
+			return fmt.Errorf("invalid contract '%s': %w", source, err)
+		}
+
+		mergedService, exists := m.contract.Services[serviceName]
+		if !exists {
+			mergedService = make(entities.Service)
+			m.contract.Services[serviceName] = mergedService
+		}
+
+		for methodName, method := range service {
+			fullName := fmt.Sprintf("%s.%s", serviceName, methodName)
+
+			mergedMethod, err := mergeMethod(
+				mergedService[methodName], method, fullName, source, m.origins,
+			)
+			if err != nil {
+				return err
+			}
+
+			mergedService[methodName] = mergedMethod
+		}
+	}
+
+	return m.mergeScenarios(source, contract.Scenarios)
+}
+
+// MergeFiles reads every contract file and merges them in the given order.
+func (m *ContractMerger) MergeFiles(filePaths []string) error {
+	for _, filePath := range filePaths {
+		contract, err := ReadContractFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", filePath, err)
+		}
+
+		if err = m.Merge(filePath, contract); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Contract returns the contract merged so far.
+func (m *ContractMerger) Contract() entities.Contract {
+	return m.contract
+}
+
+// caseOrigin tells where a merged case came from, so conflicts can be reported.
+// The criteria and the outcome are kept as JSON, so JSON and YAML files can be compared.
+type caseOrigin struct {
+	description string
+	filePath    string
+	location    string
+	criteria    string
+	outcome     string
+}
+
+func newCaseOrigin(
+	description string,
+	filePath string,
+	source entities.Source,
+	criteria interface{},
+	outcome interface{},
+) (caseOrigin, error) {
+	marshaledCriteria, err := json.Marshal(criteria)
+	if err != nil {
+		return caseOrigin{}, fmt.Errorf("invalid case '%s': %w", description, err)
+	}
+
+	marshaledOutcome, err := json.Marshal(outcome)
+	if err != nil {
+		return caseOrigin{}, fmt.Errorf("invalid case '%s': %w", description, err)
+	}
+
+	// The case position is preferred, but contracts built in memory don't have one
+	location := source.String()
+	if location == "" {
+		location = filePath
+	}
+
+	return caseOrigin{
+		description: description,
+		filePath:    filePath,
+		location:    location,
+		criteria:    string(marshaledCriteria),
+		outcome:     string(marshaledOutcome),
+	}, nil
+}
+
+func mergeMethod(
+	merged entities.Method,
+	method entities.Method,
+	fullName string,
+	filePath string,
+	origins map[string][]caseOrigin,
+) (entities.Method, error) {
+	for _, successCase := range method.SuccessCases {
+		outcome := successCase
+		outcome.Description = ""
+
+		origin, err := newCaseOrigin(
+			successCase.Description,
+			filePath,
+			successCase.Source,
+			caseCriteria(
+				successCase.Request,
+				successCase.Requests,
+				successCase.RequestMatchers,
+				successCase.RequestMetadata,
+				successCase.Steps,
+			),
+			outcome,
+		)
+		if err != nil {
+			return entities.Method{}, err
+		}
+
+		duplicated, err := registerCase(origins, fullName, origin)
+		if err != nil {
+			return entities.Method{}, err
+		}
+		if !duplicated {
+			merged.SuccessCases = append(merged.SuccessCases, successCase)
+		}
+	}
+
+	for _, failureCase := range method.FailureCases {
+		outcome := failureCase
+		outcome.Description = ""
+
+		origin, err := newCaseOrigin(
+			failureCase.Description,
+			filePath,
+			failureCase.Source,
+			caseCriteria(
+				failureCase.Request,
+				failureCase.Requests,
+				failureCase.RequestMatchers,
+				failureCase.RequestMetadata,
+				failureCase.Steps,
+			),
+			outcome,
+		)
+		if err != nil {
+			return entities.Method{}, err
+		}
+
+		duplicated, err := registerCase(origins, fullName, origin)
+		if err != nil {
+			return entities.Method{}, err
+		}
+		if !duplicated {
+			merged.FailureCases = append(merged.FailureCases, failureCase)
+		}
+	}
+
+	return merged, nil
+}
+
+// registerCase keeps track of the cases of the method, it returns whether the case was
+// already registered or an error when it conflicts with a case from another file.
+func registerCase(
+	origins map[string][]caseOrigin,
+	fullName string,
+	origin caseOrigin,
+) (bool, error) {
+	for _, registered := range origins[fullName] {
+		// Cases from the same file are kept as they are, as it was before merging files
+		if registered.filePath == origin.filePath || registered.criteria != origin.criteria {
+			continue
+		}
+
+		if registered.outcome == origin.outcome {
+			return true, nil
+		}
+
+		return false, fmt.Errorf(
+			"conflicting cases for %s: '%s' from '%s' and '%s' from '%s' match the same request",
+			fullName,
+			registered.description, registered.location,
+			origin.description, origin.location,
+		)
+	}
+
+	origins[fullName] = append(origins[fullName], origin)
+
+	return false, nil
+}
+
+// caseCriteria returns everything used to match a case, responses of the steps are
+// removed since they're part of the outcome.
+func caseCriteria(
+	request interface{},
+	requests []interface{},
+	matchers map[string]entities.FieldMatcher,
+	metadata map[string]string,
+	steps []entities.Step,
+) interface{} {
+	stepsCriteria := make([]entities.Step, 0, len(steps))
+	for _, step := range steps {
+		stepsCriteria = append(
+			stepsCriteria,
+			entities.Step{Request: step.Request, RequestMatchers: step.RequestMatchers},
+		)
+	}
+
+	return []interface{}{request, requests, matchers, metadata, stepsCriteria}
 }
@@ -0,0 +1,286 @@
+package processors_test
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestFindContractFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		patterns          []string
+		dirs              []string
+		expectedFilePaths []string
+		expectedError     string
+	}{
+		{
+			name:     "should find the files matching the patterns",
+			patterns: []string{"testdata/contracts/*.yml", "testdata/contracts/*.json"},
+			expectedFilePaths: []string{
+				"testdata/contracts/billing.yml",
+				"testdata/contracts/checkout.json",
+			},
+		},
+		{
+			name: "should find every contract file inside the dirs",
+			dirs: []string{"testdata/contracts", "testdata/contracts/conflicting"},
+			expectedFilePaths: []string{
+				"testdata/contracts/billing.yml",
+				"testdata/contracts/checkout.json",
+				"testdata/contracts/conflicting/shipping.yml",
+			},
+		},
+		{
+			name:     "should return a file found more than once only the first time",
+			patterns: []string{"testdata/contracts/checkout.json"},
+			dirs:     []string{"testdata/contracts"},
+			expectedFilePaths: []string{
+				"testdata/contracts/checkout.json",
+				"testdata/contracts/billing.yml",
+			},
+		},
+		{
+			name:          "should return an error when no file matches the pattern",
+			patterns:      []string{"testdata/contracts/*.yaml"},
+			expectedError: "no contract file matches 'testdata/contracts/*.yaml'",
+		},
+		{
+			name:          "should return an error when the dir has no contract file",
+			dirs:          []string{"testdata/contracts/empty"},
+			expectedError: "no contract file found in 'testdata/contracts/empty'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePaths, err := processors.FindContractFiles(test.patterns, test.dirs)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(filePaths, test.expectedFilePaths) {
+				t.Errorf("Wrong files, given: %v expected %v", filePaths, test.expectedFilePaths)
+			}
+		})
+	}
+}
+
+func TestReadContractFiles(t *testing.T) {
+	t.Parallel()
+
+	contract, err := processors.ReadContractFiles([]string{
+		"testdata/contracts/billing.yml",
+		"testdata/contracts/checkout.json",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if contract.Name != "Billing consumer" {
+		t.Errorf("Wrong name, given: %s expected: Billing consumer", contract.Name)
+	}
+
+	method := contract.Services["MyService"]["MyMethod"]
+
+	successDescriptions := make([]string, 0, len(method.SuccessCases))
+	for _, successCase := range method.SuccessCases {
+		successDescriptions = append(successDescriptions, successCase.Description)
+	}
+
+	// The shared case is repeated in both files, so it's kept only once
+	expectedDescriptions := []string{"Should return the invoice", "Should return the shared value"}
+	if !reflect.DeepEqual(successDescriptions, expectedDescriptions) {
+		t.Errorf(
+			"Wrong success cases, given: %v expected: %v",
+			successDescriptions, expectedDescriptions,
+		)
+	}
+
+	if len(method.FailureCases) != 1 {
+		t.Errorf("Wrong number of failure cases, given: %d expected: 1", len(method.FailureCases))
+	}
+
+	if _, exists := contract.Services["MyService"]["AnotherMethod"]; !exists {
+		t.Errorf("The methods of every file should be merged")
+	}
+}
+
+func TestReadContractFiles_Conflict(t *testing.T) {
+	t.Parallel()
+
+	_, err := processors.ReadContractFiles([]string{
+		"testdata/contracts/billing.yml",
+		"testdata/contracts/conflicting/shipping.yml",
+	})
+
+	expectedError := "conflicting cases for MyService.MyMethod: " +
+		"'Should return the shared value' from 'testdata/contracts/billing.yml:11:11' and " +
+		"'Should not find the shared value' from " +
+		"'testdata/contracts/conflicting/shipping.yml:6:11' match the same request"
+	if err == nil || err.Error() != expectedError {
+		t.Fatalf("Wrong error, given: %v expected: %s", err, expectedError)
+	}
+}
+
+func TestContractMerger_ServiceKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		packages        []string
+		key             string
+		expectedService string
+		expectedError   string
+	}{
+		{
+			name:            "should accept the fully-qualified name of the service",
+			packages:        []string{"acme.users.v1", "acme.users.v2"},
+			key:             "acme.users.v2.MyService",
+			expectedService: "acme.users.v2.MyService",
+		},
+		{
+			name:            "should resolve a unique short name",
+			packages:        []string{"acme.users.v1"},
+			key:             "MyService",
+			expectedService: "acme.users.v1.MyService",
+		},
+		{
+			name:            "should keep a key that doesn't match any service",
+			packages:        []string{"acme.users.v1"},
+			key:             "UnknownService",
+			expectedService: "UnknownService",
+		},
+		{
+			name:     "should return an error when the short name is ambiguous",
+			packages: []string{"acme.users.v2", "acme.users.v1"},
+			key:      "MyService",
+			expectedError: "invalid contract 'contract.yml': service 'MyService' is ambiguous, " +
+				"use one of its fully-qualified names: " +
+				"acme.users.v1.MyService, acme.users.v2.MyService",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := make([]*descriptorpb.FileDescriptorProto, 0, len(test.packages))
+			for _, packageName := range test.packages {
+				files = append(files, newServiceFile(packageName, nil, nil))
+			}
+
+			merger := processors.NewContractMerger(newServiceFiles(t, files...))
+			err := merger.Merge("contract.yml", entities.Contract{
+				Services: map[string]entities.Service{
+					test.key: {"MyMethod": entities.Method{}},
+				},
+			})
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if _, exists := merger.Contract().Services[test.expectedService]; !exists {
+				t.Errorf(
+					"Wrong services, given: %v expected: %s",
+					merger.Contract().Services, test.expectedService,
+				)
+			}
+		})
+	}
+}
+
+func TestReadContractFile_Sources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		filePath       string
+		expectedSource entities.Source
+		getSource      func(contract entities.Contract) entities.Source
+	}{
+		{
+			name:     "should keep the position of a YAML case",
+			filePath: "testdata/contracts/billing.yml",
+			expectedSource: entities.Source{
+				File:   "testdata/contracts/billing.yml",
+				Line:   11,
+				Column: 11,
+				Index:  1,
+			},
+			getSource: func(contract entities.Contract) entities.Source {
+				return contract.Services["MyService"]["MyMethod"].SuccessCases[1].Source
+			},
+		},
+		{
+			name:     "should keep the position of a JSON case",
+			filePath: "testdata/contracts/checkout.json",
+			expectedSource: entities.Source{
+				File:   "testdata/contracts/checkout.json",
+				Line:   14,
+				Column: 11,
+				Index:  0,
+			},
+			getSource: func(contract entities.Contract) entities.Source {
+				return contract.Services["MyService"]["MyMethod"].FailureCases[0].Source
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract, err := processors.ReadContractFile(test.filePath)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if source := test.getSource(contract); source != test.expectedSource {
+				t.Errorf("Wrong source, given: %s expected: %s", source, test.expectedSource)
+			}
+		})
+	}
+}
+
+func TestContractMerger_CasePaths(t *testing.T) {
+	t.Parallel()
+
+	merger := processors.NewContractMerger(nil)
+	err := merger.MergeFiles([]string{
+		"testdata/contracts/checkout.json", "testdata/contracts/billing.yml",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	successCases := merger.Contract().Services["MyService"]["MyMethod"].SuccessCases
+	for i, successCase := range successCases {
+		if successCase.Description != "Should return the invoice" {
+			continue
+		}
+
+		// The case comes after the checkout one, but it's the first one of its file
+		path := successCase.Source.CasePath("successCases", i)
+		if path != "successCases[0]" {
+			t.Errorf("Wrong path, given: %s expected: successCases[0]", path)
+		}
+		return
+	}
+
+	t.Fatalf("Wrong cases, given: %v expected: the invoice case", successCases)
+}
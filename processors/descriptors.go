@@ -0,0 +1,52 @@
+package processors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// ReadDescriptorSet reads a FileDescriptorSet, like the ones written by `buf build` or
+// `protoc --include_imports --descriptor_set_out`, returning the protogen version of its
+// files. Every import must be in the set, while the Go package of the files is optional
+// since they aren't used to generate code.
+func ReadDescriptorSet(filePath string) ([]*protogen.File, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorSet := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(data, descriptorSet); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set '%s': %w", filePath, err)
+	}
+
+	// protogen requires a Go import path for every file, so a fake one is given
+	// to the files without a Go package
+	var importPaths []string
+	for _, file := range descriptorSet.File {
+		if file.GetOptions().GetGoPackage() == "" {
+			importPaths = append(
+				importPaths,
+				fmt.Sprintf("M%s=%s", file.GetName(), strings.TrimSuffix(file.GetName(), ".proto")),
+			)
+		}
+	}
+
+	request := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: descriptorSet.File,
+		Parameter: proto.String(strings.Join(importPaths, ",")),
+	}
+
+	plugin, err := protogen.Options{}.New(request)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set '%s': %w", filePath, err)
+	}
+
+	return plugin.Files, nil
+}
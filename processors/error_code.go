@@ -1,5 +1,11 @@
 package processors
 
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+)
+
 var allowedErrorCodeNames = []string{
 	"OK",
 	"Canceled", // It's not a typo here, this is the actual identifier in grpc codes
@@ -29,3 +35,15 @@ func IsErrorCodeValid(errorCode string) bool {
 	}
 	return false
 }
+
+// ErrorCode returns the GRPC code with the given name, e.g. NotFound.
+func ErrorCode(errorCode string) (codes.Code, error) {
+	// The names are sorted by their codes
+	for i, allowedCode := range allowedErrorCodeNames {
+		if errorCode == allowedCode {
+			return codes.Code(i), nil
+		}
+	}
+
+	return codes.Unknown, fmt.Errorf("invalid error code: %s", errorCode)
+}
@@ -0,0 +1,93 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/runtime"
+)
+
+// FormatErrorDetail converts an error detail written in the contract to the message that
+// represents it. The detail uses the JSON representation of google.protobuf.Any, so its
+// type is given by `@type`, e.g. type.googleapis.com/google.rpc.ErrorInfo.
+func FormatErrorDetail(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
+	detail interface{},
+) (string, error) {
+	dynamicDetail, err := NewErrorDetail(registry, detail)
+	if err != nil {
+		return "", err
+	}
+
+	message, err := registry.FindMessage(dynamicDetail.ProtoReflect().Descriptor().FullName())
+	if err != nil {
+		return "", err
+	}
+
+	return FormatMessageField(
+		identFunc,
+		registry,
+		message.GoIdent,
+		CreateFieldsByNumber(message.Fields),
+		dynamicDetail.ProtoReflect(),
+	)
+}
+
+// NewErrorDetail parses an error detail written in the contract, see FormatErrorDetail.
+// The returned message is dynamic, its type is found through the registry.
+func NewErrorDetail(registry *MessageRegistry, detail interface{}) (proto.Message, error) {
+	fields, ok := detail.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("error details must be objects")
+	}
+	if _, hasType := fields["@type"]; !hasType {
+		return nil, errors.New("error details must have a '@type'")
+	}
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return nil, err
+	}
+
+	anyDetail := &anypb.Any{}
+	unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+	if err = unmarshaler.Unmarshal(data, anyDetail); err != nil {
+		return nil, fmt.Errorf("invalid error detail: %w", err)
+	}
+
+	dynamicDetail, err := anypb.UnmarshalNew(anyDetail, proto.UnmarshalOptions{Resolver: registry})
+	if err != nil {
+		return nil, fmt.Errorf("invalid error detail: %w", err)
+	}
+
+	return dynamicDetail, nil
+}
+
+// NewStatus builds the status of a failure case written in the contract,
+// its details are parsed through NewErrorDetail.
+func NewStatus(registry *MessageRegistry, grpcError entities.GRPCError) (*status.Status, error) {
+	code, err := ErrorCode(grpcError.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]proto.Message, 0, len(grpcError.Details))
+	for _, detail := range grpcError.Details {
+		dynamicDetail, err := NewErrorDetail(registry, detail)
+		if err != nil {
+			return nil, err
+		}
+
+		details = append(details, dynamicDetail)
+	}
+
+	return status.Convert(runtime.NewError(code, grpcError.Message, details...)), nil
+}
@@ -0,0 +1,90 @@
+package processors_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestFormatErrorDetail(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		detail         interface{}
+		expectedFormat string
+		expectedError  string
+	}{
+		{
+			name: "should format correctly an error info",
+			detail: map[string]interface{}{
+				"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+				"reason": "TENANT_UNKNOWN",
+				"domain": "acme.com",
+			},
+			expectedFormat: `&ErrorInfo{Reason: "TENANT_UNKNOWN", Domain: "acme.com"}`,
+		},
+		{
+			name: "should format correctly a bad request with nested messages",
+			detail: map[string]interface{}{
+				"@type": "type.googleapis.com/google.rpc.BadRequest",
+				"fieldViolations": []interface{}{
+					map[string]interface{}{"field": "name", "description": "required"},
+				},
+			},
+			expectedFormat: `&BadRequest{FieldViolations: []*BadRequest_FieldViolation{` +
+				`&BadRequest_FieldViolation{Field: "name", Description: "required"}}}`,
+		},
+		{
+			name: "should format correctly a message from the plugin files",
+			detail: map[string]interface{}{
+				"@type":       "type.googleapis.com/SimpleMessage",
+				"stringField": "value",
+			},
+			expectedFormat: `&SimpleMessage{StringField: "value"}`,
+		},
+		{
+			name:          "should return an error when the type is missing",
+			detail:        map[string]interface{}{"reason": "TENANT_UNKNOWN"},
+			expectedError: "error details must have a '@type'",
+		},
+		{
+			name:          "should return an error when the detail isn't an object",
+			detail:        "TENANT_UNKNOWN",
+			expectedError: "error details must be objects",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualFormat, err := processors.FormatErrorDetail(identFunc, registry, test.detail)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package processors
 
 import (
-	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
@@ -10,6 +10,8 @@ import (
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+const protoPackage = protogen.GoImportPath("google.golang.org/protobuf/proto")
+
 // IdentFunc is used when we need to use the QualifiedGoIdent method
 // from protogen.GeneratedFile without passing the entire struct.
 type IdentFunc func(ident protogen.GoIdent) string
@@ -27,8 +29,27 @@ type FieldsByNumber map[protoreflect.FieldNumber]*protogen.Field
 // Some other examples:
 //   - int64: 64 -> 64 (to instantiate/create a int we just need the number)
 //   - []bytes: []byte("abcd") -> []byte{0x61, 0x62, 0x63, 0x64}
+//
+// Fields generated as pointers, like the proto2 scalars and enums and the proto3 `optional`
+// ones, are formatted through the pointer helpers, e.g. `proto.String("myString")` or
+// `MyEnum_VALUE.Enum()`.
 func FormatFieldValue(
 	identFunc IdentFunc,
+	registry *MessageRegistry,
+	field *protogen.Field,
+	value protoreflect.Value,
+) (string, error) {
+	formattedValue, err := formatValue(identFunc, registry, field, value)
+	if err != nil || !isPointerField(field) {
+		return formattedValue, err
+	}
+
+	return formatPointer(identFunc, field, formattedValue), nil
+}
+
+func formatValue(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
 	field *protogen.Field,
 	value protoreflect.Value,
 ) (string, error) {
@@ -42,74 +63,248 @@ func FormatFieldValue(
 	case protoreflect.EnumNumber:
 		enum := field.Enum
 
-		if value.Enum() < 0 || int(value.Enum()) >= len(enum.Values) {
+		// Enum numbers may start anywhere and have gaps, they aren't indexes of the values
+		enumValue := enum.Desc.Values().ByNumber(value.Enum())
+		if enumValue == nil {
 			return "", fmt.Errorf("enum option out of range for '%s'", enum.Desc.Name())
 		}
 
-		return fmt.Sprintf("%s", identFunc(enum.Values[value.Enum()].GoIdent)), nil
+		return identFunc(enum.Values[enumValue.Index()].GoIdent), nil
 	case protoreflect.Message:
 		fieldsByNumber := CreateFieldsByNumber(field.Message.Fields)
 
 		return FormatMessageField(
 			identFunc,
+			registry,
 			field.Message.GoIdent,
 			fieldsByNumber,
 			value.Message(),
 		)
 	case protoreflect.List:
-		return formatList(identFunc, field, value)
+		return formatList(identFunc, registry, field, value)
 	case protoreflect.Map:
-		return formatMap(identFunc, field, value)
+		return formatMap(identFunc, registry, field, value)
 	default:
 		return fmt.Sprintf("%v", v), nil
 	}
 }
 
+// isPointerField reports whether the Go struct holds the field as a pointer, so its presence
+// is known even when it's set to the zero value. Messages are always pointers already, bytes
+// use nil instead and oneof members are wrapped by their own types.
+func isPointerField(field *protogen.Field) bool {
+	if field == nil || field.Desc == nil || !field.Desc.HasPresence() {
+		return false
+	}
+	if oneof := field.Desc.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+		return false
+	}
+
+	switch field.Desc.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.BytesKind:
+		return false
+	default:
+		return true
+	}
+}
+
+func formatPointer(identFunc IdentFunc, field *protogen.Field, value string) string {
+	var helper string
+	switch field.Desc.Kind() {
+	case protoreflect.EnumKind:
+		return fmt.Sprintf("%s.Enum()", value)
+	case protoreflect.BoolKind:
+		helper = "Bool"
+	case protoreflect.StringKind:
+		helper = "String"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		helper = "Int32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		helper = "Int64"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		helper = "Uint32"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		helper = "Uint64"
+	case protoreflect.FloatKind:
+		helper = "Float32"
+	default:
+		helper = "Float64"
+	}
+
+	return fmt.Sprintf("%s(%s)", identFunc(protoPackage.Ident(helper)), value)
+}
+
 // FormatMessageField takes care of formatting a message to
-// a properly string format.
+// a properly string format. Well-known types like Timestamp, Duration,
+// wrappers and Struct are created through their constructors instead.
+//
+// Extensions can't be set in the message literal, so a message holding them is created by
+// a function literal setting them through proto.SetExtension, see formatExtension.
 func FormatMessageField(
 	identFunc IdentFunc,
+	registry *MessageRegistry,
 	ident protogen.GoIdent,
 	fieldsByNumber FieldsByNumber,
 	message protoreflect.Message,
 ) (string, error) {
-	var err error
+	formattedType, isWellKnown, err := formatWellKnownType(identFunc, registry, message)
+	if isWellKnown || err != nil {
+		return formattedType, err
+	}
 
-	messageArguments := make([]string, 0)
+	messageArguments := make([]messageArgument, 0)
+	extensions := make([]messageArgument, 0)
 	message.Range(
 		func(descriptor protoreflect.FieldDescriptor, value protoreflect.Value) bool {
-			field, exists := fieldsByNumber[descriptor.Number()]
-			if !exists {
-				err = fmt.Errorf(
-					"field not found %s while inspecting message %s",
-					descriptor.Name(), message.Type().Descriptor().FullName(),
-				)
-				return false
-			}
-
-			// We need to declare formattedField before assign the return of
-			// FormatFieldValue to it because using the shorthand assign `:=`
+			// We need to declare argument before assign the return of the
+			// format functions to it because using the shorthand assign `:=`
 			// we will lose the closure of `err`.
-			var formattedField string
-			formattedField, err = FormatFieldValue(identFunc, field, value)
-			if err != nil {
-				return false
+			var argument messageArgument
+			if descriptor.IsExtension() {
+				argument, err = formatExtension(identFunc, registry, descriptor, value)
+				extensions = append(extensions, argument)
+			} else {
+				argument, err = formatField(identFunc, registry, fieldsByNumber, descriptor, value)
+				messageArguments = append(messageArguments, argument)
 			}
 
-			messageArguments = append(
-				messageArguments,
-				fmt.Sprintf("%s: %s", field.GoName, formattedField),
-			)
-
-			return true
+			return err == nil
 		},
 	)
+	if err != nil {
+		return "", err
+	}
 
-	return fmt.Sprintf(
+	formattedMessage := fmt.Sprintf(
 		"&%s{%s}",
 		identFunc(ident),
-		strings.Join(messageArguments, ", "),
-	), err
+		joinArguments(messageArguments, ", "), //nolint:revive // don't need a const for sep
+	)
+	if len(extensions) == 0 {
+		return formattedMessage, nil
+	}
+
+	return fmt.Sprintf(
+		"func() *%s {\nextended := %s\n%s\nreturn extended\n}()",
+		identFunc(ident),
+		formattedMessage,
+		joinArguments(extensions, "\n"),
+	), nil
+}
+
+type messageArgument struct {
+	index int
+	value string
+}
+
+// joinArguments sorts the arguments before joining them, since Range doesn't guarantee
+// any order and the same code must always be generated.
+func joinArguments(arguments []messageArgument, sep string) string {
+	sort.Slice(arguments, func(i, j int) bool {
+		return arguments[i].index < arguments[j].index
+	})
+
+	formattedArguments := make([]string, 0, len(arguments))
+	for _, argument := range arguments {
+		formattedArguments = append(formattedArguments, argument.value)
+	}
+
+	return strings.Join(formattedArguments, sep)
+}
+
+// formatField formats the field as an argument of the message literal, they're sorted by
+// their declaration.
+func formatField(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
+	fieldsByNumber FieldsByNumber,
+	descriptor protoreflect.FieldDescriptor,
+	value protoreflect.Value,
+) (messageArgument, error) {
+	field, exists := fieldsByNumber[descriptor.Number()]
+	if !exists {
+		return messageArgument{}, fmt.Errorf(
+			"field not found %s while inspecting message %s",
+			descriptor.Name(), descriptor.ContainingMessage().FullName(),
+		)
+	}
+
+	formattedField, err := FormatFieldValue(identFunc, registry, field, value)
+	if err != nil {
+		return messageArgument{}, err
+	}
+
+	return messageArgument{
+		index: descriptor.Index(),
+		value: formatMessageArgument(identFunc, field, formattedField),
+	}, nil
+}
+
+// formatExtension formats the statement setting the extension in the message created by
+// FormatMessageField, e.g. `proto.SetExtension(extended, E_Tag, "tag")`.
+// The statements are sorted by the extension numbers.
+func formatExtension(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
+	descriptor protoreflect.FieldDescriptor,
+	value protoreflect.Value,
+) (messageArgument, error) {
+	if registry == nil {
+		return messageArgument{}, fmt.Errorf(
+			"can't resolve the extension %s", descriptor.FullName(),
+		)
+	}
+
+	extension, err := registry.FindExtension(descriptor.FullName())
+	if err != nil {
+		return messageArgument{}, err
+	}
+
+	// proto.SetExtension takes the scalars and enums themselves, not pointers to them
+	formattedValue, err := formatValue(identFunc, registry, extension, value)
+	if err != nil {
+		return messageArgument{}, err
+	}
+
+	// Untyped constants would be given as int or float64, which proto.SetExtension rejects
+	if !descriptor.IsList() && isNumericKind(descriptor.Kind()) {
+		formattedValue = fmt.Sprintf(
+			"%s(%s)", formatGoType(identFunc, extension), formattedValue,
+		)
+	}
+
+	// The variable generated for each extension has the `E_` prefix
+	extensionVar := protogen.GoIdent{
+		GoName:       "E_" + extension.GoIdent.GoName,
+		GoImportPath: extension.GoIdent.GoImportPath,
+	}
+
+	return messageArgument{
+		index: int(descriptor.Number()),
+		value: fmt.Sprintf(
+			"%s(extended, %s, %s)",
+			identFunc(protoPackage.Ident("SetExtension")),
+			identFunc(extensionVar),
+			formattedValue,
+		),
+	}, nil
+}
+
+// formatMessageArgument formats the field inside the message literal. A oneof member is set
+// through the oneof field, wrapped by the type generated for the member, e.g.
+// `Result: &Response_Text{Text: "done"}`.
+func formatMessageArgument(identFunc IdentFunc, field *protogen.Field, value string) string {
+	if field.Oneof == nil || field.Oneof.Desc.IsSynthetic() {
+		return fmt.Sprintf("%s: %s", field.GoName, value)
+	}
+
+	return fmt.Sprintf(
+		"%s: &%s{%s: %s}",
+		field.Oneof.GoName,
+		identFunc(field.GoIdent),
+		field.GoName,
+		value,
+	)
 }
 
 // CreateFieldsByNumber transform a slice of protogen.Field into a FieldsByNumber,
@@ -126,6 +321,7 @@ func CreateFieldsByNumber(fields []*protogen.Field) FieldsByNumber {
 
 func formatList(
 	identFunc IdentFunc,
+	registry *MessageRegistry,
 	field *protogen.Field,
 	value protoreflect.Value,
 ) (string, error) {
@@ -134,33 +330,23 @@ func formatList(
 	formattedValues := make([]string, 0, list.Len())
 	for i := 0; i < list.Len(); i++ {
 		item := list.Get(i)
-		formattedValue, err := FormatFieldValue(identFunc, field, item)
+		formattedValue, err := FormatFieldValue(identFunc, registry, field, item)
 		if err != nil {
 			return "", err
 		}
 		formattedValues = append(formattedValues, formattedValue)
 	}
 
-	switch field.Desc.Kind() {
-	case protoreflect.GroupKind:
-		return "", errors.New("we don't support groups yet")
-	case protoreflect.MessageKind:
-		return fmt.Sprintf(
-			"[]*%s{%s}",
-			identFunc(field.Message.GoIdent),
-			strings.Join(formattedValues, ", "),
-		), nil
-	default:
-		return fmt.Sprintf(
-			"[]%s{%s}",
-			field.Desc.Kind().String(),
-			strings.Join(formattedValues, ", "),
-		), nil
-	}
+	return fmt.Sprintf(
+		"[]%s{%s}",
+		formatGoType(identFunc, field),
+		strings.Join(formattedValues, ", "),
+	), nil
 }
 
 func formatMap(
 	identFunc IdentFunc,
+	registry *MessageRegistry,
 	field *protogen.Field,
 	value protoreflect.Value,
 ) (string, error) {
@@ -185,13 +371,13 @@ func formatMap(
 	formattedValues := make([]string, 0, m.Len())
 	m.Range(func(key protoreflect.MapKey, insideValue protoreflect.Value) bool {
 		var formattedKey string
-		formattedKey, err = FormatFieldValue(identFunc, valueField, key.Value())
+		formattedKey, err = formatValue(identFunc, registry, keyField, key.Value())
 		if err != nil {
 			return false
 		}
 
 		var formattedValue string
-		formattedValue, err = FormatFieldValue(identFunc, valueField, insideValue)
+		formattedValue, err = formatValue(identFunc, registry, valueField, insideValue)
 		if err != nil {
 			return false
 		}
@@ -208,22 +394,56 @@ func formatMap(
 		return "", err
 	}
 
-	switch valueField.Desc.Kind() {
-	case protoreflect.GroupKind:
-		return "", errors.New("we don't support groups yet")
-	case protoreflect.MessageKind:
-		return fmt.Sprintf(
-			"map[%s]*%s{%s}",
-			keyField.Desc.Kind(),
-			identFunc(valueField.Message.GoIdent),
-			strings.Join(formattedValues, ", "), //nolint:revive // don't need a const for sep
-		), nil
+	// Map iteration has no order, sorting keeps the generated code stable
+	sort.Strings(formattedValues)
+
+	return fmt.Sprintf(
+		"map[%s]%s{%s}",
+		formatGoType(identFunc, keyField),
+		formatGoType(identFunc, valueField),
+		strings.Join(formattedValues, ", "), //nolint:revive // don't need a const for sep
+	), nil
+}
+
+// goKindTypes maps the scalar kinds whose name isn't their Go type.
+var goKindTypes = map[protoreflect.Kind]string{
+	protoreflect.Sint32Kind:   "int32",
+	protoreflect.Sfixed32Kind: "int32",
+	protoreflect.Fixed32Kind:  "uint32",
+	protoreflect.Sint64Kind:   "int64",
+	protoreflect.Sfixed64Kind: "int64",
+	protoreflect.Fixed64Kind:  "uint64",
+	protoreflect.FloatKind:    "float32",
+	protoreflect.DoubleKind:   "float64",
+	protoreflect.BytesKind:    "[]byte",
+}
+
+// isNumericKind reports whether the values of the kind are written as number constants.
+func isNumericKind(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.BoolKind, protoreflect.StringKind, protoreflect.BytesKind,
+		protoreflect.EnumKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
 	default:
-		return fmt.Sprintf(
-			"map[%s]%s{%s}",
-			keyField.Desc.Kind(),
-			valueField.Desc.Kind(),
-			strings.Join(formattedValues, ", "), //nolint:revive // don't need a const for sep
-		), nil
+		return true
+	}
+}
+
+// formatGoType returns the Go type of a single value of the field, the element type
+// of lists and maps.
+func formatGoType(identFunc IdentFunc, field *protogen.Field) string {
+	kind := field.Desc.Kind()
+
+	switch kind {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "*" + identFunc(field.Message.GoIdent)
+	case protoreflect.EnumKind:
+		return identFunc(field.Enum.GoIdent)
 	}
+
+	if goType, ok := goKindTypes[kind]; ok {
+		return goType
+	}
+
+	return kind.String()
 }
@@ -1,11 +1,28 @@
 package processors_test
 
 import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path"
+	"strings"
 	"testing"
 
 	"google.golang.org/protobuf/encoding/protojson"
 
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/protobuf/types/pluginpb"
 
 	"github.com/faunists/deal-go/mocks"
 
@@ -175,6 +192,98 @@ func TestFormatFieldValue(t *testing.T) {
 			),
 			expectedFormat: `map[string]*SimpleMessage{"my value": &SimpleMessage{IntField: 42}}`,
 		},
+		{
+			name: "should format correctly when value is a message with a oneof of string",
+			value: protoreflect.ValueOfMessage(
+				getMessage(
+					t,
+					"MessageWithOneof",
+					[]byte(`{"textResult": "done", "note": "first"}`),
+				),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOneof"),
+			},
+			expectedFormat: `&MessageWithOneof{` +
+				`Result: &MessageWithOneof_TextResult{TextResult: "done"}, Note: "first"}`,
+		},
+		{
+			name: "should format correctly when value is a message with a oneof of message",
+			value: protoreflect.ValueOfMessage(
+				getMessage(
+					t,
+					"MessageWithOneof",
+					[]byte(`{"messageResult": {"intField": 42}}`),
+				),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOneof"),
+			},
+			expectedFormat: `&MessageWithOneof{Result: &MessageWithOneof_MessageResult{` +
+				`MessageResult: &SimpleMessage{IntField: 42}}}`,
+		},
+		{
+			name: "should format correctly when value is a message with a oneof of enum",
+			value: protoreflect.ValueOfMessage(
+				getMessage(t, "MessageWithOneof", []byte(`{"enumResult": "TWO"}`)),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOneof"),
+			},
+			expectedFormat: `&MessageWithOneof{Result: &MessageWithOneof_EnumResult{` +
+				`EnumResult: EnumNumbers_TWO}}`,
+		},
+		{
+			name: "should format correctly when the oneof is set to its default value",
+			value: protoreflect.ValueOfMessage(
+				getMessage(t, "MessageWithOneof", []byte(`{"textResult": ""}`)),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOneof"),
+			},
+			expectedFormat: `&MessageWithOneof{` +
+				`Result: &MessageWithOneof_TextResult{TextResult: ""}}`,
+		},
+		{
+			name: "should format correctly when value is a message with optional fields",
+			value: protoreflect.ValueOfMessage(
+				getMessage(
+					t,
+					"MessageWithOptional",
+					[]byte(`{"optionalString": "text", "optionalEnum": "TWO", "optionalInt": 42}`),
+				),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOptional"),
+			},
+			expectedFormat: `&MessageWithOptional{OptionalString: String("text"), ` +
+				`OptionalEnum: EnumNumbers_TWO.Enum(), OptionalInt: Int64(42)}`,
+		},
+		{
+			name: "should format correctly when optional fields are set to their zero value",
+			value: protoreflect.ValueOfMessage(
+				getMessage(
+					t,
+					"MessageWithOptional",
+					[]byte(`{"optionalBool": false, "optionalDouble": 0, "optionalBytes": ""}`),
+				),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOptional"),
+			},
+			expectedFormat: `&MessageWithOptional{OptionalBool: Bool(false), ` +
+				`OptionalDouble: Float64(0.000000), OptionalBytes: []byte{}}`,
+		},
+		{
+			name: "should format correctly when optional fields are unset",
+			value: protoreflect.ValueOfMessage(
+				getMessage(t, "MessageWithOptional", []byte(`{}`)),
+			),
+			field: &protogen.Field{
+				Message: protoFields.getMessage(t, "MessageWithOptional"),
+			},
+			expectedFormat: `&MessageWithOptional{}`,
+		},
 	}
 
 	identFunc := func(ident protogen.GoIdent) string {
@@ -185,6 +294,7 @@ func TestFormatFieldValue(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			actualFormat, err := processors.FormatFieldValue(
 				identFunc,
+				nil,
 				test.field,
 				test.value,
 			)
@@ -203,6 +313,598 @@ func TestFormatFieldValue(t *testing.T) {
 	}
 }
 
+func TestFormatMessageField_WellKnownTypes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		message        proto.Message
+		value          string
+		expectedFormat string
+	}{
+		{
+			name:    "should format a timestamp through its constructor",
+			message: &timestamppb.Timestamp{},
+			value:   `"2021-03-04T05:06:07.5Z"`,
+			expectedFormat: "timestamppb.New(" +
+				"time.Date(2021, time.March, 4, 5, 6, 7, 500000000, time.UTC))",
+		},
+		{
+			name:           "should format a duration using the largest unit",
+			message:        &durationpb.Duration{},
+			value:          `"3.5s"`,
+			expectedFormat: "durationpb.New(3500 * time.Millisecond)",
+		},
+		{
+			name:           "should format a negative duration",
+			message:        &durationpb.Duration{},
+			value:          `"-7200s"`,
+			expectedFormat: "durationpb.New(-2 * time.Hour)",
+		},
+		{
+			name:           "should format a zero duration",
+			message:        &durationpb.Duration{},
+			value:          `"0s"`,
+			expectedFormat: "durationpb.New(0)",
+		},
+		{
+			name:           "should format a duration longer than a time.Duration field by field",
+			message:        &durationpb.Duration{},
+			value:          `"315576000000s"`,
+			expectedFormat: "&Duration{Seconds: 315576000000}",
+		},
+		{
+			name:           "should format a string wrapper from its bare value",
+			message:        &wrapperspb.StringValue{},
+			value:          `"text"`,
+			expectedFormat: `wrapperspb.String("text")`,
+		},
+		{
+			name:           "should format a number wrapper from its bare value",
+			message:        &wrapperspb.Int64Value{},
+			value:          `"42"`,
+			expectedFormat: "wrapperspb.Int64(42)",
+		},
+		{
+			name:           "should format a bool wrapper set to false",
+			message:        &wrapperspb.BoolValue{},
+			value:          `false`,
+			expectedFormat: "wrapperspb.Bool(false)",
+		},
+		{
+			name:    "should format a struct from its JSON",
+			message: &structpb.Struct{},
+			value:   `{"name": "deal", "count": 2, "tags": ["a", null], "nested": {"ok": true}}`,
+			expectedFormat: `runtime.MustNewStruct(map[string]interface{}{"count": float64(2), ` +
+				`"name": "deal", "nested": map[string]interface{}{"ok": true}, ` +
+				`"tags": []interface{}{"a", nil}})`,
+		},
+		{
+			name:           "should format a list value from its JSON",
+			message:        &structpb.ListValue{},
+			value:          `[1.5, "b"]`,
+			expectedFormat: `runtime.MustNewList([]interface{}{float64(1.5), "b"})`,
+		},
+		{
+			name:    "should format large numbers and exponents as float64",
+			message: &structpb.Struct{},
+			value:   `{"large": 100000000000000000000, "small": 1.5e-10, "negative": -2E+30}`,
+			expectedFormat: `runtime.MustNewStruct(map[string]interface{}{` +
+				`"large": float64(1e+20), "negative": float64(-2e+30), "small": float64(1.5e-10)})`,
+		},
+		{
+			name:           "should format a string value through its constructor",
+			message:        &structpb.Value{},
+			value:          `"text"`,
+			expectedFormat: `structpb.NewStringValue("text")`,
+		},
+		{
+			name:           "should format a null value through its constructor",
+			message:        &structpb.Value{},
+			value:          `null`,
+			expectedFormat: "structpb.NewNullValue()",
+		},
+		{
+			name:    "should format an object value through its constructor",
+			message: &structpb.Value{},
+			value:   `{"id": 1}`,
+			expectedFormat: `structpb.NewStructValue(` +
+				`runtime.MustNewStruct(map[string]interface{}{"id": float64(1)}))`,
+		},
+		{
+			name:           "should format a field mask field by field",
+			message:        &fieldmaskpb.FieldMask{},
+			value:          `"user.name,user.email"`,
+			expectedFormat: `&FieldMask{Paths: []string{"user.name", "user.email"}}`,
+		},
+		{
+			name:           "should format an empty message",
+			message:        &emptypb.Empty{},
+			value:          `{}`,
+			expectedFormat: "&Empty{}",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return path.Base(string(ident.GoImportPath)) + "." + ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := dynamicpb.NewMessage(test.message.ProtoReflect().Descriptor())
+			if err := protojson.Unmarshal([]byte(test.value), message); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			file, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+				ProtoFile: []*descriptorpb.FileDescriptorProto{
+					protodesc.ToFileDescriptorProto(message.Descriptor().ParentFile()),
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var protoMessage *protogen.Message
+			for _, m := range file.Files[0].Messages {
+				if m.Desc.FullName() == message.Descriptor().FullName() {
+					protoMessage = m
+				}
+			}
+
+			actualFormat, err := processors.FormatMessageField(
+				func(ident protogen.GoIdent) string {
+					if ident == protoMessage.GoIdent {
+						return ident.GoName
+					}
+					return identFunc(ident)
+				},
+				nil,
+				protoMessage.GoIdent,
+				processors.CreateFieldsByNumber(protoMessage.Fields),
+				message,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
+
+func TestFormatMessageField_Any(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		value          string
+		expectedFormat string
+	}{
+		{
+			name:           "should pack the message of the type",
+			value:          `{"@type": "type.googleapis.com/SimpleMessage", "intField": 42}`,
+			expectedFormat: "MustNewAny(&SimpleMessage{IntField: 42})",
+		},
+		{
+			name: "should pack a message with oneofs",
+			value: `{"@type": "type.googleapis.com/MessageWithOneof", ` +
+				`"messageResult": {"stringField": "done"}}`,
+			expectedFormat: "MustNewAny(&MessageWithOneof{" +
+				"Result: &MessageWithOneof_MessageResult{" +
+				`MessageResult: &SimpleMessage{StringField: "done"}}})`,
+		},
+		{
+			name: "should pack a well-known type",
+			value: `{"@type": "type.googleapis.com/google.protobuf.Duration", ` +
+				`"value": "1s"}`,
+			expectedFormat: "MustNewAny(New(1 * Second))",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := dynamicpb.NewMessage((&anypb.Any{}).ProtoReflect().Descriptor())
+			unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+			if err := unmarshaler.Unmarshal([]byte(test.value), message); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualFormat, err := processors.FormatMessageField(
+				identFunc, registry, protogen.GoIdent{GoName: "Any"}, nil, message,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
+
+func TestFormatMessageField_AnyErrors(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		registry      *processors.MessageRegistry
+		expectedError string
+	}{
+		{
+			name:     "should return an error when the type is unknown",
+			registry: registry,
+			expectedError: "unknown type 'type.googleapis.com/acme.Unknown' of " +
+				"google.protobuf.Any",
+		},
+		{
+			name:     "should return an error without a registry",
+			registry: nil,
+			expectedError: "can't resolve the type 'type.googleapis.com/acme.Unknown' of " +
+				"google.protobuf.Any",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := processors.FormatMessageField(
+				identFunc,
+				test.registry,
+				protogen.GoIdent{GoName: "Any"},
+				nil,
+				(&anypb.Any{TypeUrl: "type.googleapis.com/acme.Unknown"}).ProtoReflect(),
+			)
+			// The errors of the protobuf module are not stable, so only the prefix is compared
+			if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+				t.Fatalf(`wrong error, given: "%v" expected: "%s"`, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestFormatMessageField_Proto2(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	protoMessage, err := registry.FindMessage("legacy.LegacyMessage")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		value          string
+		expectedFormat string
+	}{
+		{
+			name: "should format the scalars through the pointer helpers",
+			value: `{"id": "1", "name": "john", "count": 10, "level": "HIGH", ` +
+				`"data": "YQ==", "tags": ["a"]}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(1), Name: String("john"), ` +
+				`Count: Int32(10), Level: Level_HIGH.Enum(), Data: []byte{0x61}, ` +
+				`Tags: []string{"a"}}`,
+		},
+		{
+			name:           "should keep the zero values set",
+			value:          `{"id": "0", "name": "", "count": 0}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(0), Name: String(""), Count: Int32(0)}`,
+		},
+		{
+			name:           "should not use pointers for the oneof members",
+			value:          `{"id": "1", "text": "hi"}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(1), Choice: &LegacyMessage_Text{Text: "hi"}}`,
+		},
+		{
+			name:  "should format the groups",
+			value: `{"id": "1", "meta": {"owner": "ops"}, "item": [{"sku": "a"}, {"sku": "b"}]}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(1), ` +
+				`Meta: &LegacyMessage_Meta{Owner: String("ops")}, ` +
+				`Item: []*LegacyMessage_Item{&LegacyMessage_Item{Sku: String("a")}, ` +
+				`&LegacyMessage_Item{Sku: String("b")}}}`,
+		},
+		{
+			name:  "should not use pointers for the map keys and values",
+			value: `{"id": "1", "counters": {"b": 2, "a": 1}, "levels": {"3": "HIGH"}}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(1), ` +
+				`Counters: map[string]int32{"a": 1, "b": 2}, ` +
+				`Levels: map[int32]Level{3: Level_HIGH}}`,
+		},
+		{
+			name:           "should find the enum values by their number",
+			value:          `{"id": "1", "status": "ACTIVE"}`,
+			expectedFormat: `&LegacyMessage{Id: Int64(1), Status: Status_ACTIVE.Enum()}`,
+		},
+		{
+			name: "should set the extensions in a function literal",
+			value: `{"id": "1", "[legacy.Note.note]": {"text": "n"}, "[legacy.codes]": [1, 2], ` +
+				`"[legacy.label]": "x", "[legacy.priority]": "HIGH"}`,
+			expectedFormat: "func() *LegacyMessage {\n" +
+				"extended := &LegacyMessage{Id: Int64(1)}\n" +
+				`SetExtension(extended, E_Label, "x")` + "\n" +
+				"SetExtension(extended, E_Codes, []int32{1, 2})\n" +
+				"SetExtension(extended, E_Priority, Level_HIGH)\n" +
+				`SetExtension(extended, E_Note_Note, &Note{Text: String("n")})` + "\n" +
+				"return extended\n" +
+				"}()",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := dynamicpb.NewMessage(protoMessage.Desc)
+			unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+			if err := unmarshaler.Unmarshal([]byte(test.value), message); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualFormat, err := processors.FormatMessageField(
+				identFunc,
+				registry,
+				protoMessage.GoIdent,
+				processors.CreateFieldsByNumber(protoMessage.Fields),
+				message,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
+
+func TestFormatMessageField_ScalarExtensions(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	protoMessage, err := registry.FindMessage("legacy.LegacyMessage")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		extension         protoreflect.FullName
+		value             string
+		expectedStatement string
+		expectedValue     interface{}
+	}{
+		{
+			name:              "should convert an int32 extension",
+			extension:         "legacy.rank",
+			value:             `5`,
+			expectedStatement: "SetExtension(extended, E_Rank, int32(5))",
+			expectedValue:     int32(5), //nolint:revive // random number
+		},
+		{
+			name:              "should convert a sint64 extension",
+			extension:         "legacy.delta",
+			value:             `"-7"`,
+			expectedStatement: "SetExtension(extended, E_Delta, int64(-7))",
+			expectedValue:     int64(-7), //nolint:revive // random number
+		},
+		{
+			name:              "should convert an uint64 extension",
+			extension:         "legacy.total",
+			value:             `"9"`,
+			expectedStatement: "SetExtension(extended, E_Total, uint64(9))",
+			expectedValue:     uint64(9), //nolint:revive // random number
+		},
+		{
+			name:              "should convert a fixed32 extension",
+			extension:         "legacy.checksum",
+			value:             `3`,
+			expectedStatement: "SetExtension(extended, E_Checksum, uint32(3))",
+			expectedValue:     uint32(3), //nolint:revive // random number
+		},
+		{
+			name:              "should convert a float extension",
+			extension:         "legacy.ratio",
+			value:             `1.5`,
+			expectedStatement: "SetExtension(extended, E_Ratio, float32(1.500000))",
+			expectedValue:     float32(1.5), //nolint:revive // random number
+		},
+		{
+			name:              "should convert a double extension",
+			extension:         "legacy.weight",
+			value:             `2.25`,
+			expectedStatement: "SetExtension(extended, E_Weight, float64(2.250000))",
+			expectedValue:     2.25, //nolint:revive // random number
+		},
+		{
+			name:              "should keep a bool extension as it is",
+			extension:         "legacy.flagged",
+			value:             `true`,
+			expectedStatement: "SetExtension(extended, E_Flagged, true)",
+			expectedValue:     true,
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			message := dynamicpb.NewMessage(protoMessage.Desc)
+			unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+			value := fmt.Sprintf(`{"id": "1", "[%s]": %s}`, test.extension, test.value)
+			if err := unmarshaler.Unmarshal([]byte(value), message); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualFormat, err := processors.FormatMessageField(
+				identFunc,
+				registry,
+				protoMessage.GoIdent,
+				processors.CreateFieldsByNumber(protoMessage.Fields),
+				message,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			statement := strings.Split(actualFormat, "\n")[2]
+			if statement != test.expectedStatement {
+				t.Fatalf(
+					"Wrong statement, given: %s expected: %s", statement, test.expectedStatement,
+				)
+			}
+
+			// Play the statement, the extension types reject values of another Go type
+			extensionType, err := registry.FindExtensionByName(test.extension)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			argument := strings.SplitN(strings.TrimSuffix(statement, ")"), ", ", 3)[2]
+			extended := dynamicpb.NewMessage(protoMessage.Desc)
+			err = setExtension(extended, extensionType, evalConstant(t, argument))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualValue := proto.GetExtension(extended, extensionType)
+			if actualValue != test.expectedValue {
+				t.Errorf("Wrong value, given: %v expected: %v", actualValue, test.expectedValue)
+			}
+		})
+	}
+}
+
+// setExtension calls proto.SetExtension, returning its panic as an error.
+func setExtension(
+	message proto.Message,
+	extensionType protoreflect.ExtensionType,
+	value interface{},
+) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	proto.SetExtension(message, extensionType, value)
+
+	return nil
+}
+
+// evalConstant evaluates a constant expression of the generated code, e.g. `int32(5)`,
+// returning its value with the Go type the compiler would give it.
+func evalConstant(t *testing.T, expression string) interface{} {
+	t.Helper()
+
+	typed, err := types.Eval(token.NewFileSet(), nil, token.NoPos, expression)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	value := typed.Value
+
+	switch typed.Type.Underlying().(*types.Basic).Kind() { //nolint:exhaustive // constants only
+	case types.Bool, types.UntypedBool:
+		return constant.BoolVal(value)
+	case types.Float32:
+		float, _ := constant.Float32Val(value)
+		return float
+	case types.Float64, types.UntypedFloat:
+		float, _ := constant.Float64Val(value)
+		return float
+	case types.Uint32:
+		unsigned, _ := constant.Uint64Val(value)
+		return uint32(unsigned)
+	case types.Uint64:
+		unsigned, _ := constant.Uint64Val(value)
+		return unsigned
+	case types.Int32:
+		integer, _ := constant.Int64Val(value)
+		return int32(integer)
+	case types.Int64:
+		integer, _ := constant.Int64Val(value)
+		return integer
+	default:
+		// Untyped integers become int
+		integer, _ := constant.Int64Val(value)
+		return int(integer)
+	}
+}
+
+func TestFormatMessageField_ExtensionErrors(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	protoMessage, err := registry.FindMessage("legacy.LegacyMessage")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	message := dynamicpb.NewMessage(protoMessage.Desc)
+	unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+	err = unmarshaler.Unmarshal([]byte(`{"id": "1", "[legacy.label]": "x"}`), message)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = processors.FormatMessageField(
+		func(ident protogen.GoIdent) string { return ident.GoName },
+		nil,
+		protoMessage.GoIdent,
+		processors.CreateFieldsByNumber(protoMessage.Fields),
+		message,
+	)
+
+	expectedError := "can't resolve the extension legacy.label"
+	if err == nil || err.Error() != expectedError {
+		t.Fatalf(`wrong error, given: "%v" expected: "%s"`, err, expectedError)
+	}
+}
+
 func TestFormatFieldValue_Errors(t *testing.T) {
 	t.Parallel()
 
@@ -242,6 +944,7 @@ func TestFormatFieldValue_Errors(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			_, err := processors.FormatFieldValue(
 				identFunc,
+				nil,
 				test.field,
 				test.value,
 			)
@@ -0,0 +1,325 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/runtime"
+)
+
+// RuntimePackage is the package holding the helpers used by the generated code.
+const RuntimePackage = protogen.GoImportPath("github.com/faunists/deal-go/runtime")
+
+const mathPackage = protogen.GoImportPath("math")
+
+// FormatRequestMatchers validates the matchers against the request message and converts
+// each one of them to the runtime.FieldMatcher constructor call that represents it.
+// The matchers are sorted by their path, so the generated code is always the same.
+func FormatRequestMatchers(
+	identFunc IdentFunc,
+	message *protogen.Message,
+	matchers map[string]entities.FieldMatcher,
+) ([]string, error) {
+	paths := make([]string, 0, len(matchers))
+	for path := range matchers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	formattedMatchers := make([]string, 0, len(matchers))
+	for _, path := range paths {
+		field, err := findFieldByPath(message.Desc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		formattedMatcher, err := formatMatcher(identFunc, field, path, matchers[path])
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher for '%s': %w", path, err)
+		}
+
+		formattedMatchers = append(formattedMatchers, formattedMatcher)
+	}
+
+	return formattedMatchers, nil
+}
+
+func findFieldByPath(
+	message protoreflect.MessageDescriptor,
+	path string,
+) (protoreflect.FieldDescriptor, error) {
+	names := strings.Split(path, ".")
+
+	var field protoreflect.FieldDescriptor
+	for i, name := range names {
+		if i > 0 {
+			if field.Message() == nil || field.IsList() || field.IsMap() {
+				return nil, fmt.Errorf(
+					"field '%s' is not a message and can't be traversed by '%s'",
+					field.Name(), path,
+				)
+			}
+			message = field.Message()
+		}
+
+		field = message.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			field = message.Fields().ByJSONName(name)
+		}
+		if field == nil {
+			return nil, fmt.Errorf("field '%s' not found in message %s", name, message.FullName())
+		}
+	}
+
+	return field, nil
+}
+
+func formatMatcher(
+	identFunc IdentFunc,
+	field protoreflect.FieldDescriptor,
+	path string,
+	matcher entities.FieldMatcher,
+) (string, error) {
+	if err := checkMatcher(field, matcher); err != nil {
+		return "", err
+	}
+
+	runtimeIdent := func(name string) string {
+		return identFunc(RuntimePackage.Ident(name))
+	}
+
+	switch {
+	case matcher.Present:
+		return fmt.Sprintf("%s(%q)", runtimeIdent("Present"), path), nil
+	case matcher.Any:
+		return fmt.Sprintf("%s(%q)", runtimeIdent("Any"), path), nil
+	case matcher.Regex != "":
+		return fmt.Sprintf("%s(%q, %q)", runtimeIdent("Regex"), path, matcher.Regex), nil
+	case matcher.Prefix != "":
+		return fmt.Sprintf("%s(%q, %q)", runtimeIdent("Prefix"), path, matcher.Prefix), nil
+	case matcher.Range != nil:
+		return fmt.Sprintf(
+			"%s(%q, %s, %s)",
+			runtimeIdent("Range"),
+			path,
+			formatBoundary(identFunc, matcher.Range.Min, "-1"),
+			formatBoundary(identFunc, matcher.Range.Max, "1"),
+		), nil
+	case matcher.OneOf != nil:
+		return fmt.Sprintf(
+			"%s(%q, %s)", runtimeIdent("OneOf"), path, formatMatcherValues(matcher.OneOf),
+		), nil
+	default:
+		return fmt.Sprintf(
+			"%s(%q, %s)", runtimeIdent("SubsetOf"), path, formatMatcherValues(matcher.SubsetOf),
+		), nil
+	}
+}
+
+// NewRequestMatchers is the same as FormatRequestMatchers, but the matchers are built
+// instead of formatted, so they can be used without generating code.
+func NewRequestMatchers(
+	message protoreflect.MessageDescriptor,
+	matchers map[string]entities.FieldMatcher,
+) ([]runtime.FieldMatcher, error) {
+	paths := make([]string, 0, len(matchers))
+	for path := range matchers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fieldMatchers := make([]runtime.FieldMatcher, 0, len(matchers))
+	for _, path := range paths {
+		field, err := findFieldByPath(message, path)
+		if err != nil {
+			return nil, err
+		}
+
+		matcher := matchers[path]
+		if err = checkMatcher(field, matcher); err != nil {
+			return nil, fmt.Errorf("invalid matcher for '%s': %w", path, err)
+		}
+
+		fieldMatchers = append(fieldMatchers, newMatcher(path, matcher))
+	}
+
+	return fieldMatchers, nil
+}
+
+// newMatcher builds the runtime.FieldMatcher of a matcher already checked.
+func newMatcher(path string, matcher entities.FieldMatcher) runtime.FieldMatcher {
+	switch {
+	case matcher.Present:
+		return runtime.Present(path)
+	case matcher.Any:
+		return runtime.Any(path)
+	case matcher.Regex != "":
+		return runtime.Regex(path, matcher.Regex)
+	case matcher.Prefix != "":
+		return runtime.Prefix(path, matcher.Prefix)
+	case matcher.Range != nil:
+		min, max := math.Inf(-1), math.Inf(1)
+		if matcher.Range.Min != nil {
+			min = *matcher.Range.Min
+		}
+		if matcher.Range.Max != nil {
+			max = *matcher.Range.Max
+		}
+		return runtime.Range(path, min, max)
+	case matcher.OneOf != nil:
+		return runtime.OneOf(path, matcher.OneOf...)
+	default:
+		return runtime.SubsetOf(path, matcher.SubsetOf...)
+	}
+}
+
+// checkMatcher verifies whether the matcher has a single rule that can be used with the field.
+func checkMatcher( //nolint:gocognit // a flat list of rules, splitting it would hurt reading
+	field protoreflect.FieldDescriptor,
+	matcher entities.FieldMatcher,
+) error {
+	if err := checkSingleRule(matcher); err != nil {
+		return err
+	}
+
+	switch {
+	case matcher.Present, matcher.Any:
+		return nil
+	case matcher.Regex != "":
+		if !isStringLike(field) || field.IsList() {
+			return errors.New("regex can only be used with singular string and enum fields")
+		}
+		_, err := regexp.Compile(matcher.Regex)
+		return err
+	case matcher.Prefix != "":
+		if !isStringLike(field) || field.IsList() {
+			return errors.New("prefix can only be used with singular string and enum fields")
+		}
+		return nil
+	case matcher.Range != nil:
+		if !isNumeric(field) || field.IsList() {
+			return errors.New("range can only be used with singular numeric fields")
+		}
+		return nil
+	case matcher.OneOf != nil:
+		if field.IsList() || field.IsMap() {
+			return errors.New("oneOf can only be used with singular fields")
+		}
+		return checkMatcherValues(field, matcher.OneOf)
+	default:
+		if !field.IsList() {
+			return errors.New("subsetOf can only be used with repeated fields")
+		}
+		return checkMatcherValues(field, matcher.SubsetOf)
+	}
+}
+
+func checkSingleRule(matcher entities.FieldMatcher) error {
+	rules := []bool{
+		matcher.Present,
+		matcher.Any,
+		matcher.Regex != "",
+		matcher.Prefix != "",
+		matcher.Range != nil,
+		matcher.OneOf != nil,
+		matcher.SubsetOf != nil,
+	}
+
+	count := 0
+	for _, isSet := range rules {
+		if isSet {
+			count++
+		}
+	}
+
+	switch count {
+	case 0:
+		return errors.New("no rule was provided")
+	case 1:
+		return nil
+	default:
+		return errors.New("only one rule must be provided")
+	}
+}
+
+func formatBoundary(identFunc IdentFunc, boundary *float64, infinitySign string) string {
+	if boundary == nil {
+		return fmt.Sprintf("%s(%s)", identFunc(mathPackage.Ident("Inf")), infinitySign)
+	}
+
+	return strconv.FormatFloat(*boundary, 'g', -1, 64)
+}
+
+func formatMatcherValues(values []interface{}) string {
+	formattedValues := make([]string, 0, len(values))
+	for _, value := range values {
+		formattedValues = append(formattedValues, fmt.Sprintf("%#v", value))
+	}
+
+	return strings.Join(formattedValues, ", ")
+}
+
+func checkMatcherValues(field protoreflect.FieldDescriptor, values []interface{}) error {
+	if len(values) == 0 {
+		return errors.New("at least one value must be provided")
+	}
+
+	for _, value := range values {
+		if err := checkMatcherValue(field, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkMatcherValue(field protoreflect.FieldDescriptor, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		if field.Kind() == protoreflect.EnumKind {
+			if field.Enum().Values().ByName(protoreflect.Name(v)) == nil {
+				return fmt.Errorf("'%s' is not a value of enum %s", v, field.Enum().FullName())
+			}
+			return nil
+		}
+		if field.Kind() == protoreflect.StringKind || field.Kind() == protoreflect.BytesKind {
+			return nil
+		}
+	case bool:
+		if field.Kind() == protoreflect.BoolKind {
+			return nil
+		}
+	case int, int64, uint64, float64:
+		if isNumeric(field) || field.Kind() == protoreflect.EnumKind {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("value %v can't be used with a %s field", value, field.Kind())
+}
+
+func isStringLike(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.StringKind || field.Kind() == protoreflect.EnumKind
+}
+
+func isNumeric(field protoreflect.FieldDescriptor) bool {
+	switch field.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind,
+		protoreflect.FloatKind, protoreflect.DoubleKind:
+		return true
+	default:
+		return false
+	}
+}
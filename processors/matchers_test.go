@@ -0,0 +1,291 @@
+package processors_test
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestFormatRequestMatchers(t *testing.T) {
+	t.Parallel()
+
+	minValue := 1.0
+	maxValue := 10.5
+
+	tests := []struct {
+		name           string
+		matchers       map[string]entities.FieldMatcher
+		expectedFormat string
+	}{
+		{
+			name:           "should format correctly when there are no matchers",
+			matchers:       nil,
+			expectedFormat: "",
+		},
+		{
+			name: "should format correctly when using present",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField": {Present: true},
+			},
+			expectedFormat: `Present("simpleMessageField")`,
+		},
+		{
+			name: "should format correctly when using any",
+			matchers: map[string]entities.FieldMatcher{
+				"mapField": {Any: true},
+			},
+			expectedFormat: `Any("mapField")`,
+		},
+		{
+			name: "should format correctly when using regex in a nested field",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Regex: "^[a-z]+$"},
+			},
+			expectedFormat: `Regex("simpleMessageField.stringField", "^[a-z]+$")`,
+		},
+		{
+			name: "should format correctly when using prefix",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Prefix: "abc"},
+			},
+			expectedFormat: `Prefix("simpleMessageField.stringField", "abc")`,
+		},
+		{
+			name: "should format correctly when using range",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.intField": {
+					Range: &entities.NumericRange{Min: &minValue, Max: &maxValue},
+				},
+			},
+			expectedFormat: `Range("simpleMessageField.intField", 1, 10.5)`,
+		},
+		{
+			name: "should format correctly when using an open range",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.intField": {
+					Range: &entities.NumericRange{Min: &minValue},
+				},
+			},
+			expectedFormat: `Range("simpleMessageField.intField", 1, Inf(1))`,
+		},
+		{
+			name: "should format correctly when using oneOf with an enum",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {OneOf: []interface{}{"ONE", "TWO"}},
+			},
+			expectedFormat: `OneOf("enumField", "ONE", "TWO")`,
+		},
+		{
+			name: "should format correctly when using subsetOf",
+			matchers: map[string]entities.FieldMatcher{
+				"stringListField": {SubsetOf: []interface{}{"first", "second"}},
+			},
+			expectedFormat: `SubsetOf("stringListField", "first", "second")`,
+		},
+		{
+			name: "should sort the matchers by path",
+			matchers: map[string]entities.FieldMatcher{
+				"stringListField": {Any: true},
+				"enumField":       {Any: true},
+			},
+			expectedFormat: `Any("enumField"), Any("stringListField")`,
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualMatchers, err := processors.FormatRequestMatchers(
+				identFunc,
+				protoFields.getMessage(t, "MessageWithComplexFields"),
+				test.matchers,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualFormat := strings.Join(actualMatchers, ", ")
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
+
+func TestFormatRequestMatchers_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		matchers      map[string]entities.FieldMatcher
+		expectedError string
+	}{
+		{
+			name: "should return an error when the field doesn't exist",
+			matchers: map[string]entities.FieldMatcher{
+				"unknownField": {Any: true},
+			},
+			expectedError: "field 'unknownField' not found in message MessageWithComplexFields",
+		},
+		{
+			name: "should return an error when traversing a non message field",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField.value": {Any: true},
+			},
+			expectedError: "field 'enumField' is not a message and can't be traversed by " +
+				"'enumField.value'",
+		},
+		{
+			name: "should return an error when no rule is provided",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {},
+			},
+			expectedError: "invalid matcher for 'enumField': no rule was provided",
+		},
+		{
+			name: "should return an error when more than one rule is provided",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {Any: true, Present: true},
+			},
+			expectedError: "invalid matcher for 'enumField': only one rule must be provided",
+		},
+		{
+			name: "should return an error when the regex is invalid",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Regex: "[a-z"},
+			},
+			expectedError: "invalid matcher for 'simpleMessageField.stringField': " +
+				"error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			name: "should return an error when using range with a non numeric field",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Range: &entities.NumericRange{}},
+			},
+			expectedError: "invalid matcher for 'simpleMessageField.stringField': " +
+				"range can only be used with singular numeric fields",
+		},
+		{
+			name: "should return an error when using subsetOf with a singular field",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {SubsetOf: []interface{}{"ONE"}},
+			},
+			expectedError: "invalid matcher for 'enumField': " +
+				"subsetOf can only be used with repeated fields",
+		},
+		{
+			name: "should return an error when an enum value doesn't exist",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {OneOf: []interface{}{"THREE"}},
+			},
+			expectedError: "invalid matcher for 'enumField': " +
+				"'THREE' is not a value of enum EnumNumbers",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := processors.FormatRequestMatchers(
+				identFunc,
+				protoFields.getMessage(t, "MessageWithComplexFields"),
+				test.matchers,
+			)
+
+			if err == nil {
+				t.Fatal("expected an error but none was returned")
+			}
+
+			if err.Error() != test.expectedError {
+				t.Fatalf(`wrong error, given: "%s" expected: "%s"`, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewRequestMatchers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		matchers      map[string]entities.FieldMatcher
+		request       string
+		expectedMatch bool
+		expectedError string
+	}{
+		{
+			name: "should match when the matcher accepts the field",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Prefix: "ab"},
+			},
+			request:       `{"simpleMessageField": {"stringField": "abc"}}`,
+			expectedMatch: true,
+		},
+		{
+			name: "should not match when the matcher rejects the field",
+			matchers: map[string]entities.FieldMatcher{
+				"simpleMessageField.stringField": {Prefix: "ab"},
+			},
+			request:       `{"simpleMessageField": {"stringField": "xyz"}}`,
+			expectedMatch: false,
+		},
+		{
+			name: "should return the same errors as the formatted matchers",
+			matchers: map[string]entities.FieldMatcher{
+				"enumField": {OneOf: []interface{}{"THREE"}},
+			},
+			expectedError: "invalid matcher for 'enumField': " +
+				"'THREE' is not a value of enum EnumNumbers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			descriptor := protoFields.getMessage(t, "MessageWithComplexFields").Desc
+
+			matchers, err := processors.NewRequestMatchers(descriptor, test.matchers)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			request := dynamicpb.NewMessage(descriptor)
+			if err = protojson.Unmarshal([]byte(test.request), request); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// The field is different, but it's covered by the matcher
+			expected := dynamicpb.NewMessage(descriptor)
+			expectedJSON := `{"simpleMessageField": {"stringField": "ab"}}`
+			if err = protojson.Unmarshal([]byte(expectedJSON), expected); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actualMatch := runtime.MatchRequest(request, expected, matchers...)
+			if actualMatch != test.expectedMatch {
+				t.Errorf("Wrong match, given: %v expected: %v", actualMatch, test.expectedMatch)
+			}
+		})
+	}
+}
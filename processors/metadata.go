@@ -0,0 +1,75 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const metadataPackage = protogen.GoImportPath("google.golang.org/grpc/metadata")
+
+// metadataKeyExpression follows the characters accepted by gRPC in header names.
+var metadataKeyExpression = regexp.MustCompile(`^[0-9a-z_.-]+$`)
+
+// FormatMetadata validates the metadata defined in the contract and converts it to the
+// metadata.MD that represents it. The keys are sorted, so the generated code is always
+// the same, and "nil" is returned when there's no metadata.
+func FormatMetadata(identFunc IdentFunc, md map[string]string) (string, error) {
+	if len(md) == 0 {
+		return "nil", nil
+	}
+
+	keys, err := metadataKeys(md)
+	if err != nil {
+		return "", err
+	}
+
+	pairs := make([]string, 0, len(md))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%q: %q", key, md[key]))
+	}
+
+	return fmt.Sprintf(
+		"%s(map[string]string{%s})",
+		identFunc(metadataPackage.Ident("New")),
+		strings.Join(pairs, ", "),
+	), nil
+}
+
+// NewMetadata is the same as FormatMetadata, but the metadata.MD is built instead of
+// formatted, nil is returned when there's no metadata.
+func NewMetadata(md map[string]string) (metadata.MD, error) {
+	if len(md) == 0 {
+		return nil, nil
+	}
+
+	if _, err := metadataKeys(md); err != nil {
+		return nil, err
+	}
+
+	return metadata.New(md), nil
+}
+
+// metadataKeys validates the keys of the metadata, returning them sorted.
+func metadataKeys(md map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !metadataKeyExpression.MatchString(key) {
+			return nil, fmt.Errorf("invalid metadata key '%s'", key)
+		}
+		if strings.HasPrefix(key, "grpc-") {
+			return nil, fmt.Errorf("metadata key '%s' is reserved by gRPC", key)
+		}
+	}
+
+	return keys, nil
+}
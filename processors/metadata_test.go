@@ -0,0 +1,127 @@
+package processors_test
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestFormatMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		metadata       map[string]string
+		expectedFormat string
+		expectedError  string
+	}{
+		{
+			name:           "should format correctly when there's no metadata",
+			metadata:       nil,
+			expectedFormat: "nil",
+		},
+		{
+			name:           "should format correctly with a single key",
+			metadata:       map[string]string{"x-tenant-id": "acme"},
+			expectedFormat: `New(map[string]string{"x-tenant-id": "acme"})`,
+		},
+		{
+			name: "should sort the keys",
+			metadata: map[string]string{
+				"x-tenant-id":   "acme",
+				"authorization": "Bearer token",
+			},
+			expectedFormat: `New(map[string]string{"authorization": "Bearer token", ` +
+				`"x-tenant-id": "acme"})`,
+		},
+		{
+			name:          "should return an error when the key has invalid characters",
+			metadata:      map[string]string{"X-Tenant": "acme"},
+			expectedError: "invalid metadata key 'X-Tenant'",
+		},
+		{
+			name:          "should return an error when the key is reserved",
+			metadata:      map[string]string{"grpc-status": "0"},
+			expectedError: "metadata key 'grpc-status' is reserved by gRPC",
+		},
+	}
+
+	identFunc := func(ident protogen.GoIdent) string {
+		return ident.GoName
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualFormat, err := processors.FormatMetadata(identFunc, test.metadata)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
+
+func TestNewMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		metadata         map[string]string
+		expectedMetadata metadata.MD
+		expectedError    string
+	}{
+		{
+			name:             "should return nil when there's no metadata",
+			metadata:         nil,
+			expectedMetadata: nil,
+		},
+		{
+			name:             "should build the metadata",
+			metadata:         map[string]string{"x-tenant-id": "acme"},
+			expectedMetadata: metadata.Pairs("x-tenant-id", "acme"),
+		},
+		{
+			name:          "should return an error when the key is reserved",
+			metadata:      map[string]string{"grpc-status": "0"},
+			expectedError: "metadata key 'grpc-status' is reserved by gRPC",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualMetadata, err := processors.NewMetadata(test.metadata)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actualMetadata, test.expectedMetadata) {
+				t.Errorf(
+					"Wrong metadata, given: %v expected %v",
+					actualMetadata, test.expectedMetadata,
+				)
+			}
+		})
+	}
+}
@@ -18,3 +18,17 @@ func MakeExportedName(name string) string {
 		name[1:],
 	)
 }
+
+// MakeUnexportedName transforms any string in a Go's unexported name,
+// it's the opposite of MakeExportedName.
+func MakeUnexportedName(name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"%s%s",
+		strings.ToLower(name[:1]),
+		name[1:],
+	)
+}
@@ -48,3 +48,46 @@ func TestMakeExportedName(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeUnexportedName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		testName     string
+		name         string
+		expectedName string
+	}{
+		{
+			testName:     "should lower the first letter to unexport name",
+			name:         "SomeName",
+			expectedName: "someName",
+		},
+		{
+			testName:     "should do nothing when name is already unexported",
+			name:         "someName",
+			expectedName: "someName",
+		},
+		{
+			testName:     "should work when the string has length equal to one",
+			name:         "S",
+			expectedName: "s",
+		},
+		{
+			testName:     "should work when the string has length equal to zero",
+			name:         "",
+			expectedName: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			actualName := processors.MakeUnexportedName(test.name)
+			if actualName != test.expectedName {
+				t.Errorf(
+					"Wrong unexported name formatting, given: %s, expected: %s",
+					actualName, test.expectedName,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/proto/deal/v1/contract"
+)
+
+// MergeOptions merges the contracts written through the `deal.v1.contract` service and
+// method options of the file. Case files referenced by the options are relative to the
+// proto file, which is looked up inside each one of the protoPaths, like protoc does.
+func (m *ContractMerger) MergeOptions(file *protogen.File, protoPaths []string) error {
+	protoDir := filepath.Dir(file.Desc.Path())
+
+	for _, service := range file.Services {
+		serviceName := string(service.Desc.FullName())
+		serviceOption := getServiceOption(service)
+
+		methods := make(entities.Service)
+		source, err := readOption(
+			declarationSource(file, service.Desc), protoDir, protoPaths,
+			serviceOption.GetMethods(), serviceOption.GetFile(), &methods,
+		)
+		if err != nil {
+			return fmt.Errorf("invalid contract option of %s: %w", service.Desc.FullName(), err)
+		}
+		if source != "" {
+			err = m.Merge(source, entities.Contract{
+				Services: map[string]entities.Service{serviceName: methods},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, method := range service.Methods {
+			methodOption := getMethodOption(method)
+
+			var cases entities.Method
+			source, err = readOption(
+				declarationSource(file, method.Desc), protoDir, protoPaths,
+				methodOption.GetCases(), methodOption.GetFile(), &cases,
+			)
+			if err != nil {
+				return fmt.Errorf(
+					"invalid contract option of %s: %w", method.Desc.FullName(), err,
+				)
+			}
+			if source == "" {
+				continue
+			}
+
+			err = m.Merge(source, entities.Contract{
+				Services: map[string]entities.Service{
+					serviceName: {method.GoName: cases},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getServiceOption returns the contract option of the service, or nil when it isn't set.
+func getServiceOption(service *protogen.Service) *contract.ServiceContract {
+	option, ok := proto.GetExtension(
+		service.Desc.Options(), contract.E_Service,
+	).(*contract.ServiceContract)
+	if !ok {
+		return nil
+	}
+
+	return option
+}
+
+// getMethodOption returns the contract option of the method, or nil when it isn't set.
+func getMethodOption(method *protogen.Method) *contract.MethodContract {
+	option, ok := proto.GetExtension(
+		method.Desc.Options(), contract.E_Method,
+	).(*contract.MethodContract)
+	if !ok {
+		return nil
+	}
+
+	return option
+}
+
+// readOption parses either the inline cases or the referenced case file into `out`, it
+// returns where the cases came from, or an empty string when the option isn't set.
+func readOption(
+	declaration entities.Source,
+	protoDir string,
+	protoPaths []string,
+	inline string,
+	file string,
+	out interface{},
+) (string, error) {
+	switch {
+	case inline != "" && file != "":
+		return "", errors.New("the inline cases and 'file' can't be set together")
+	case inline != "":
+		// YAML is a superset of JSON, so both formats are accepted
+		if err := yaml.Unmarshal([]byte(inline), out); err != nil {
+			return "", err
+		}
+
+		setInlineSources(out, declaration)
+
+		return declaration.File, nil
+	case file != "":
+		filePath, err := findOptionFile(filepath.Join(protoDir, file), protoPaths)
+		if err != nil {
+			return "", err
+		}
+
+		if err = readContractData(filePath, out); err != nil {
+			return "", fmt.Errorf("failed to read '%s': %w", filePath, err)
+		}
+
+		return filePath, nil
+	default:
+		return "", nil
+	}
+}
+
+func findOptionFile(relativePath string, protoPaths []string) (string, error) {
+	for _, protoPath := range protoPaths {
+		filePath := filepath.Join(protoPath, relativePath)
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, nil
+		}
+	}
+
+	return "", fmt.Errorf("case file '%s' not found in %v", relativePath, protoPaths)
+}
@@ -0,0 +1,189 @@
+package processors_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/proto/deal/v1/contract"
+)
+
+// newOptionsFile returns a proto file with a single service and method using the
+// given contract options.
+func newOptionsFile(
+	t *testing.T,
+	serviceOption *contract.ServiceContract,
+	methodOption *contract.MethodContract,
+) *protogen.File {
+	t.Helper()
+
+	serviceOptions := &descriptorpb.ServiceOptions{}
+	if serviceOption != nil {
+		proto.SetExtension(serviceOptions, contract.E_Service, serviceOption)
+	}
+
+	methodOptions := &descriptorpb.MethodOptions{}
+	if methodOption != nil {
+		proto.SetExtension(methodOptions, contract.E_Method, methodOption)
+	}
+
+	return newServiceFiles(t, newServiceFile("example", serviceOptions, methodOptions))[0]
+}
+
+// newServiceFile returns the descriptor of a file from the given package, holding the
+// MyService service with a single method.
+func newServiceFile(
+	packageName string,
+	serviceOptions *descriptorpb.ServiceOptions,
+	methodOptions *descriptorpb.MethodOptions,
+) *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(fmt.Sprintf("%s/service.proto", packageName)),
+		Package: proto.String(packageName),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String(fmt.Sprintf("example.com/%s", packageName)),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("RequestMessage")},
+			{Name: proto.String("ResponseMessage")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:    proto.String("MyService"),
+			Options: serviceOptions,
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("MyMethod"),
+				InputType:  proto.String(fmt.Sprintf(".%s.RequestMessage", packageName)),
+				OutputType: proto.String(fmt.Sprintf(".%s.ResponseMessage", packageName)),
+				Options:    methodOptions,
+			}},
+		}},
+	}
+}
+
+func newServiceFiles(t *testing.T, files ...*descriptorpb.FileDescriptorProto) []*protogen.File {
+	t.Helper()
+
+	fileNames := make([]string, 0, len(files))
+	for _, file := range files {
+		fileNames = append(fileNames, file.GetName())
+	}
+
+	plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: fileNames,
+		ProtoFile:      files,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return plugin.Files
+}
+
+func TestContractMerger_MergeOptions(t *testing.T) {
+	t.Parallel()
+
+	file := newOptionsFile(
+		t,
+		&contract.ServiceContract{File: "cases.yml"},
+		&contract.MethodContract{
+			Cases: `{"successCases": [
+				{"description": "Should return the shared value",
+				 "request": {"requestField": "shared"}, "response": {"responseField": 42}},
+				{"description": "Should return the inline value",
+				 "request": {"requestField": "inline"}, "response": {"responseField": 3}}
+			]}`,
+		},
+	)
+
+	merger := processors.NewContractMerger([]*protogen.File{file})
+	if err := merger.MergeFiles([]string{"testdata/contracts/billing.yml"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := merger.MergeOptions(file, []string{"testdata", "testdata/options"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The short name used by the contract file is resolved to the proto service
+	method := merger.Contract().Services["example.MyService"]["MyMethod"]
+
+	successDescriptions := make([]string, 0, len(method.SuccessCases))
+	for _, successCase := range method.SuccessCases {
+		successDescriptions = append(successDescriptions, successCase.Description)
+	}
+
+	expectedDescriptions := []string{
+		"Should return the invoice",
+		"Should return the shared value",
+		"Should return the value from the case file",
+		"Should return the inline value",
+	}
+	if !reflect.DeepEqual(successDescriptions, expectedDescriptions) {
+		t.Errorf(
+			"Wrong success cases, given: %v expected: %v",
+			successDescriptions, expectedDescriptions,
+		)
+	}
+}
+
+func TestContractMerger_MergeOptions_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		serviceOption *contract.ServiceContract
+		methodOption  *contract.MethodContract
+		expectedError string
+	}{
+		{
+			name:          "should return an error when the case file doesn't exist",
+			serviceOption: &contract.ServiceContract{File: "unknown.yml"},
+			expectedError: "invalid contract option of example.MyService: " +
+				"case file 'example/unknown.yml' not found in [testdata/options]",
+		},
+		{
+			name: "should return an error when both inline cases and file are set",
+			methodOption: &contract.MethodContract{
+				Cases: "successCases: []",
+				File:  "cases.yml",
+			},
+			expectedError: "invalid contract option of example.MyService.MyMethod: " +
+				"the inline cases and 'file' can't be set together",
+		},
+		{
+			name:          "should return an error when the cases conflict",
+			serviceOption: &contract.ServiceContract{File: "cases.yml"},
+			methodOption: &contract.MethodContract{
+				Cases: `{"successCases": [
+					{"description": "Should return another value",
+					 "request": {"requestField": "file"}, "response": {"responseField": 7}}
+				]}`,
+			},
+			expectedError: "conflicting cases for example.MyService.MyMethod: " +
+				"'Should return the value from the case file' from " +
+				"'testdata/options/example/cases.yml:3:7' and " +
+				"'Should return another value' from 'example/service.proto' " +
+				"match the same request",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := newOptionsFile(t, test.serviceOption, test.methodOption)
+
+			merger := processors.NewContractMerger([]*protogen.File{file})
+			err := merger.MergeOptions(file, []string{"testdata/options"})
+			if err == nil || err.Error() != test.expectedError {
+				t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var (
+	_ protoregistry.MessageTypeResolver   = &MessageRegistry{}
+	_ protoregistry.ExtensionTypeResolver = &MessageRegistry{}
+)
+
+// MessageRegistry knows every message of the files received by the plugin, besides the
+// standard error details (google/rpc/error_details.proto), so the messages referenced by
+// their names in the contract, like the error details and the types packed in Any fields,
+// can be generated. It knows the extensions declared by the files as well.
+type MessageRegistry struct {
+	messages   map[protoreflect.FullName]*protogen.Message
+	extensions map[protoreflect.FullName]*protogen.Extension
+}
+
+// NewMessageRegistry creates a MessageRegistry holding the messages of the given files.
+func NewMessageRegistry(files []*protogen.File) (*MessageRegistry, error) {
+	errorDetailsFiles, err := errorDetailsFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the error details: %w", err)
+	}
+
+	registry := &MessageRegistry{
+		messages:   make(map[protoreflect.FullName]*protogen.Message),
+		extensions: make(map[protoreflect.FullName]*protogen.Extension),
+	}
+	for _, file := range append(files, errorDetailsFiles...) {
+		registry.addMessages(file.Messages)
+		registry.addExtensions(file.Extensions)
+	}
+
+	return registry, nil
+}
+
+func (r *MessageRegistry) addMessages(messages []*protogen.Message) {
+	for _, message := range messages {
+		// The files received by the plugin come first, so they're kept
+		if _, exists := r.messages[message.Desc.FullName()]; !exists {
+			r.messages[message.Desc.FullName()] = message
+		}
+
+		r.addMessages(message.Messages)
+		r.addExtensions(message.Extensions)
+	}
+}
+
+func (r *MessageRegistry) addExtensions(extensions []*protogen.Extension) {
+	for _, extension := range extensions {
+		if _, exists := r.extensions[extension.Desc.FullName()]; !exists {
+			r.extensions[extension.Desc.FullName()] = extension
+		}
+	}
+}
+
+// FindMessage returns the message with the given full name, e.g. google.rpc.ErrorInfo.
+func (r *MessageRegistry) FindMessage(name protoreflect.FullName) (*protogen.Message, error) {
+	message, exists := r.messages[name]
+	if !exists {
+		return nil, fmt.Errorf("message %s not found", name)
+	}
+
+	return message, nil
+}
+
+// FindMessageByName implements protoregistry.MessageTypeResolver, the returned types are
+// dynamic, so they're able to parse the values written in the contract.
+func (r *MessageRegistry) FindMessageByName(
+	name protoreflect.FullName,
+) (protoreflect.MessageType, error) {
+	message, exists := r.messages[name]
+	if !exists {
+		return nil, protoregistry.NotFound
+	}
+
+	return dynamicpb.NewMessageType(message.Desc), nil
+}
+
+// FindMessageByURL implements protoregistry.MessageTypeResolver, only the part after the
+// last slash of the URL is used, e.g. type.googleapis.com/google.rpc.ErrorInfo.
+func (r *MessageRegistry) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+// FindExtension returns the extension with the given full name, e.g. acme.v1.legacy_id.
+func (r *MessageRegistry) FindExtension(
+	name protoreflect.FullName,
+) (*protogen.Extension, error) {
+	extension, exists := r.extensions[name]
+	if !exists {
+		return nil, fmt.Errorf("extension %s not found", name)
+	}
+
+	return extension, nil
+}
+
+// FindExtensionByName implements protoregistry.ExtensionTypeResolver, the returned types
+// are dynamic like the ones returned by FindMessageByName.
+func (r *MessageRegistry) FindExtensionByName(
+	name protoreflect.FullName,
+) (protoreflect.ExtensionType, error) {
+	extension, exists := r.extensions[name]
+	if !exists {
+		return nil, protoregistry.NotFound
+	}
+
+	return dynamicpb.NewExtensionType(extension.Desc), nil
+}
+
+// FindExtensionByNumber implements protoregistry.ExtensionTypeResolver.
+func (r *MessageRegistry) FindExtensionByNumber(
+	message protoreflect.FullName,
+	number protoreflect.FieldNumber,
+) (protoreflect.ExtensionType, error) {
+	for _, extension := range r.extensions {
+		if extension.Desc.ContainingMessage().FullName() == message &&
+			extension.Desc.Number() == number {
+			return dynamicpb.NewExtensionType(extension.Desc), nil
+		}
+	}
+
+	return nil, protoregistry.NotFound
+}
+
+// errorDetailsFiles returns the protogen version of google/rpc/error_details.proto,
+// as the plugin only receives it when it's imported by the compiled files.
+func errorDetailsFiles() ([]*protogen.File, error) {
+	descriptors := []protoreflect.FileDescriptor{
+		durationpb.File_google_protobuf_duration_proto,
+		errdetails.File_google_rpc_error_details_proto,
+	}
+
+	request := &pluginpb.CodeGeneratorRequest{}
+	for _, descriptor := range descriptors {
+		request.ProtoFile = append(request.ProtoFile, protodesc.ToFileDescriptorProto(descriptor))
+	}
+
+	plugin, err := protogen.Options{}.New(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin.Files, nil
+}
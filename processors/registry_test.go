@@ -0,0 +1,168 @@
+package processors_test
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestMessageRegistry_FindMessage(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		messageName   protoreflect.FullName
+		expectedIdent string
+		expectedError string
+	}{
+		{
+			name:          "should find a message from the plugin files",
+			messageName:   "SimpleMessage",
+			expectedIdent: "SimpleMessage",
+		},
+		{
+			name:          "should find an error detail",
+			messageName:   "google.rpc.ErrorInfo",
+			expectedIdent: "ErrorInfo",
+		},
+		{
+			name:          "should find a nested error detail",
+			messageName:   "google.rpc.BadRequest.FieldViolation",
+			expectedIdent: "BadRequest_FieldViolation",
+		},
+		{
+			name:          "should return an error when the message doesn't exist",
+			messageName:   "google.rpc.Unknown",
+			expectedError: "message google.rpc.Unknown not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, err := registry.FindMessage(test.messageName)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if message.GoIdent.GoName != test.expectedIdent {
+				t.Errorf(
+					"Wrong message, given: %s expected %s",
+					message.GoIdent.GoName, test.expectedIdent,
+				)
+			}
+		})
+	}
+}
+
+func TestMessageRegistry_FindMessageByURL(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	messageType, err := registry.FindMessageByURL("type.googleapis.com/google.rpc.RetryInfo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if name := messageType.Descriptor().FullName(); name != "google.rpc.RetryInfo" {
+		t.Errorf("Wrong message, given: %s expected google.rpc.RetryInfo", name)
+	}
+}
+
+func TestMessageRegistry_FindExtension(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		extensionName protoreflect.FullName
+		expectedIdent string
+		expectedError string
+	}{
+		{
+			name:          "should find an extension declared in the file",
+			extensionName: "legacy.label",
+			expectedIdent: "Label",
+		},
+		{
+			name:          "should find an extension declared in a message",
+			extensionName: "legacy.Note.note",
+			expectedIdent: "Note_Note",
+		},
+		{
+			name:          "should return an error when the extension doesn't exist",
+			extensionName: "legacy.unknown",
+			expectedError: "extension legacy.unknown not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extension, err := registry.FindExtension(test.extensionName)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if extension.GoIdent.GoName != test.expectedIdent {
+				t.Errorf(
+					"Wrong extension, given: %s expected %s",
+					extension.GoIdent.GoName, test.expectedIdent,
+				)
+			}
+		})
+	}
+}
+
+func TestMessageRegistry_FindExtensionByNumber(t *testing.T) {
+	t.Parallel()
+
+	registry, err := processors.NewMessageRegistry(protoFields.plugin.Files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	extensionType, err := registry.FindExtensionByNumber(
+		"legacy.LegacyMessage", 102, //nolint:revive // extension number
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if name := extensionType.TypeDescriptor().FullName(); name != "legacy.priority" {
+		t.Errorf("Wrong extension, given: %s expected legacy.priority", name)
+	}
+
+	_, err = registry.FindExtensionByNumber(
+		"legacy.LegacyMessage", 199, //nolint:revive // extension number
+	)
+	if !errors.Is(err, protoregistry.NotFound) {
+		t.Errorf("Wrong error, given: %v expected: %v", err, protoregistry.NotFound)
+	}
+}
@@ -0,0 +1,80 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+// scenarioOrigin tells where a merged scenario came from, the steps are kept as JSON
+// so the same scenario repeated across the sources can be found.
+type scenarioOrigin struct {
+	source string
+	steps  string
+}
+
+// mergeScenarios adds the scenarios to the merged contract, a scenario repeated across
+// the sources is kept only once, while different scenarios with the same name are
+// reported as conflicts.
+func (m *ContractMerger) mergeScenarios(source string, scenarios []entities.Scenario) error {
+	for _, scenario := range scenarios {
+		if scenario.Name == "" {
+			return fmt.Errorf("invalid contract '%s': scenarios must have a name", source)
+		}
+		if scenario.Service == "" {
+			return fmt.Errorf(
+				"invalid contract '%s': scenario '%s' must have a service", source, scenario.Name,
+			)
+		}
+
+		for i, step := range scenario.Steps {
+			if step.Method == "" {
+				return fmt.Errorf(
+					"invalid contract '%s': step %d of scenario '%s' must have a method",
+					source, i, scenario.Name,
+				)
+			}
+		}
+
+		serviceName, err := m.serviceKey(scenario.Service)
+		if err != nil {
+			return fmt.Errorf("invalid contract '%s': %w", source, err)
+		}
+		scenario.Service = serviceName
+
+		steps, err := json.Marshal(scenario.Steps)
+		if err != nil {
+			return fmt.Errorf("invalid scenario '%s': %w", scenario.Name, err)
+		}
+
+		key := fmt.Sprintf("%s/%s", serviceName, scenario.Name)
+		if registered, exists := m.scenarios[key]; exists {
+			if registered.steps == string(steps) {
+				continue
+			}
+
+			return fmt.Errorf(
+				"conflicting scenarios for %s: '%s' from '%s' and '%s' have different steps",
+				serviceName, scenario.Name, registered.source, source,
+			)
+		}
+
+		m.scenarios[key] = scenarioOrigin{source: source, steps: string(steps)}
+		m.contract.Scenarios = append(m.contract.Scenarios, scenario)
+	}
+
+	return nil
+}
+
+// ServiceScenarios returns the scenarios of the given service, in the order they were merged.
+func ServiceScenarios(contract entities.Contract, serviceName string) []entities.Scenario {
+	scenarios := make([]entities.Scenario, 0)
+	for _, scenario := range contract.Scenarios {
+		if scenario.Service == serviceName {
+			scenarios = append(scenarios, scenario)
+		}
+	}
+
+	return scenarios
+}
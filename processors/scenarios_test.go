@@ -0,0 +1,112 @@
+package processors_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestContractMerger_MergeScenarios(t *testing.T) {
+	t.Parallel()
+
+	lifecycle := entities.Scenario{
+		Name:    "Lifecycle",
+		Service: "MyService",
+		Steps: []entities.ScenarioStep{
+			{Method: "MyMethod", State: "Started", NextState: "Created"},
+			{Method: "MyMethod", State: "Created"},
+		},
+	}
+
+	changedLifecycle := lifecycle
+	changedLifecycle.Steps = []entities.ScenarioStep{{Method: "MyMethod"}}
+
+	tests := []struct {
+		name              string
+		contracts         []entities.Contract
+		expectedScenarios []string
+		expectedError     string
+	}{
+		{
+			name: "should merge the scenarios resolving their services",
+			contracts: []entities.Contract{
+				{Scenarios: []entities.Scenario{lifecycle}},
+			},
+			expectedScenarios: []string{"acme.users.v1.MyService/Lifecycle"},
+		},
+		{
+			name: "should keep a scenario repeated across the contracts only once",
+			contracts: []entities.Contract{
+				{Scenarios: []entities.Scenario{lifecycle}},
+				{Scenarios: []entities.Scenario{lifecycle}},
+			},
+			expectedScenarios: []string{"acme.users.v1.MyService/Lifecycle"},
+		},
+		{
+			name: "should return an error when scenarios with the same name have different steps",
+			contracts: []entities.Contract{
+				{Scenarios: []entities.Scenario{lifecycle}},
+				{Scenarios: []entities.Scenario{changedLifecycle}},
+			},
+			expectedError: "conflicting scenarios for acme.users.v1.MyService: " +
+				"'Lifecycle' from 'contract.yml' and 'contract.yml' have different steps",
+		},
+		{
+			name: "should return an error when the scenario has no service",
+			contracts: []entities.Contract{
+				{Scenarios: []entities.Scenario{{Name: "Lifecycle"}}},
+			},
+			expectedError: "invalid contract 'contract.yml': " +
+				"scenario 'Lifecycle' must have a service",
+		},
+		{
+			name: "should return an error when a step has no method",
+			contracts: []entities.Contract{
+				{Scenarios: []entities.Scenario{{
+					Name:    "Lifecycle",
+					Service: "MyService",
+					Steps:   []entities.ScenarioStep{{State: "Started"}},
+				}}},
+			},
+			expectedError: "invalid contract 'contract.yml': " +
+				"step 0 of scenario 'Lifecycle' must have a method",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merger := processors.NewContractMerger(
+				newServiceFiles(t, newServiceFile("acme.users.v1", nil, nil)),
+			)
+
+			var err error
+			for _, contract := range test.contracts {
+				if err = merger.Merge("contract.yml", contract); err != nil {
+					break
+				}
+			}
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			scenarios := make([]string, 0)
+			for _, scenario := range merger.Contract().Scenarios {
+				scenarios = append(scenarios, scenario.Service+"/"+scenario.Name)
+			}
+
+			if !reflect.DeepEqual(scenarios, test.expectedScenarios) {
+				t.Errorf(
+					"Wrong scenarios, given: %v expected: %v", scenarios, test.expectedScenarios,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package processors
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+// setSources fills the source of every case parsed into `out` from the given data, the
+// positions come from the YAML nodes since JSON is valid YAML as well. When the positions
+// can't be found only the file is set.
+func setSources(out interface{}, data []byte, filePath string) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		document = yaml.Node{Content: []*yaml.Node{nil}}
+	}
+	root := document.Content[0]
+
+	switch value := out.(type) {
+	case *entities.Contract:
+		services := mappingValue(root, "services")
+		for serviceName, service := range value.Services {
+			setServiceSources(service, mappingValue(services, serviceName), filePath)
+		}
+
+		scenarios := mappingValue(root, "scenarios")
+		for i := range value.Scenarios {
+			steps := mappingValue(sequenceItem(scenarios, i), "steps")
+			for j := range value.Scenarios[i].Steps {
+				value.Scenarios[i].Steps[j].Source = nodeSource(sequenceItem(steps, j), filePath)
+			}
+		}
+	case *entities.Service:
+		setServiceSources(*value, root, filePath)
+	case *entities.Method:
+		setMethodSources(value, root, filePath)
+	}
+}
+
+// setInlineSources sets the same source to every case parsed into `out`, it's used when
+// the cases are written inside the proto files, so they point to the proto declaration.
+func setInlineSources(out interface{}, source entities.Source) {
+	switch value := out.(type) {
+	case *entities.Service:
+		for methodName, method := range *value {
+			setMethodSource(&method, source)
+			(*value)[methodName] = method
+		}
+	case *entities.Method:
+		setMethodSource(value, source)
+	}
+}
+
+// declarationSource returns where the descriptor was declared inside the proto file.
+func declarationSource(file *protogen.File, descriptor protoreflect.Descriptor) entities.Source {
+	location := file.Desc.SourceLocations().ByDescriptor(descriptor)
+	if location.Path == nil {
+		return entities.Source{File: file.Desc.Path()}
+	}
+
+	// Source locations are zero-based, while editors count from one
+	return entities.Source{
+		File:   file.Desc.Path(),
+		Line:   location.StartLine + 1,
+		Column: location.StartColumn + 1,
+	}
+}
+
+func setMethodSource(method *entities.Method, source entities.Source) {
+	for i := range method.SuccessCases {
+		method.SuccessCases[i].Source = source
+		method.SuccessCases[i].Source.Index = i
+	}
+	for i := range method.FailureCases {
+		method.FailureCases[i].Source = source
+		method.FailureCases[i].Source.Index = i
+	}
+}
+
+func setServiceSources(service entities.Service, node *yaml.Node, filePath string) {
+	for methodName, method := range service {
+		setMethodSources(&method, mappingValue(node, methodName), filePath)
+		service[methodName] = method
+	}
+}
+
+func setMethodSources(method *entities.Method, node *yaml.Node, filePath string) {
+	successCases := mappingValue(node, "successCases")
+	for i := range method.SuccessCases {
+		method.SuccessCases[i].Source = nodeSource(sequenceItem(successCases, i), filePath)
+		method.SuccessCases[i].Source.Index = i
+	}
+
+	failureCases := mappingValue(node, "failureCases")
+	for i := range method.FailureCases {
+		method.FailureCases[i].Source = nodeSource(sequenceItem(failureCases, i), filePath)
+		method.FailureCases[i].Source.Index = i
+	}
+}
+
+func nodeSource(node *yaml.Node, filePath string) entities.Source {
+	if node == nil {
+		return entities.Source{File: filePath}
+	}
+
+	return entities.Source{File: filePath, Line: node.Line, Column: node.Column}
+}
+
+// mappingValue returns the value of the key inside a mapping node, or nil when
+// the node isn't a mapping or it doesn't have the key.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	// The content of a mapping node alternates between keys and values
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func sequenceItem(node *yaml.Node, index int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+		return nil
+	}
+
+	return node.Content[index]
+}
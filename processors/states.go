@@ -0,0 +1,73 @@
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FormatStateParams converts the parameters of a provider state to the map literal given
+// to its handler. Nested maps and lists are kept, the keys are sorted so the generated code
+// is always the same. Numbers are given as float64 whatever the contract format, as JSON
+// decodes them.
+func FormatStateParams(params map[string]interface{}) (string, error) {
+	if params == nil {
+		return "nil", nil
+	}
+
+	return formatStateParamsMap(params)
+}
+
+func formatStateParamsMap(params map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(params))
+	for _, key := range keys {
+		value, err := formatStateParam(params[key])
+		if err != nil {
+			return "", fmt.Errorf("invalid param '%s': %w", key, err)
+		}
+
+		entries = append(entries, fmt.Sprintf("%q: %s", key, value))
+	}
+
+	return fmt.Sprintf("map[string]interface{}{%s}", strings.Join(entries, ", ")), nil
+}
+
+func formatStateParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "nil", nil
+	case string, bool:
+		return fmt.Sprintf("%#v", v), nil
+	case int:
+		return formatStateParam(float64(v))
+	case int64:
+		return formatStateParam(float64(v))
+	case uint64:
+		return formatStateParam(float64(v))
+	case float64:
+		// Without the conversion a whole number would reach the handler as an int
+		return fmt.Sprintf("float64(%#v)", v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			formattedItem, err := formatStateParam(item)
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, formattedItem)
+		}
+
+		return fmt.Sprintf("[]interface{}{%s}", strings.Join(items, ", ")), nil
+	case map[string]interface{}:
+		return formatStateParamsMap(v)
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,73 @@
+package processors_test
+
+import (
+	"testing"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestFormatStateParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		params         map[string]interface{}
+		expectedFormat string
+		expectedError  string
+	}{
+		{
+			name:           "should format correctly when there are no params",
+			params:         nil,
+			expectedFormat: "nil",
+		},
+		{
+			name:   "should sort the keys and keep the value types",
+			params: map[string]interface{}{"name": "book", "id": 42, "price": 9.5, "active": true},
+			expectedFormat: `map[string]interface{}{"active": true, "id": float64(42), ` +
+				`"name": "book", "price": float64(9.5)}`,
+		},
+		{
+			name: "should format every number as a float64",
+			params: map[string]interface{}{
+				"int": 7, "int64": int64(-7), "uint64": uint64(7), "float": 7.0,
+			},
+			expectedFormat: `map[string]interface{}{"float": float64(7), "int": float64(7), ` +
+				`"int64": float64(-7), "uint64": float64(7)}`,
+		},
+		{
+			name: "should format nested maps and lists",
+			params: map[string]interface{}{
+				"user": map[string]interface{}{"roles": []interface{}{"admin", nil}},
+			},
+			expectedFormat: `map[string]interface{}{"user": map[string]interface{}{` +
+				`"roles": []interface{}{"admin", nil}}}`,
+		},
+		{
+			name:          "should return an error when the value isn't supported",
+			params:        map[string]interface{}{"id": struct{}{}},
+			expectedError: "invalid param 'id': unsupported value {}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualFormat, err := processors.FormatStateParams(test.params)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actualFormat != test.expectedFormat {
+				t.Errorf(
+					"Wrong format, given: %s expected %s",
+					actualFormat, test.expectedFormat,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckUnmatched returns an error listing every service and method of the merged contract,
+// scenarios included, that doesn't match any service or method of the proto files, so a typo
+// doesn't leave a contract that is never verified. The closest names are suggested.
+func (m *ContractMerger) CheckUnmatched() error {
+	knownServices := make([]string, 0, len(m.methods)+len(m.shortNames))
+	for fullName := range m.methods {
+		knownServices = append(knownServices, fullName)
+	}
+	for shortName := range m.shortNames {
+		knownServices = append(knownServices, shortName)
+	}
+	sort.Strings(knownServices)
+
+	serviceNames := make([]string, 0, len(m.contract.Services))
+	for serviceName := range m.contract.Services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	unmatched := make([]string, 0)
+	for _, serviceName := range serviceNames {
+		knownMethods, exists := m.methods[serviceName]
+		if !exists {
+			unmatched = append(
+				unmatched,
+				fmt.Sprintf(
+					"service '%s' not found%s",
+					serviceName, suggestName(serviceName, knownServices),
+				),
+			)
+			continue
+		}
+
+		methodNames := make([]string, 0, len(m.contract.Services[serviceName]))
+		for methodName := range m.contract.Services[serviceName] {
+			methodNames = append(methodNames, methodName)
+		}
+		sort.Strings(methodNames)
+
+		for _, methodName := range methodNames {
+			if !containsName(knownMethods, methodName) {
+				unmatched = append(
+					unmatched,
+					fmt.Sprintf(
+						"method '%s' not found in %s%s",
+						methodName, serviceName, suggestName(methodName, knownMethods),
+					),
+				)
+			}
+		}
+	}
+
+	for _, scenario := range m.contract.Scenarios {
+		knownMethods, exists := m.methods[scenario.Service]
+		if !exists {
+			unmatched = append(
+				unmatched,
+				fmt.Sprintf(
+					"service '%s' of scenario '%s' not found%s",
+					scenario.Service, scenario.Name,
+					suggestName(scenario.Service, knownServices),
+				),
+			)
+			continue
+		}
+
+		for _, step := range scenario.Steps {
+			if !containsName(knownMethods, step.Method) {
+				unmatched = append(
+					unmatched,
+					fmt.Sprintf(
+						"method '%s' of scenario '%s' not found in %s%s",
+						step.Method, scenario.Name, scenario.Service,
+						suggestName(step.Method, knownMethods),
+					),
+				)
+			}
+		}
+	}
+
+	if len(unmatched) == 0 {
+		return nil
+	}
+
+	return errors.New(
+		"contract entries without a matching proto definition:\n  - " +
+			strings.Join(unmatched, "\n  - "),
+	)
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// suggestName returns a "did you mean" hint with the closest candidate, names are only
+// suggested when they're close enough to be a typo.
+func suggestName(name string, candidates []string) string {
+	closest := ""
+	closestDistance := len(name)/3 + 1
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < closestDistance {
+			closest = candidate
+			closestDistance = distance
+		}
+	}
+
+	if closest == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean '%s'?", closest)
+}
+
+// editDistance returns the Levenshtein distance between both strings.
+func editDistance(a, b string) int {
+	first, second := []rune(a), []rune(b)
+
+	previous := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(first); i++ {
+		current := make([]int, len(second)+1)
+		current[0] = i
+
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(second)]
+}
+
+func minInt(first int, others ...int) int {
+	result := first
+	for _, other := range others {
+		if other < result {
+			result = other
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package processors_test
+
+import (
+	"testing"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+func TestContractMerger_CheckUnmatched(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		services      map[string]entities.Service
+		expectedError string
+	}{
+		{
+			name: "should accept a contract matching the proto files",
+			services: map[string]entities.Service{
+				"MyService":               {"MyMethod": entities.Method{}},
+				"acme.users.v1.MyService": {"MyMethod": entities.Method{}},
+			},
+		},
+		{
+			name: "should list every unmatched service and method",
+			services: map[string]entities.Service{
+				"MyServce":     {"MyMethod": entities.Method{}},
+				"OtherService": {"MyMethod": entities.Method{}},
+				"acme.users.v1.MyService": {
+					"MyMetod":   entities.Method{},
+					"Unrelated": entities.Method{},
+					"MyMethod":  entities.Method{},
+				},
+			},
+			expectedError: "contract entries without a matching proto definition:\n" +
+				"  - service 'MyServce' not found, did you mean 'MyService'?\n" +
+				"  - service 'OtherService' not found\n" +
+				"  - method 'MyMetod' not found in acme.users.v1.MyService, " +
+				"did you mean 'MyMethod'?\n" +
+				"  - method 'Unrelated' not found in acme.users.v1.MyService",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := newServiceFiles(t, newServiceFile("acme.users.v1", nil, nil))
+
+			merger := processors.NewContractMerger(files)
+			err := merger.Merge("contract.yml", entities.Contract{Services: test.services})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = merger.CheckUnmatched()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	timePackage      = protogen.GoImportPath("time")
+	timestampPackage = protogen.GoImportPath("google.golang.org/protobuf/types/known/timestamppb")
+	durationPackage  = protogen.GoImportPath("google.golang.org/protobuf/types/known/durationpb")
+	wrappersPackage  = protogen.GoImportPath("google.golang.org/protobuf/types/known/wrapperspb")
+	structPackage    = protogen.GoImportPath("google.golang.org/protobuf/types/known/structpb")
+)
+
+// maxDurationSeconds is the largest number of seconds a time.Duration holds.
+const maxDurationSeconds = math.MaxInt64 / int64(time.Second)
+
+// wrapperConstructors maps each wrapper type to the wrapperspb function creating it.
+var wrapperConstructors = map[protoreflect.FullName]string{
+	"google.protobuf.DoubleValue": "Double",
+	"google.protobuf.FloatValue":  "Float",
+	"google.protobuf.Int64Value":  "Int64",
+	"google.protobuf.UInt64Value": "UInt64",
+	"google.protobuf.Int32Value":  "Int32",
+	"google.protobuf.UInt32Value": "UInt32",
+	"google.protobuf.BoolValue":   "Bool",
+	"google.protobuf.StringValue": "String",
+	"google.protobuf.BytesValue":  "Bytes",
+}
+
+// durationUnits are the time constants used to format durations, from the largest one.
+var durationUnits = []struct {
+	name string
+	unit time.Duration
+}{
+	{name: "Hour", unit: time.Hour},
+	{name: "Minute", unit: time.Minute},
+	{name: "Second", unit: time.Second},
+	{name: "Millisecond", unit: time.Millisecond},
+	{name: "Microsecond", unit: time.Microsecond},
+}
+
+// formatWellKnownType formats the well-known types through their constructors, so they read
+// like the JSON written in the contract, e.g. `timestamppb.New(time.Date(...))`. The bool
+// is false for any other message, which is formatted field by field.
+func formatWellKnownType(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
+	message protoreflect.Message,
+) (string, bool, error) {
+	fullName := message.Descriptor().FullName()
+	if constructor, isWrapper := wrapperConstructors[fullName]; isWrapper {
+		formattedValue, err := formatValue(identFunc, registry, nil, getField(message, "value"))
+		return fmt.Sprintf(
+			"%s(%s)",
+			identFunc(wrappersPackage.Ident(constructor)),
+			formattedValue,
+		), true, err
+	}
+
+	switch fullName {
+	case "google.protobuf.Timestamp":
+		return formatTimestamp(identFunc, message), true, nil
+	case "google.protobuf.Duration":
+		formattedDuration, ok := formatDuration(identFunc, message)
+		return formattedDuration, ok, nil
+	case "google.protobuf.Struct", "google.protobuf.ListValue", "google.protobuf.Value":
+		formattedStruct, err := formatStruct(identFunc, message)
+		return formattedStruct, true, err
+	case "google.protobuf.Any":
+		formattedAny, err := formatAny(identFunc, registry, message)
+		return formattedAny, true, err
+	default:
+		return "", false, nil
+	}
+}
+
+// formatAny formats the message packed in the Any, found through the registry by its
+// `@type`, e.g. `runtime.MustNewAny(&pkg.Msg{...})`.
+func formatAny(
+	identFunc IdentFunc,
+	registry *MessageRegistry,
+	message protoreflect.Message,
+) (string, error) {
+	typeURL := getField(message, "type_url").String()
+	if registry == nil {
+		return "", fmt.Errorf("can't resolve the type '%s' of google.protobuf.Any", typeURL)
+	}
+
+	messageType, err := registry.FindMessageByURL(typeURL)
+	if err != nil {
+		return "", fmt.Errorf("unknown type '%s' of google.protobuf.Any: %w", typeURL, err)
+	}
+
+	packedMessage := messageType.New()
+	err = proto.UnmarshalOptions{Resolver: registry}.Unmarshal(
+		getField(message, "value").Bytes(),
+		packedMessage.Interface(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("invalid google.protobuf.Any of type '%s': %w", typeURL, err)
+	}
+
+	protoMessage, err := registry.FindMessage(packedMessage.Descriptor().FullName())
+	if err != nil {
+		return "", err
+	}
+
+	formattedMessage, err := FormatMessageField(
+		identFunc,
+		registry,
+		protoMessage.GoIdent,
+		CreateFieldsByNumber(protoMessage.Fields),
+		packedMessage,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s(%s)",
+		identFunc(RuntimePackage.Ident("MustNewAny")),
+		formattedMessage,
+	), nil
+}
+
+func getField(message protoreflect.Message, name protoreflect.Name) protoreflect.Value {
+	return message.Get(message.Descriptor().Fields().ByName(name))
+}
+
+func formatTimestamp(identFunc IdentFunc, message protoreflect.Message) string {
+	timestamp := time.Unix(
+		getField(message, "seconds").Int(),
+		getField(message, "nanos").Int(),
+	).UTC()
+
+	return fmt.Sprintf(
+		"%s(%s(%d, %s, %d, %d, %d, %d, %d, %s))",
+		identFunc(timestampPackage.Ident("New")),
+		identFunc(timePackage.Ident("Date")),
+		timestamp.Year(),
+		identFunc(timePackage.Ident(timestamp.Month().String())),
+		timestamp.Day(),
+		timestamp.Hour(),
+		timestamp.Minute(),
+		timestamp.Second(),
+		timestamp.Nanosecond(),
+		identFunc(timePackage.Ident("UTC")),
+	)
+}
+
+// formatDuration formats the duration using the largest time unit dividing it, e.g.
+// `durationpb.New(3500 * time.Millisecond)`. Durations longer than a time.Duration
+// are not formatted.
+func formatDuration(identFunc IdentFunc, message protoreflect.Message) (string, bool) {
+	seconds := getField(message, "seconds").Int()
+	if seconds >= maxDurationSeconds || seconds <= -maxDurationSeconds {
+		return "", false
+	}
+
+	duration := time.Duration(seconds)*time.Second +
+		time.Duration(getField(message, "nanos").Int())
+	constructor := identFunc(durationPackage.Ident("New"))
+
+	if duration == 0 {
+		return fmt.Sprintf("%s(0)", constructor), true
+	}
+
+	for _, unit := range durationUnits {
+		if duration%unit.unit == 0 {
+			return fmt.Sprintf(
+				"%s(%d * %s)",
+				constructor,
+				duration/unit.unit,
+				identFunc(timePackage.Ident(unit.name)),
+			), true
+		}
+	}
+
+	return fmt.Sprintf(
+		"%s(%d * %s)",
+		constructor,
+		duration,
+		identFunc(timePackage.Ident("Nanosecond")),
+	), true
+}
+
+// formatStruct formats the Struct, ListValue and Value types from their JSON form, so the
+// generated code holds the same values written in the contract.
+func formatStruct(identFunc IdentFunc, message protoreflect.Message) (string, error) {
+	content, err := protojson.Marshal(message.Interface())
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err = json.Unmarshal(content, &value); err != nil {
+		return "", err
+	}
+
+	if message.Descriptor().FullName() == "google.protobuf.Value" {
+		return formatStructValue(identFunc, value)
+	}
+
+	return formatStructLiteral(identFunc, value)
+}
+
+// formatStructValue formats a Value through the structpb constructor of its kind.
+func formatStructValue(identFunc IdentFunc, value interface{}) (string, error) {
+	var constructor string
+	switch value.(type) {
+	case nil:
+		return fmt.Sprintf("%s()", identFunc(structPackage.Ident("NewNullValue"))), nil
+	case bool:
+		constructor = "NewBoolValue"
+	case float64:
+		constructor = "NewNumberValue"
+	case string:
+		constructor = "NewStringValue"
+	case []interface{}:
+		constructor = "NewListValue"
+	default:
+		constructor = "NewStructValue"
+	}
+
+	formattedValue, err := formatStructLiteral(identFunc, value)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s(%s)", identFunc(structPackage.Ident(constructor)), formattedValue), nil
+}
+
+// formatStructLiteral formats a JSON value as Go code, objects and arrays are converted by
+// runtime.MustNewStruct and runtime.MustNewList.
+func formatStructLiteral(identFunc IdentFunc, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		formattedFields, err := formatJSONValue(v)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf(
+			"%s(%s)",
+			identFunc(RuntimePackage.Ident("MustNewStruct")),
+			formattedFields,
+		), nil
+	case []interface{}:
+		formattedValues, err := formatJSONValue(v)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf(
+			"%s(%s)",
+			identFunc(RuntimePackage.Ident("MustNewList")),
+			formattedValues,
+		), nil
+	default:
+		return formatJSONValue(v)
+	}
+}
+
+// formatJSONValue formats a value decoded by encoding/json as Go code.
+func formatJSONValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "nil", nil
+	case string:
+		return fmt.Sprintf("%q", v), nil
+	case map[string]interface{}:
+		return formatJSONObject(v)
+	case []interface{}:
+		formattedValues := make([]string, 0, len(v))
+		for _, item := range v {
+			formattedValue, err := formatJSONValue(item)
+			if err != nil {
+				return "", err
+			}
+			formattedValues = append(formattedValues, formattedValue)
+		}
+
+		return fmt.Sprintf("[]interface{}{%s}", strings.Join(formattedValues, ", ")), nil
+	case float64:
+		// Untyped constants in interface{} become int, overflowing with large numbers
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(v, 'g', -1, 64)), nil
+	default:
+		// Booleans are written like in JSON
+		content, err := json.Marshal(v)
+		return string(content), err
+	}
+}
+
+func formatJSONObject(object map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	formattedFields := make([]string, 0, len(object))
+	for _, key := range keys {
+		formattedValue, err := formatJSONValue(object[key])
+		if err != nil {
+			return "", err
+		}
+		formattedFields = append(formattedFields, fmt.Sprintf("%q: %s", key, formattedValue))
+	}
+
+	return fmt.Sprintf("map[string]interface{}{%s}", strings.Join(formattedFields, ", ")), nil
+}
@@ -0,0 +1,289 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: deal/v1/contract/annotations.proto
+
+package contract
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// MethodContract holds the cases of a single method, written exactly like a method
+// inside a contract file (`successCases` and `failureCases`).
+type MethodContract struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Cases written inline, using YAML or JSON.
+	Cases string `protobuf:"bytes,1,opt,name=cases,proto3" json:"cases,omitempty"`
+	// Path of a YAML or JSON file holding the cases, relative to the proto file.
+	File string `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+}
+
+func (x *MethodContract) Reset() {
+	*x = MethodContract{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_deal_v1_contract_annotations_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MethodContract) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MethodContract) ProtoMessage() {}
+
+func (x *MethodContract) ProtoReflect() protoreflect.Message {
+	mi := &file_deal_v1_contract_annotations_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MethodContract.ProtoReflect.Descriptor instead.
+func (*MethodContract) Descriptor() ([]byte, []int) {
+	return file_deal_v1_contract_annotations_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MethodContract) GetCases() string {
+	if x != nil {
+		return x.Cases
+	}
+	return ""
+}
+
+func (x *MethodContract) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+// ServiceContract holds the cases of many methods of a service, written exactly
+// like a service inside a contract file, where each key is a method name.
+type ServiceContract struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Methods written inline, using YAML or JSON.
+	Methods string `protobuf:"bytes,1,opt,name=methods,proto3" json:"methods,omitempty"`
+	// Path of a YAML or JSON file holding the methods, relative to the proto file.
+	File string `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+}
+
+func (x *ServiceContract) Reset() {
+	*x = ServiceContract{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_deal_v1_contract_annotations_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceContract) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceContract) ProtoMessage() {}
+
+func (x *ServiceContract) ProtoReflect() protoreflect.Message {
+	mi := &file_deal_v1_contract_annotations_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceContract.ProtoReflect.Descriptor instead.
+func (*ServiceContract) Descriptor() ([]byte, []int) {
+	return file_deal_v1_contract_annotations_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ServiceContract) GetMethods() string {
+	if x != nil {
+		return x.Methods
+	}
+	return ""
+}
+
+func (x *ServiceContract) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+var file_deal_v1_contract_annotations_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*MethodContract)(nil),
+		Field:         51801,
+		Name:          "deal.v1.contract.method",
+		Tag:           "bytes,51801,opt,name=method",
+		Filename:      "deal/v1/contract/annotations.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
+		ExtensionType: (*ServiceContract)(nil),
+		Field:         51801,
+		Name:          "deal.v1.contract.service",
+		Tag:           "bytes,51801,opt,name=service",
+		Filename:      "deal/v1/contract/annotations.proto",
+	},
+}
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// optional deal.v1.contract.MethodContract method = 51801;
+	E_Method = &file_deal_v1_contract_annotations_proto_extTypes[0]
+)
+
+// Extension fields to descriptorpb.ServiceOptions.
+var (
+	// optional deal.v1.contract.ServiceContract service = 51801;
+	E_Service = &file_deal_v1_contract_annotations_proto_extTypes[1]
+)
+
+var File_deal_v1_contract_annotations_proto protoreflect.FileDescriptor
+
+var file_deal_v1_contract_annotations_proto_rawDesc = []byte{
+	0x0a, 0x22, 0x64, 0x65, 0x61, 0x6c, 0x2f, 0x76, 0x31, 0x2f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61,
+	0x63, 0x74, 0x2f, 0x61, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x64, 0x65, 0x61, 0x6c, 0x2e, 0x76, 0x31, 0x2e, 0x63, 0x6f,
+	0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x3a, 0x0a, 0x0e, 0x4d, 0x65, 0x74, 0x68,
+	0x6f, 0x64, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x61,
+	0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x61, 0x73, 0x65, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x66, 0x69, 0x6c, 0x65, 0x22, 0x3f, 0x0a, 0x0f, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x43,
+	0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64,
+	0x73, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x66, 0x69, 0x6c, 0x65, 0x3a, 0x5a, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12,
+	0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0xd9, 0x94, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x64, 0x65, 0x61, 0x6c, 0x2e, 0x76,
+	0x31, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x3a, 0x5e, 0x0a, 0x07, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x1f, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xd9, 0x94,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x64, 0x65, 0x61, 0x6c, 0x2e, 0x76, 0x31, 0x2e,
+	0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x43, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x07, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x42, 0x3d, 0x5a, 0x3b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x66, 0x61, 0x75, 0x6e, 0x69, 0x73, 0x74, 0x73, 0x2f, 0x64, 0x65, 0x61, 0x6c, 0x2d, 0x67, 0x6f,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x64, 0x65, 0x61, 0x6c, 0x2f, 0x76, 0x31, 0x2f, 0x63,
+	0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74, 0x3b, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x74,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_deal_v1_contract_annotations_proto_rawDescOnce sync.Once
+	file_deal_v1_contract_annotations_proto_rawDescData = file_deal_v1_contract_annotations_proto_rawDesc
+)
+
+func file_deal_v1_contract_annotations_proto_rawDescGZIP() []byte {
+	file_deal_v1_contract_annotations_proto_rawDescOnce.Do(func() {
+		file_deal_v1_contract_annotations_proto_rawDescData = protoimpl.X.CompressGZIP(file_deal_v1_contract_annotations_proto_rawDescData)
+	})
+	return file_deal_v1_contract_annotations_proto_rawDescData
+}
+
+var file_deal_v1_contract_annotations_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_deal_v1_contract_annotations_proto_goTypes = []interface{}{
+	(*MethodContract)(nil),              // 0: deal.v1.contract.MethodContract
+	(*ServiceContract)(nil),             // 1: deal.v1.contract.ServiceContract
+	(*descriptorpb.MethodOptions)(nil),  // 2: google.protobuf.MethodOptions
+	(*descriptorpb.ServiceOptions)(nil), // 3: google.protobuf.ServiceOptions
+}
+var file_deal_v1_contract_annotations_proto_depIdxs = []int32{
+	2, // 0: deal.v1.contract.method:extendee -> google.protobuf.MethodOptions
+	3, // 1: deal.v1.contract.service:extendee -> google.protobuf.ServiceOptions
+	0, // 2: deal.v1.contract.method:type_name -> deal.v1.contract.MethodContract
+	1, // 3: deal.v1.contract.service:type_name -> deal.v1.contract.ServiceContract
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	2, // [2:4] is the sub-list for extension type_name
+	0, // [0:2] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_deal_v1_contract_annotations_proto_init() }
+func file_deal_v1_contract_annotations_proto_init() {
+	if File_deal_v1_contract_annotations_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_deal_v1_contract_annotations_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MethodContract); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_deal_v1_contract_annotations_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServiceContract); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_deal_v1_contract_annotations_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 2,
+			NumServices:   0,
+		},
+		GoTypes:           file_deal_v1_contract_annotations_proto_goTypes,
+		DependencyIndexes: file_deal_v1_contract_annotations_proto_depIdxs,
+		MessageInfos:      file_deal_v1_contract_annotations_proto_msgTypes,
+		ExtensionInfos:    file_deal_v1_contract_annotations_proto_extTypes,
+	}.Build()
+	File_deal_v1_contract_annotations_proto = out.File
+	file_deal_v1_contract_annotations_proto_rawDesc = nil
+	file_deal_v1_contract_annotations_proto_goTypes = nil
+	file_deal_v1_contract_annotations_proto_depIdxs = nil
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+var errBidiCaseFields = errors.New(
+	"bidirectional streaming methods must only use 'steps' to describe the requests and responses",
+)
+
+// getScriptsRepresentation returns the []runtime.Script representing every case of a
+// bidirectional streaming method, success cases come first so they're preferred.
+func getScriptsRepresentation(
+	file *outputFile,
+	method *protogen.Method,
+	methodContract entities.Method,
+) (string, error) {
+	scripts := make([]string, 0, len(methodContract.SuccessCases)+len(methodContract.FailureCases))
+
+	for i, successCase := range methodContract.SuccessCases {
+		if successCase.Request != nil || successCase.Requests != nil ||
+			successCase.RequestMatchers != nil || successCase.Response != nil ||
+			successCase.Responses != nil {
+			return "", successCaseError(method, i, successCase, errBidiCaseFields)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
+		)
+		if err != nil {
+			return "", successCaseError(method, i, successCase, err)
+		}
+
+		script, err := getScriptRepresentation(
+			file, method, successCase.Description, successCase.Steps, "nil", metadata,
+		)
+		if err != nil {
+			return "", successCaseError(method, i, successCase, err)
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	for i, failureCase := range methodContract.FailureCases {
+		if failureCase.Request != nil || failureCase.Requests != nil ||
+			failureCase.RequestMatchers != nil || failureCase.Responses != nil {
+			return "", failureCaseError(method, i, failureCase, errBidiCaseFields)
+		}
+
+		errorRepresentation, err := getErrorRepresentation(file, failureCase.Error)
+		if err != nil {
+			return "", failureCaseError(method, i, failureCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
+		)
+		if err != nil {
+			return "", failureCaseError(method, i, failureCase, err)
+		}
+
+		script, err := getScriptRepresentation(
+			file,
+			method,
+			failureCase.Description,
+			failureCase.Steps,
+			errorRepresentation,
+			metadata,
+		)
+		if err != nil {
+			return "", failureCaseError(method, i, failureCase, err)
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	return fmt.Sprintf(
+		"[]%s{\n%s}",
+		file.QualifiedGoIdent(dealRuntime.Ident("Script")),
+		strings.Join(scripts, ""),
+	), nil
+}
+
+func getScriptRepresentation(
+	file *outputFile,
+	method *protogen.Method,
+	description string,
+	steps []entities.Step,
+	err string,
+	metadata caseMetadata,
+) (string, error) {
+	formattedSteps := make([]string, 0, len(steps))
+	for _, step := range steps {
+		requestRepresentation, stepErr := getProtoRepresentation(step.Request, method.Input, file)
+		if stepErr != nil {
+			return "", stepErr
+		}
+
+		formattedMatchers, stepErr := processors.FormatRequestMatchers(
+			file.QualifiedGoIdent, method.Input, step.RequestMatchers,
+		)
+		if stepErr != nil {
+			return "", stepErr
+		}
+
+		responsesRepresentation, stepErr := getProtoListRepresentation(
+			step.Responses, method.Output, file,
+		)
+		if stepErr != nil {
+			return "", stepErr
+		}
+
+		formattedSteps = append(
+			formattedSteps,
+			fmt.Sprintf(
+				"{\nRequest: %s,\nMatchers: []%s{%s},\nResponses: []%s{%s},\n},\n",
+				requestRepresentation,
+				file.QualifiedGoIdent(dealRuntime.Ident("FieldMatcher")),
+				strings.Join(formattedMatchers, ", "),
+				file.QualifiedGoIdent(protoPackage.Ident("Message")),
+				formatListItems(responsesRepresentation),
+			),
+		)
+	}
+
+	return fmt.Sprintf(
+		"{\nDescription: %q,\nSteps: []%s{\n%s},\nErr: %s,\n%s},\n",
+		description,
+		file.QualifiedGoIdent(dealRuntime.Ident("Step")),
+		strings.Join(formattedSteps, ""),
+		err,
+		metadata.fields("Metadata", "Header", "Trailer"),
+	), nil
+}
+
+// generateBidiStreamingStubMethod generates the stub server method for a bidirectional
+// streaming RPC, the contract scripts are replayed as the messages arrive.
+func generateBidiStreamingStubMethod(
+	file *outputFile,
+	serverName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) error {
+	scripts, err := getScriptsRepresentation(file, method, methodContract)
+	if err != nil {
+		return err
+	}
+
+	file.P(
+		fmt.Sprintf(
+			`func (s *%s) %s(stream %s_%sServer) error {
+				return %s(
+					stream,
+					func() %s { return new(%s) },
+					s.calls.Recorder(%q),
+					%s,
+					%s...,
+				)
+			}`,
+			serverName,
+			method.GoName,
+			method.Parent.GoName,
+			method.GoName,
+			file.QualifiedGoIdent(dealRuntime.Ident("ReplayScripts")),
+			file.QualifiedGoIdent(protoPackage.Ident("Message")),
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			method.GoName,
+			scriptsUnmatched(file, method, "s.Unmatched"),
+			scripts,
+		),
+	)
+	file.P()
+
+	return nil
+}
+
+func generateBidiStreamingSuccessTest(
+	file *outputFile,
+	method *protogen.Method,
+	successCases []entities.SuccessCase,
+) error {
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Success Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	generateBidiStepType(file, method)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nsteps []step\n%s\n%s} {",
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, successCase := range successCases {
+		steps, err := getStepsTestRepresentation(file, method, successCase.Steps)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		given, err := providerStateTestField(file, successCase.Given)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nsteps: %s,\n%s%s},",
+				successCase.Description,
+				steps,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					%s
+
+					if _, err = stream.Recv(); err != %s {
+						t.Fatalf("expected the end of the stream, given: %%v", err)
+					}
+
+					%s
+
+					%s
+				})
+			}`,
+			playSteps(file, method),
+			file.QualifiedGoIdent(ioPackage.Ident("EOF")),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+func generateBidiStreamingFailureTest(
+	file *outputFile,
+	method *protogen.Method,
+	failureCases []entities.FailureCase,
+) error {
+	file.P()
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Failure Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	generateBidiStepType(file, method)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nsteps []step\nexpectedError string\n%s\n%s\n%s} {",
+			errorDetailsTestStructField(file),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, failureCase := range failureCases {
+		steps, err := getStepsTestRepresentation(file, method, failureCase.Steps)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		given, err := providerStateTestField(file, failureCase.Given)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		detailsField, err := errorDetailsTestField(file, failureCase.Error)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nsteps: %s,\nexpectedError: %q,\n%s%s%s},",
+				failureCase.Description,
+				steps,
+				failureCase.Error,
+				detailsField,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					%s
+
+					_, err = stream.Recv()
+					if err == nil || err == %s {
+						t.Fatalf("an error was expected but the stream didn't return one: %%v", err)
+					}
+
+					if err.Error() != test.expectedError {
+						t.Fatalf("expected error: %%s, given error: %%s", test.expectedError, err)
+					}
+
+					%s
+
+					%s
+
+					%s
+				})
+			}`,
+			playSteps(file, method),
+			file.QualifiedGoIdent(ioPackage.Ident("EOF")),
+			assertErrorDetails(file),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+func generateBidiStepType(file *outputFile, method *protogen.Method) {
+	file.P(
+		fmt.Sprintf(
+			"type step struct {request *%s\nexpectedResponses []*%s}",
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			file.QualifiedGoIdent(method.Output.GoIdent),
+		),
+	)
+	file.P()
+}
+
+func getStepsTestRepresentation(
+	file *outputFile,
+	method *protogen.Method,
+	steps []entities.Step,
+) (string, error) {
+	formattedSteps := make([]string, 0, len(steps))
+	for _, step := range steps {
+		requestRepresentation, err := getProtoRepresentation(step.Request, method.Input, file)
+		if err != nil {
+			return "", err
+		}
+
+		responsesRepresentation, err := getProtoListRepresentation(
+			step.Responses, method.Output, file,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		formattedSteps = append(
+			formattedSteps,
+			fmt.Sprintf(
+				"{\nrequest: %s,\nexpectedResponses: []*%s{%s},\n},\n",
+				requestRepresentation,
+				file.QualifiedGoIdent(method.Output.GoIdent),
+				formatListItems(responsesRepresentation),
+			),
+		)
+	}
+
+	return fmt.Sprintf("[]step{\n%s}", strings.Join(formattedSteps, "")), nil
+}
+
+// playSteps returns the code opening the stream and going through every step,
+// sending the request and comparing the received responses with the expected ones.
+// The sending side is closed at the end, so the stream can be finished by the server.
+func playSteps(file *outputFile, method *protogen.Method) string {
+	return fmt.Sprintf(`stream, err := client.%s(%s)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %%v", err)
+		}
+
+		for i, step := range test.steps {
+			if err = stream.Send(step.request); err != nil {
+				t.Fatalf("unexpected error sending the request %%d: %%v", i, err)
+			}
+
+			for j, expectedResponse := range step.expectedResponses {
+				response, err := stream.Recv()
+				if err != nil {
+					t.Fatalf(
+						"unexpected error receiving the response %%d of the step %%d: %%v",
+						j, i, err,
+					)
+				}
+
+				if !%s(response, expectedResponse) {
+					t.Fatalf(
+						"expected response %%d of the step %%d: %%v, given response: %%v",
+						j, i, expectedResponse, response,
+					)
+				}
+			}
+		}
+
+		if err = stream.CloseSend(); err != nil {
+			t.Fatalf("unexpected error closing the stream: %%v", err)
+		}`,
+		method.GoName,
+		outgoingContext(file),
+		file.QualifiedGoIdent(dealRuntime.Ident("EqualMessages")),
+	)
+}
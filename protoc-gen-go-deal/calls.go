@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+// callsField returns the declaration of the field recording the calls, used by the
+// generated client and stub server.
+func callsField(file *outputFile) string {
+	return fmt.Sprintf("calls %s\n", file.QualifiedGoIdent(dealRuntime.Ident("CallJournal")))
+}
+
+// withCallRecord wraps returnFunc recording the call in `journal` before the case returns,
+// `requests` is the expression holding the received requests as a []proto.Message.
+func withCallRecord(
+	journal string,
+	method *protogen.Method,
+	requests string,
+	returnFunc caseReturnFunc,
+) caseReturnFunc {
+	return func(outcome caseOutcome) string {
+		record := func(err string) string {
+			return fmt.Sprintf(
+				"%s.Record(%q, %q, %s, %s)",
+				journal, method.GoName, outcome.description, requests, err,
+			)
+		}
+
+		if outcome.err == "nil" {
+			return fmt.Sprintf("%s\n%s", record("nil"), returnFunc(outcome))
+		}
+
+		// The error is built only once, since it's both recorded and returned
+		recorded := outcome
+		recorded.err = "callErr"
+
+		return fmt.Sprintf(
+			"callErr := %s\n%s\n%s", outcome.err, record("callErr"), returnFunc(recorded),
+		)
+	}
+}
+
+// generateCallJournal writes the methods exposing the calls recorded by the generated type,
+// along with the assertions used by the consumer tests.
+func generateCallJournal(
+	file *outputFile,
+	typeName string,
+	receiver string,
+	service *protogen.Service,
+	contractService entities.Service,
+	scenarios []entities.Scenario,
+) {
+	testingTB := file.QualifiedGoIdent(testingPackage.Ident("TB"))
+
+	file.P()
+	file.P(
+		fmt.Sprintf(
+			`// Calls returns every call received so far, in order.
+			func (%s *%s) Calls() []%s {
+				return %s.calls.Calls()
+			}
+
+			// ResetCalls forgets every call received so far.
+			func (%s *%s) ResetCalls() {
+				%s.calls.Reset()
+			}
+
+			// AssertCalled fails the test when the method wasn't called with the case,
+			// an empty caseName accepts any call of the method.
+			func (%s *%s) AssertCalled(t %s, method string, caseName string) bool {
+				t.Helper()
+				return %s.calls.AssertCalled(t, method, caseName)
+			}
+
+			// AssertNotCalled fails the test when the method was called with the case,
+			// an empty caseName rejects any call of the method.
+			func (%s *%s) AssertNotCalled(t %s, method string, caseName string) bool {
+				t.Helper()
+				return %s.calls.AssertNotCalled(t, method, caseName)
+			}
+
+			// AssertNumberOfCalls fails the test when the method wasn't called the given times
+			// with the case, an empty caseName counts every call of the method.
+			func (%s *%s) AssertNumberOfCalls(
+				t %s, method string, caseName string, times int,
+			) bool {
+				t.Helper()
+				return %s.calls.AssertNumberOfCalls(t, method, caseName, times)
+			}
+
+			// AssertAllCasesExercised fails the test when any case of the contract wasn't
+			// matched by a call.
+			func (%s *%s) AssertAllCasesExercised(t %s) bool {
+				t.Helper()
+				return %s.calls.AssertAllCasesExercised(t, map[string][]string{%s})
+			}`,
+			receiver, typeName, file.QualifiedGoIdent(dealRuntime.Ident("Call")), receiver,
+			receiver, typeName, receiver,
+			receiver, typeName, testingTB, receiver,
+			receiver, typeName, testingTB, receiver,
+			receiver, typeName, testingTB, receiver,
+			receiver, typeName, testingTB, receiver,
+			getContractCases(service, contractService, scenarios),
+		),
+	)
+	file.P()
+}
+
+// getContractCases returns the items of the map holding the description of every case
+// of the contract by method, scenario steps included.
+func getContractCases(
+	service *protogen.Service,
+	contractService entities.Service,
+	scenarios []entities.Scenario,
+) string {
+	cases := make(map[string][]string)
+	for _, scenario := range scenarios {
+		for _, step := range scenario.Steps {
+			cases[step.Method] = append(cases[step.Method], step.Description)
+		}
+	}
+
+	for _, method := range service.Methods {
+		methodContract := contractService[method.GoName]
+		for _, successCase := range methodContract.SuccessCases {
+			cases[method.GoName] = append(cases[method.GoName], successCase.Description)
+		}
+		for _, failureCase := range methodContract.FailureCases {
+			cases[method.GoName] = append(cases[method.GoName], failureCase.Description)
+		}
+	}
+
+	methods := make([]string, 0, len(cases))
+	for method := range cases {
+		if findMethod(service, method) != nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+
+	items := make([]string, 0, len(methods))
+	for _, method := range methods {
+		descriptions := make([]string, 0, len(cases[method]))
+		for _, description := range cases[method] {
+			descriptions = append(descriptions, fmt.Sprintf("%q", description))
+		}
+
+		items = append(
+			items, fmt.Sprintf("%q: {%s}", method, strings.Join(descriptions, ", ")),
+		)
+	}
+
+	return formatListItems(items)
+}
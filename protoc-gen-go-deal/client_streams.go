@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+// generateClientStreamingStubMethod generates the stub server method for a
+// client-streaming RPC, the cases are matched once the client closes the stream.
+func generateClientStreamingStubMethod(
+	file *outputFile,
+	serverName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) error {
+	returnFunc := withResponseMetadata(
+		streamMetadataStatement(file),
+		func(outcome caseOutcome) string {
+			if outcome.err != "nil" {
+				return fmt.Sprintf("return %s", outcome.err)
+			}
+
+			return fmt.Sprintf("return stream.SendAndClose(%s)", outcome.responses[0])
+		},
+	)
+
+	switchCase, err := generateClientCases(
+		file, method, methodContract, withCallRecord(
+			"s.calls", method, "in", withEmptyResponse(emptyResponse(file, method), returnFunc),
+		),
+		unmatchedCall{behavior: "s.Unmatched", ctx: "stream.Context()", requests: "in"},
+	)
+	if err != nil {
+		return err
+	}
+
+	file.P(
+		fmt.Sprintf(
+			`func (s *%s) %s(stream %s_%sServer) error {
+				in, err := %s(stream, func() %s { return new(%s) })
+				if err != nil {
+					return err
+				}
+				%s%s
+			}`,
+			serverName,
+			method.GoName,
+			method.Parent.GoName,
+			method.GoName,
+			file.QualifiedGoIdent(dealRuntime.Ident("ReceiveRequests")),
+			file.QualifiedGoIdent(protoPackage.Ident("Message")),
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			streamContext(methodContract, "stream"),
+			switchCase,
+		),
+	)
+	file.P()
+
+	return nil
+}
+
+func generateClientStreamingSuccessTest(
+	file *outputFile,
+	method *protogen.Method,
+	successCases []entities.SuccessCase,
+) error {
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Success Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nrequests []*%s\nexpectedResponse *%s\n%s\n%s} {",
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			file.QualifiedGoIdent(method.Output.GoIdent),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, successCase := range successCases {
+		requestsRepresentation, err := getProtoListRepresentation(
+			successCase.Requests, method.Input, file,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		responseRepresentation, err := getProtoRepresentation(
+			successCase.Response, method.Output, file,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		given, err := providerStateTestField(file, successCase.Given)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nrequests: []*%s{%s},\nexpectedResponse: %s,\n%s%s},",
+				successCase.Description,
+				file.QualifiedGoIdent(method.Input.GoIdent),
+				formatListItems(requestsRepresentation),
+				responseRepresentation,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					stream, err := client.%s(%s)
+					if err != nil {
+						t.Fatalf("unexpected error happened: %%v", err)
+					}
+
+					%s
+
+					response, err := stream.CloseAndRecv()
+					if err != nil {
+						t.Fatalf("unexpected error happened: %%v", err)
+					}
+
+					if !%s(response, test.expectedResponse) {
+						t.Fatalf(
+							"expected response: %%v, given response: %%v",
+							test.expectedResponse, response,
+						)
+					}
+
+					%s
+
+					%s
+				})
+			}`,
+			method.GoName,
+			outgoingContext(file),
+			sendRequests(file),
+			file.QualifiedGoIdent(dealRuntime.Ident("EqualMessages")),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+func generateClientStreamingFailureTest(
+	file *outputFile,
+	method *protogen.Method,
+	failureCases []entities.FailureCase,
+) error {
+	file.P()
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Failure Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nrequests []*%s\nexpectedError string\n%s\n%s\n%s} {",
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			errorDetailsTestStructField(file),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, failureCase := range failureCases {
+		requestsRepresentation, err := getProtoListRepresentation(
+			failureCase.Requests, method.Input, file,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		given, err := providerStateTestField(file, failureCase.Given)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		detailsField, err := errorDetailsTestField(file, failureCase.Error)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nrequests: []*%s{%s},\nexpectedError: %q,\n%s%s%s},",
+				failureCase.Description,
+				file.QualifiedGoIdent(method.Input.GoIdent),
+				formatListItems(requestsRepresentation),
+				failureCase.Error,
+				detailsField,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					stream, err := client.%s(%s)
+					if err != nil {
+						t.Fatalf("unexpected error happened: %%v", err)
+					}
+
+					%s
+
+					_, err = stream.CloseAndRecv()
+					if err == nil {
+						t.Fatalf("an error was expected but no one was returned")
+					}
+
+					if err.Error() != test.expectedError {
+						t.Fatalf("expected error: %%s, given error: %%s", test.expectedError, err)
+					}
+
+					%s
+
+					%s
+
+					%s
+				})
+			}`,
+			method.GoName,
+			outgoingContext(file),
+			sendRequests(file),
+			assertErrorDetails(file),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+// sendRequests returns the loop that sends every request of the test through the stream.
+// As the server may fail before receiving all of them, io.EOF stops the loop and the
+// actual status is given by CloseAndRecv.
+func sendRequests(file *outputFile) string {
+	return fmt.Sprintf(`for i, request := range test.requests {
+			err = stream.Send(request)
+			if err == %s {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error sending the request %%d: %%v", i, err)
+			}
+		}`,
+		file.QualifiedGoIdent(ioPackage.Ident("EOF")),
+	)
+}
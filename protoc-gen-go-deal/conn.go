@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+// generateContractConn generates a grpc.ClientConnInterface playing the contract cases
+// through the contract client, so the client generated by `protoc-gen-go-grpc` can be
+// built on top of it along with its interceptors.
+func generateContractConn(file *outputFile, service *protogen.Service) {
+	serviceName := processors.MakeExportedName(service.GoName)
+	connName := fmt.Sprintf("%sContractConn", serviceName)
+	clientName := fmt.Sprintf("%sContractClient", serviceName)
+	grpcCallOption := file.QualifiedGoIdent(grpcPackage.Ident("CallOption"))
+
+	unaryHandlers := make([]string, 0, len(service.Methods))
+	streamHandlers := make([]string, 0, len(service.Methods))
+	for _, method := range service.Methods {
+		fullMethod := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.Desc.Name())
+
+		if isStreaming(method) {
+			streamHandlers = append(
+				streamHandlers,
+				fmt.Sprintf(
+					"%q: func(ctx %s, opts ...%s) (%s, error) {\n%s\n}",
+					fullMethod,
+					file.QualifiedGoIdent(contextContext),
+					grpcCallOption,
+					file.QualifiedGoIdent(grpcPackage.Ident("ClientStream")),
+					streamHandlerBody(file, method),
+				),
+			)
+			continue
+		}
+
+		unaryHandlers = append(
+			unaryHandlers,
+			fmt.Sprintf(
+				`%q: func(ctx %s, request interface{}, opts ...%s) (%s, error) {
+					%s
+					return client.%s(ctx, in, opts...)
+				}`,
+				fullMethod,
+				file.QualifiedGoIdent(contextContext),
+				grpcCallOption,
+				file.QualifiedGoIdent(protoPackage.Ident("Message")),
+				requestAssertion(file, method),
+				method.GoName,
+			),
+		)
+	}
+
+	file.P(
+		fmt.Sprintf(
+			`// %s is a grpc.ClientConnInterface playing the contract cases through
+			// %s, clients built on top of it run their interceptors as usual.
+			type %s struct {
+				*%s
+				// Client plays the cases, it's configured like any contract client.
+				Client *%s
+			}`,
+			connName,
+			clientName,
+			connName,
+			file.QualifiedGoIdent(dealRuntime.Ident("ContractConn")),
+			clientName,
+		),
+	)
+	file.P()
+	file.P(
+		fmt.Sprintf(
+			`// New%s creates a %s, the options may add client interceptors.
+			func New%s(opts ...%s) *%s {
+				client := &%s{}
+				return &%s{
+					ContractConn: %s(
+						map[string]%s{%s},
+						map[string]%s{%s},
+						opts...,
+					),
+					Client: client,
+				}
+			}`,
+			connName,
+			connName,
+			connName,
+			file.QualifiedGoIdent(dealRuntime.Ident("ContractConnOption")),
+			connName,
+			clientName,
+			connName,
+			file.QualifiedGoIdent(dealRuntime.Ident("NewContractConn")),
+			file.QualifiedGoIdent(dealRuntime.Ident("UnaryHandler")),
+			formatListItems(unaryHandlers),
+			file.QualifiedGoIdent(dealRuntime.Ident("StreamHandler")),
+			formatListItems(streamHandlers),
+		),
+	)
+	file.P()
+}
+
+// streamHandlerBody returns the body of the runtime.StreamHandler of a streaming method.
+func streamHandlerBody(file *outputFile, method *protogen.Method) string {
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return fmt.Sprintf("return client.%s(ctx, opts...)", method.GoName)
+	case method.Desc.IsStreamingClient():
+		return fmt.Sprintf(
+			`stream, err := client.%s(ctx, opts...)
+			if err != nil {
+				return nil, err
+			}
+			closeAndRecv := func() (%s, error) { return stream.CloseAndRecv() }
+			return %s(stream, closeAndRecv), nil`,
+			method.GoName,
+			file.QualifiedGoIdent(protoPackage.Ident("Message")),
+			file.QualifiedGoIdent(dealRuntime.Ident("NewClientStreamingStream")),
+		)
+	default:
+		// The request is only sent once the stream is open
+		return fmt.Sprintf(
+			`return %s(ctx, func(request interface{}) (%s, error) {
+				%s
+				return client.%s(ctx, in, opts...)
+			}), nil`,
+			file.QualifiedGoIdent(dealRuntime.Ident("NewServerStreamingStream")),
+			file.QualifiedGoIdent(grpcPackage.Ident("ClientStream")),
+			requestAssertion(file, method),
+			method.GoName,
+		)
+	}
+}
+
+// requestAssertion returns the statements converting `request` to the method input as `in`.
+func requestAssertion(file *outputFile, method *protogen.Method) string {
+	return strings.Join([]string{
+		fmt.Sprintf("in, ok := request.(*%s)", file.QualifiedGoIdent(method.Input.GoIdent)),
+		"if !ok {",
+		fmt.Sprintf(
+			`return nil, %s(%s, "unexpected request type %%T", request)`,
+			file.QualifiedGoIdent(grpcStatus.Ident("Errorf")),
+			file.QualifiedGoIdent(grpcCodes.Ident("Internal")),
+		),
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+// getErrorRepresentation returns the expression creating the error of a failure case,
+// errors with details are created through runtime.NewError.
+func getErrorRepresentation(file *outputFile, grpcError entities.GRPCError) (string, error) {
+	if !processors.IsErrorCodeValid(grpcError.ErrorCode) {
+		return "", fmt.Errorf("invalid error code: %s", grpcError.ErrorCode)
+	}
+
+	code := file.QualifiedGoIdent(grpcCodes.Ident(grpcError.ErrorCode))
+
+	if len(grpcError.Details) == 0 {
+		return fmt.Sprintf(
+			"%s(%s, %q)",
+			file.QualifiedGoIdent(grpcStatus.Ident("Error")),
+			code,
+			grpcError.Message,
+		), nil
+	}
+
+	details, err := getErrorDetailsRepresentation(file, grpcError.Details)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s(%s, %q, %s)",
+		file.QualifiedGoIdent(dealRuntime.Ident("NewError")),
+		code,
+		grpcError.Message,
+		strings.Join(details, ", "),
+	), nil
+}
+
+func getErrorDetailsRepresentation(file *outputFile, details []interface{}) ([]string, error) {
+	representations := make([]string, 0, len(details))
+	for _, detail := range details {
+		representation, err := processors.FormatErrorDetail(
+			file.QualifiedGoIdent, file.registry, detail,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		representations = append(representations, representation)
+	}
+
+	return representations, nil
+}
+
+// errorDetailsTestField returns the test case field holding the expected error details,
+// it's omitted when the error has no details.
+func errorDetailsTestField(file *outputFile, grpcError entities.GRPCError) (string, error) {
+	if len(grpcError.Details) == 0 {
+		return "", nil
+	}
+
+	details, err := getErrorDetailsRepresentation(file, grpcError.Details)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"expectedDetails: []%s{%s},\n",
+		file.QualifiedGoIdent(protoPackage.Ident("Message")),
+		formatListItems(details),
+	), nil
+}
+
+// errorDetailsTestStructField returns the declaration of the test case field
+// holding the expected error details.
+func errorDetailsTestStructField(file *outputFile) string {
+	return fmt.Sprintf(
+		"expectedDetails []%s",
+		file.QualifiedGoIdent(protoPackage.Ident("Message")),
+	)
+}
+
+// assertErrorDetails returns the code verifying that `err` carries the expected details.
+func assertErrorDetails(file *outputFile) string {
+	return fmt.Sprintf(`if !%s(err, test.expectedDetails...) {
+			t.Fatalf(
+				"expected details: %%v, given details: %%v",
+				test.expectedDetails, %s(err).Details(),
+			)
+		}`,
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchErrorDetails")),
+		file.QualifiedGoIdent(grpcStatus.Ident("Convert")),
+	)
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -22,11 +24,14 @@ const (
 	testingPackage         = protogen.GoImportPath("testing")
 	logPackage             = protogen.GoImportPath("log")
 	netPackage             = protogen.GoImportPath("net")
+	ioPackage              = protogen.GoImportPath("io")
 	grpcPackage            = protogen.GoImportPath("google.golang.org/grpc")
 	grpcCodes              = protogen.GoImportPath("google.golang.org/grpc/codes")
 	grpcStatus             = protogen.GoImportPath("google.golang.org/grpc/status")
 	protoPackage           = protogen.GoImportPath("google.golang.org/protobuf/proto")
 	buffconPackage         = protogen.GoImportPath("google.golang.org/grpc/test/bufconn")
+	grpcInsecure           = protogen.GoImportPath("google.golang.org/grpc/credentials/insecure")
+	dealRuntime            = processors.RuntimePackage
 )
 
 var (
@@ -37,69 +42,166 @@ var (
 func main() { //nolint:gocognit // this function set flags and verify them, after generate the code
 	var flags flag.FlagSet
 
-	contractFilePath := flags.String("contract-file", "", "Path to your contract file")
+	var contractFiles, contractDirs, protoPaths, descriptorSets stringList
+	flags.Var(&contractFiles, "contract-file", "Path or glob pattern of your contract files")
+	flags.Var(&contractDirs, "contract-dir", "Directory containing your contract files")
+	flags.Var(&protoPaths, "proto-path", "Directory where the proto files are looked up")
+	flags.Var(
+		&descriptorSets, "descriptor-set", "FileDescriptorSet with the types packed in Any fields",
+	)
+	strict := flags.Bool(
+		"strict", false, "Fail when the contract has services or methods missing from the protos",
+	)
+	unmatched := flags.String(
+		"unmatched", "strict", "What happens when a request matches no case: strict or permissive",
+	)
 
 	protogen.Options{
 		ParamFunc: flags.Set,
 	}.Run(func(plugin *protogen.Plugin) error {
 		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
 
-		if *contractFilePath == "" {
-			return fmt.Errorf("'contract-file' option not provided")
+		if len(protoPaths) == 0 {
+			protoPaths = stringList{"."}
+		}
+
+		unmatchedBehavior, valid := unmatchedBehaviors[*unmatched]
+		if !valid {
+			return fmt.Errorf("invalid unmatched option '%s', use strict or permissive", *unmatched)
+		}
+
+		// Contracts from every file are merged, so each service is generated only once.
+		// Files are optional since the contracts can be written through proto options
+		contracts := processors.NewContractMerger(plugin.Files)
+		if len(contractFiles) > 0 || len(contractDirs) > 0 {
+			contractFilePaths, err := processors.FindContractFiles(contractFiles, contractDirs)
+			if err != nil {
+				return err
+			}
+
+			if err = contracts.MergeFiles(contractFilePaths); err != nil {
+				return err
+			}
+		}
+
+		registry, err := newMessageRegistry(plugin.Files, descriptorSets)
+		if err != nil {
+			return err
 		}
 
 		for _, file := range plugin.Files {
 			if file.Generate {
-				_, err := generateContracts(plugin, file, *contractFilePath)
+				_, err := generateContracts(
+					plugin, registry, unmatchedBehavior, file, contracts, protoPaths,
+				)
 				if err != nil {
 					return err
 				}
 			}
 		}
 
+		// Contracts can only be checked once the options of every file were merged
+		if *strict {
+			return contracts.CheckUnmatched()
+		}
+
 		return nil
 	})
 }
 
+// newMessageRegistry creates the registry with the files received by the plugin, followed by
+// the ones of the descriptor sets, so the types packed in Any fields can be found even when
+// the compiled files don't import them.
+func newMessageRegistry(
+	files []*protogen.File,
+	descriptorSets []string,
+) (*processors.MessageRegistry, error) {
+	files = append([]*protogen.File{}, files...)
+	for _, descriptorSet := range descriptorSets {
+		descriptorFiles, err := processors.ReadDescriptorSet(descriptorSet)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, descriptorFiles...)
+	}
+
+	return processors.NewMessageRegistry(files)
+}
+
+// stringList is a flag that can be given more than once, every value is kept in order.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// outputFile is the file being generated, along with the registry used to find the
+// messages referenced by the contract and the runtime behavior of unmatched requests.
+type outputFile struct {
+	*protogen.GeneratedFile
+	registry  *processors.MessageRegistry
+	unmatched string
+}
+
 func generateContracts( //nolint:gocognit // This function is simple enough to keep it as is
 	plugin *protogen.Plugin,
+	registry *processors.MessageRegistry,
+	unmatched string,
 	file *protogen.File,
-	contractFilePath string,
-) (*protogen.GeneratedFile, error) {
+	contracts *processors.ContractMerger,
+	protoPaths []string,
+) (*outputFile, error) {
 	if len(file.Services) == 0 {
 		return nil, nil
 	}
 
-	// Parse contract JSON file that was defined in the service options
-	rawContract, err := processors.ReadContractFile(contractFilePath)
-	if err != nil {
+	// Contracts written through the proto options are merged with the ones from the files
+	if err := contracts.MergeOptions(file, protoPaths); err != nil {
 		return nil, err
 	}
+	rawContract := contracts.Contract()
 
 	filename := fmt.Sprintf("%s_contract.pb.go", file.GeneratedFilenamePrefix)
-	newFile := plugin.NewGeneratedFile(filename, file.GoImportPath)
+	newFile := &outputFile{
+		GeneratedFile: plugin.NewGeneratedFile(filename, file.GoImportPath),
+		registry:      registry,
+		unmatched:     unmatched,
+	}
 
-	writeHeader(file, newFile, getProtocVersion(plugin))
+	writeHeader(file, newFile.GeneratedFile, getProtocVersion(plugin))
 
 	for _, service := range file.Services {
 		// Verifies if the file has a contract for the given service
-		serviceContract, hasContract := rawContract.Services[service.GoName]
-		if !hasContract {
+		serviceName := string(service.Desc.FullName())
+		serviceContract, hasContract := rawContract.Services[serviceName]
+		scenarios := processors.ServiceScenarios(rawContract, serviceName)
+		if !hasContract && len(scenarios) == 0 {
 			continue
 		}
 
-		err = generateClient(newFile, service, serviceContract)
-		if err != nil {
+		if err := validateScenarios(service, scenarios); err != nil {
 			return nil, err
 		}
 
-		err = generateStubServer(newFile, service, serviceContract)
-		if err != nil {
+		if err := generateClient(newFile, service, serviceContract, scenarios); err != nil {
 			return nil, err
 		}
 
-		err = generateServerTest(newFile, service, serviceContract)
-		if err != nil {
+		generateContractConn(newFile, service)
+
+		if err := generateStubServer(newFile, service, serviceContract, scenarios); err != nil {
+			return nil, err
+		}
+
+		generateStartStub(newFile, service)
+
+		if err := generateServerTest(newFile, service, serviceContract, scenarios); err != nil {
 			return nil, err
 		}
 	}
@@ -137,15 +239,56 @@ func getProtocVersion(plugin *protogen.Plugin) string {
 	return version
 }
 
+// caseOutcome holds the representation of how a case finishes the call: its description,
+// its responses, its error and the response metadata, "nil" is used for the missing ones.
+// The unmatched outcome is the one of the requests matching no case.
+type caseOutcome struct {
+	description string
+	responses   []string
+	err         string
+	header      string
+	trailer     string
+	unmatched   bool
+}
+
+// caseReturnFunc formats the statement returned by a switch case.
+type caseReturnFunc func(outcome caseOutcome) string
+
+// withEmptyResponse answers the unmatched requests with the given empty response when the
+// behavior is permissive, since a stub server can't finish a call with a nil response.
+func withEmptyResponse(response string, returnFunc caseReturnFunc) caseReturnFunc {
+	return func(outcome caseOutcome) string {
+		if !outcome.unmatched {
+			return returnFunc(outcome)
+		}
+
+		empty := caseOutcome{
+			responses: []string{response}, err: "nil", header: "nil", trailer: "nil",
+		}
+
+		return fmt.Sprintf(
+			"if %s != nil {\n%s\n}\n%s", outcome.err, returnFunc(outcome), returnFunc(empty),
+		)
+	}
+}
+
 func generateClient(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	service *protogen.Service,
 	contractService entities.Service,
+	scenarios []entities.Scenario,
 ) error {
 	clientName := fmt.Sprintf("%sContractClient", processors.MakeExportedName(service.GoName))
 
 	// Create client struct
-	file.P(fmt.Sprintf("type %s struct {}", clientName))
+	file.P(
+		fmt.Sprintf(
+			"type %s struct {\n%s%s%s}",
+			clientName, unmatchedField(file), scenariosField(file, scenarios), callsField(file),
+		),
+	)
+	generateResetScenarios(file, clientName, "c", scenarios)
+	generateCallJournal(file, clientName, "c", service, contractService, scenarios)
 
 	// Iterate over the service methods and generate the proper method containing a
 	// switch case based on the Request/Response provided by the user through JSON File
@@ -154,14 +297,35 @@ func generateClient(
 		// 'cause the method will be created with a default switch case in order to satisfy
 		// the client interface generated by `protoc-gen-go-grpc`.
 		methodContract := contractService[method.GoName]
-		switchCase, err := generateClientCases(file, method, methodContract)
+
+		if isStreaming(method) {
+			err := generateStreamingClientMethod(file, clientName, method, methodContract)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		returnFunc := withResponseMetadata(
+			fmt.Sprintf(
+				"%s(opts, %%s, %%s)",
+				file.QualifiedGoIdent(dealRuntime.Ident("SetCallMetadata")),
+			),
+			unaryCaseReturn,
+		)
+
+		unmatched := unaryUnmatchedCall(file, "c.Unmatched", "ctx")
+		switchCase, err := generateScenarioClientCases(
+			file, method, methodContract, scenarios, "c.scenarios",
+			withCallRecord("c.calls", method, unmatched.requests, returnFunc), unmatched,
+		)
 		if err != nil {
 			return err
 		}
 
 		file.P(
 			fmt.Sprintf(
-				"func (_ %s) %s(ctx %s, in *%s, opts ...%s) (*%s, error) {%s}",
+				"func (c *%s) %s(ctx %s, in *%s, opts ...%s) (*%s, error) {%s}",
 				clientName,
 				method.GoName,
 				file.QualifiedGoIdent(contextContext),
@@ -177,14 +341,26 @@ func generateClient(
 }
 
 func generateStubServer(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	service *protogen.Service,
 	contractService entities.Service,
+	scenarios []entities.Scenario,
 ) error {
 	clientName := fmt.Sprintf("%sStubServer", processors.MakeExportedName(service.GoName))
 
 	// Create client struct
-	file.P(fmt.Sprintf("type %s struct {\nUnimplemented%sServer\n}", clientName, service.GoName))
+	file.P(
+		fmt.Sprintf(
+			"type %s struct {\nUnimplemented%sServer\n%s%s%s}",
+			clientName,
+			service.GoName,
+			unmatchedField(file),
+			scenariosField(file, scenarios),
+			callsField(file),
+		),
+	)
+	generateResetScenarios(file, clientName, "s", scenarios)
+	generateCallJournal(file, clientName, "s", service, contractService, scenarios)
 
 	// Iterate over the service methods and generate the proper method containing a
 	// switch case based on the Request/Response provided by the user through JSON File
@@ -193,14 +369,39 @@ func generateStubServer(
 		// 'cause the method will be created with a default switch case in order to satisfy
 		// the client interface generated by `protoc-gen-go-grpc`.
 		methodContract := contractService[method.GoName]
-		switchCase, err := generateClientCases(file, method, methodContract)
+
+		if isStreaming(method) {
+			err := generateStreamingStubMethod(file, clientName, method, methodContract)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		returnFunc := withResponseMetadata(
+			fmt.Sprintf(
+				"if err := %s(ctx, %%s, %%s); err != nil {\nreturn nil, err\n}",
+				file.QualifiedGoIdent(dealRuntime.Ident("SetResponseMetadata")),
+			),
+			unaryCaseReturn,
+		)
+
+		unmatched := unaryUnmatchedCall(file, "s.Unmatched", "ctx")
+		switchCase, err := generateScenarioClientCases(
+			file, method, methodContract, scenarios, "s.scenarios",
+			withCallRecord(
+				"s.calls", method, unmatched.requests,
+				withEmptyResponse(emptyResponse(file, method), returnFunc),
+			),
+			unmatched,
+		)
 		if err != nil {
 			return err
 		}
 
 		file.P(
 			fmt.Sprintf(
-				"func (%s) %s(ctx %s, in *%s) (*%s, error) {%s}",
+				"func (s *%s) %s(ctx %s, in *%s) (*%s, error) {%s}",
 				clientName,
 				method.GoName,
 				file.QualifiedGoIdent(contextContext),
@@ -214,57 +415,134 @@ func generateStubServer(
 	return nil
 }
 
+// emptyResponse returns the expression creating an empty response of the method.
+func emptyResponse(file *outputFile, method *protogen.Method) string {
+	return fmt.Sprintf("&%s{}", file.QualifiedGoIdent(method.Output.GoIdent))
+}
+
+func unaryCaseReturn(outcome caseOutcome) string {
+	if outcome.err != "nil" {
+		return fmt.Sprintf("return nil, %s", outcome.err)
+	}
+
+	return fmt.Sprintf("return %s, nil", outcome.responses[0])
+}
+
 func generateClientCases(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	method *protogen.Method,
 	methodContract entities.Method,
+	returnFunc caseReturnFunc,
+	unmatched unmatchedCall,
+) (string, error) {
+	return generateScenarioClientCases(
+		file, method, methodContract, nil, "", returnFunc, unmatched,
+	)
+}
+
+// generateScenarioClientCases is the same as generateClientCases, but the switch also
+// holds the steps of the scenarios, whose state is kept by `states`.
+func generateScenarioClientCases(
+	file *outputFile,
+	method *protogen.Method,
+	methodContract entities.Method,
+	scenarios []entities.Scenario,
+	states string,
+	returnFunc caseReturnFunc,
+	unmatched unmatchedCall,
 ) (string, error) {
 	switchCase := bytes.NewBufferString("switch {")
 
-	err := generateSuccessCases(file, method, methodContract.SuccessCases, switchCase)
+	// Scenario steps come first, the stateless cases are used when no scenario matches
+	err := generateScenarioCases(file, method, scenarios, states, returnFunc, switchCase)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate the success cases: %w", err)
+		return "", err
 	}
 
-	err = generateFailureCases(file, method, methodContract.FailureCases, switchCase)
+	err = generateSuccessCases(file, method, methodContract.SuccessCases, returnFunc, switchCase)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate the failure cases: %w", err)
+		return "", err
 	}
 
-	// Default case if no cases are provided
-	switchCase.WriteString("default: return nil, nil }")
+	err = generateFailureCases(file, method, methodContract.FailureCases, returnFunc, switchCase)
+	if err != nil {
+		return "", err
+	}
+
+	// Requests matching no case finish with the error describing the closest case
+	unmatchedError, err := getUnmatchedError(file, method, methodContract, scenarios, unmatched)
+	if err != nil {
+		return "", err
+	}
+
+	switchCase.WriteString(
+		fmt.Sprintf(
+			"default:\n%s\n}",
+			returnFunc(caseOutcome{
+				err: unmatchedError, header: "nil", trailer: "nil", unmatched: true,
+			}),
+		),
+	)
 
 	return switchCase.String(), nil
 }
 
 func generateSuccessCases(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	method *protogen.Method,
 	cases []entities.SuccessCase,
+	returnFunc caseReturnFunc,
 	writer io.StringWriter,
 ) error {
-	for _, successCase := range cases {
-		requestRepresentation, err := getProtoRepresentation(
-			successCase.Request, method.Input, file,
+	for i, successCase := range cases {
+		if len(successCase.Steps) > 0 {
+			return successCaseError(
+				method, i, successCase,
+				errors.New("'steps' can only be used with bidirectional streaming methods"),
+			)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
 		)
 		if err != nil {
-			return err
+			return successCaseError(method, i, successCase, err)
 		}
 
-		responseRepresentation, err := getProtoRepresentation(
-			successCase.Response, method.Output, file,
+		requestCondition, err := getCaseCondition(
+			method,
+			successCase.Request,
+			successCase.Requests,
+			successCase.RequestMatchers,
+			metadata.request,
+			file,
 		)
 		if err != nil {
-			return err
+			return successCaseError(method, i, successCase, err)
+		}
+
+		responsesRepresentation, err := getResponsesRepresentation(
+			method, successCase.Response, successCase.Responses, file,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
 		}
 
 		_, err = writer.WriteString(
 			fmt.Sprintf(
-				"case %s(in, %s):\n// Description: %s\n return %s, nil\n",
-				file.QualifiedGoIdent(protoPackage.Ident("Equal")),
-				requestRepresentation,
+				"case %s:\n// Description: %s\n %s\n",
+				requestCondition,
 				successCase.Description,
-				responseRepresentation,
+				returnFunc(caseOutcome{
+					description: successCase.Description,
+					responses:   responsesRepresentation,
+					err:         "nil",
+					header:      metadata.header,
+					trailer:     metadata.trailer,
+				}),
 			),
 		)
 		if err != nil {
@@ -276,32 +554,75 @@ func generateSuccessCases(
 }
 
 func generateFailureCases(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	method *protogen.Method,
 	cases []entities.FailureCase,
+	returnFunc caseReturnFunc,
 	writer io.StringWriter,
 ) error {
-	for _, failureCase := range cases {
-		requestRepresentation, err := getProtoRepresentation(
-			failureCase.Request, method.Input, file,
+	for i, failureCase := range cases {
+		if len(failureCase.Steps) > 0 {
+			return failureCaseError(
+				method, i, failureCase,
+				errors.New("'steps' can only be used with bidirectional streaming methods"),
+			)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
 		)
 		if err != nil {
-			return err
+			return failureCaseError(method, i, failureCase, err)
 		}
 
-		if !processors.IsErrorCodeValid(failureCase.Error.ErrorCode) {
-			return fmt.Errorf("invalid error code: %s", failureCase.Error.ErrorCode)
+		requestCondition, err := getCaseCondition(
+			method,
+			failureCase.Request,
+			failureCase.Requests,
+			failureCase.RequestMatchers,
+			metadata.request,
+			file,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		errorRepresentation, err := getErrorRepresentation(file, failureCase.Error)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		// Only streaming methods are able to send responses before failing
+		var responsesRepresentation []string
+		if method.Desc.IsStreamingServer() {
+			responsesRepresentation, err = getResponsesRepresentation(
+				method, nil, failureCase.Responses, file,
+			)
+			if err != nil {
+				return failureCaseError(method, i, failureCase, err)
+			}
+		} else if len(failureCase.Responses) > 0 {
+			return failureCaseError(
+				method, i, failureCase,
+				errors.New("'responses' can only be used with streaming methods"),
+			)
 		}
 
 		_, err = writer.WriteString(
 			fmt.Sprintf(
-				"case %s(in, %s):\n// Description: %s\n return nil, %s(%s, %q)\n",
-				file.QualifiedGoIdent(protoPackage.Ident("Equal")),
-				requestRepresentation,
+				"case %s:\n// Description: %s\n %s\n",
+				requestCondition,
 				failureCase.Description,
-				file.QualifiedGoIdent(grpcStatus.Ident("Error")),
-				file.QualifiedGoIdent(grpcCodes.Ident(failureCase.Error.ErrorCode)),
-				failureCase.Error.Message,
+				returnFunc(caseOutcome{
+					description: failureCase.Description,
+					responses:   responsesRepresentation,
+					err:         errorRepresentation,
+					header:      metadata.header,
+					trailer:     metadata.trailer,
+				}),
 			),
 		)
 		if err != nil {
@@ -312,17 +633,173 @@ func generateFailureCases(
 	return nil
 }
 
+// getResponsesRepresentation returns the representation of every response of a case,
+// unary methods use the single `response` while streaming ones use the `responses` list.
+func getResponsesRepresentation(
+	method *protogen.Method,
+	response interface{},
+	responses []interface{},
+	file *outputFile,
+) ([]string, error) {
+	if !method.Desc.IsStreamingServer() {
+		if len(responses) > 0 {
+			return nil, errors.New("'responses' can only be used with streaming methods")
+		}
+
+		responseRepresentation, err := getProtoRepresentation(response, method.Output, file)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{responseRepresentation}, nil
+	}
+
+	if response != nil {
+		return nil, errors.New("streaming methods must use 'responses' instead of 'response'")
+	}
+
+	return getProtoListRepresentation(responses, method.Output, file)
+}
+
+// getCaseCondition returns the expression used by the switch cases to verify whether
+// the incoming request (`in`) matches the case, client-streaming methods receive the
+// whole list of requests instead of a single one. When the case has request metadata
+// it's also verified against the call context (`ctx`).
+func getCaseCondition(
+	method *protogen.Method,
+	request interface{},
+	requests []interface{},
+	matchers map[string]entities.FieldMatcher,
+	requestMetadata string,
+	file *outputFile,
+) (string, error) {
+	var (
+		condition string
+		err       error
+	)
+	if !method.Desc.IsStreamingClient() {
+		if len(requests) > 0 {
+			return "", errors.New("'requests' can only be used with client-streaming methods")
+		}
+
+		condition, err = getRequestCondition(request, matchers, method.Input, file)
+	} else {
+		if request != nil {
+			return "", errors.New(
+				"client-streaming methods must use 'requests' instead of 'request'",
+			)
+		}
+
+		condition, err = getRequestsCondition(requests, matchers, method.Input, file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if requestMetadata == "nil" {
+		return condition, nil
+	}
+
+	return fmt.Sprintf(
+		"%s && %s(ctx, %s)",
+		condition,
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchRequestMetadata")),
+		requestMetadata,
+	), nil
+}
+
+// getRequestCondition returns the expression used by the switch cases to verify
+// whether the incoming request (`in`) matches the request from the contract.
+func getRequestCondition(
+	request interface{},
+	matchers map[string]entities.FieldMatcher,
+	message *protogen.Message,
+	file *outputFile,
+) (string, error) {
+	requestRepresentation, err := getProtoRepresentation(request, message, file)
+	if err != nil {
+		return "", err
+	}
+
+	formattedMatchers, err := processors.FormatRequestMatchers(
+		file.QualifiedGoIdent, message, matchers,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s(%s)",
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchRequest")),
+		strings.Join(append([]string{"in", requestRepresentation}, formattedMatchers...), ", "),
+	), nil
+}
+
+// getRequestsCondition is the client-streaming version of getRequestCondition,
+// where `in` is the list of requests sent by the client.
+func getRequestsCondition(
+	requests []interface{},
+	matchers map[string]entities.FieldMatcher,
+	message *protogen.Message,
+	file *outputFile,
+) (string, error) {
+	requestsRepresentation, err := getProtoListRepresentation(requests, message, file)
+	if err != nil {
+		return "", err
+	}
+
+	formattedMatchers, err := processors.FormatRequestMatchers(
+		file.QualifiedGoIdent, message, matchers,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	expectedRequests := fmt.Sprintf(
+		"[]%s{%s}",
+		file.QualifiedGoIdent(protoPackage.Ident("Message")),
+		strings.Join(requestsRepresentation, ", "),
+	)
+
+	return fmt.Sprintf(
+		"%s(%s)",
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchRequests")),
+		strings.Join(append([]string{"in", expectedRequests}, formattedMatchers...), ", "),
+	), nil
+}
+
+// getProtoListRepresentation returns the representation of every item of the list.
+func getProtoListRepresentation(
+	items []interface{},
+	message *protogen.Message,
+	file *outputFile,
+) ([]string, error) {
+	representations := make([]string, 0, len(items))
+	for _, item := range items {
+		representation, err := getProtoRepresentation(item, message, file)
+		if err != nil {
+			return nil, err
+		}
+
+		representations = append(representations, representation)
+	}
+
+	return representations, nil
+}
+
 func getProtoRepresentation(
 	r interface{},
 	message *protogen.Message,
-	file *protogen.GeneratedFile,
+	file *outputFile,
 ) (string, error) {
 	marshaledRequest, err := json.Marshal(r)
 	if err != nil {
 		return "", err
 	}
 
-	messageArguments, err := inputOutputToString(file.QualifiedGoIdent, marshaledRequest, message)
+	messageArguments, err := inputOutputToString(
+		file.QualifiedGoIdent, file.registry, marshaledRequest, message,
+	)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate message representation: %w", err)
 	}
@@ -332,12 +809,14 @@ func getProtoRepresentation(
 
 func inputOutputToString(
 	identFunc processors.IdentFunc,
+	registry *processors.MessageRegistry,
 	data []byte,
 	message *protogen.Message,
 ) (string, error) {
-	// This step validates the data provided by the user through JSON file
+	// This step validates the data provided by the user through JSON file,
+	// the registry resolves the types packed in google.protobuf.Any fields
 	methodInputMessage := dynamicpb.NewMessage(message.Desc)
-	err := protojson.Unmarshal(data, methodInputMessage)
+	err := protojson.UnmarshalOptions{Resolver: registry}.Unmarshal(data, methodInputMessage)
 	if err != nil {
 		return "", err
 	}
@@ -346,6 +825,7 @@ func inputOutputToString(
 
 	return processors.FormatMessageField(
 		identFunc,
+		registry,
 		message.GoIdent,
 		fieldsByNumber,
 		methodInputMessage,
@@ -353,18 +833,20 @@ func inputOutputToString(
 }
 
 func generateServerTest(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	service *protogen.Service,
 	contractService entities.Service,
+	scenarios []entities.Scenario,
 ) error {
 	functionName := fmt.Sprintf("%sContractTest", processors.MakeExportedName(service.GoName))
 	file.P(
 		fmt.Sprintf(
-			"func %s(t *%s, ctx %s, server *%s) {",
+			"func %s(t *%s, ctx %s, server *%s, %s) {",
 			functionName,
 			file.QualifiedGoIdent(testingT),
 			file.QualifiedGoIdent(contextContext),
 			file.QualifiedGoIdent(grpcPackage.Ident("Server")),
+			contractTestOptionsParameter(file),
 		),
 	)
 
@@ -399,38 +881,93 @@ func generateServerTest(
 	)
 	file.P(
 		fmt.Sprintf(
-			`clientConn, err := %s(ctx, "bufnet", %s(dialer), %s())`,
+			`clientConn, err := %s(ctx, "bufnet", %s(dialer), %s(%s()))`,
 			file.QualifiedGoIdent(grpcPackage.Ident("DialContext")),
 			file.QualifiedGoIdent(grpcPackage.Ident("WithContextDialer")),
-			file.QualifiedGoIdent(grpcPackage.Ident("WithInsecure")),
+			file.QualifiedGoIdent(grpcPackage.Ident("WithTransportCredentials")),
+			file.QualifiedGoIdent(grpcInsecure.Ident("NewCredentials")),
 		),
 	)
 	file.P(`if err != nil { t.Fatalf("Failed to dial bufnet: %v", err) }`)
 	file.P("defer clientConn.Close()")
 	file.P()
 
-	// We're creating a client this way believing on what go-grpc will generate
-	// and both will be in the same package.
-	file.P(fmt.Sprintf("client := New%sClient(clientConn)", service.GoName))
-	file.P(fmt.Sprintf("run%sTests(t, ctx, client)", service.GoName))
+	file.P(fmt.Sprintf("%sConn(t, ctx, clientConn, opts...)", functionName))
 
 	file.P("}\n")
 
-	return generateSuccessAndFailureTests(file, service, contractService)
+	generateServerTestConn(file, service, functionName)
+	generateServerTestImpl(file, service, functionName)
+
+	return generateSuccessAndFailureTests(file, service, contractService, scenarios)
+}
+
+// generateServerTestConn generates the contract test running against any connection,
+// so providers started apart, e.g. a binary or a container, can be verified as well.
+func generateServerTestConn(file *outputFile, service *protogen.Service, functionName string) {
+	// We're creating a client this way believing on what go-grpc will generate
+	// and both will be in the same package.
+	file.P(
+		fmt.Sprintf(
+			`// %sConn verifies the contract against the provider reachable through conn,
+			// the connection is left open.
+			func %sConn(t *%s, ctx %s, conn %s, %s) {
+				client := New%sClient(conn)
+				run%sTests(t, ctx, client, opts...)
+			}`,
+			functionName,
+			functionName,
+			file.QualifiedGoIdent(testingT),
+			file.QualifiedGoIdent(contextContext),
+			file.QualifiedGoIdent(grpcPackage.Ident("ClientConnInterface")),
+			contractTestOptionsParameter(file),
+			service.GoName,
+			service.GoName,
+		),
+	)
+	file.P()
+}
+
+// generateServerTestImpl generates the contract test calling the implementation directly,
+// without a server or a network in between.
+func generateServerTestImpl(file *outputFile, service *protogen.Service, functionName string) {
+	// The service descriptor is generated by go-grpc in the same package as well.
+	file.P(
+		fmt.Sprintf(
+			`// %sImpl verifies the contract against impl, calling it in the same process
+			// through the server interceptors given as options.
+			func %sImpl(t *%s, ctx %s, impl %sServer, %s) {
+				conn := %s(&%s_ServiceDesc, impl, opts...)
+				%sConn(t, ctx, conn, opts...)
+			}`,
+			functionName,
+			functionName,
+			file.QualifiedGoIdent(testingT),
+			file.QualifiedGoIdent(contextContext),
+			service.GoName,
+			contractTestOptionsParameter(file),
+			file.QualifiedGoIdent(dealRuntime.Ident("NewServerConn")),
+			service.GoName,
+			functionName,
+		),
+	)
+	file.P()
 }
 
 func generateSuccessAndFailureTests(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	service *protogen.Service,
 	contractService entities.Service,
+	scenarios []entities.Scenario,
 ) error {
 	file.P(
 		fmt.Sprintf(
-			"func run%sTests(t *%s, ctx %s, client %sClient) {",
+			"func run%sTests(t *%s, ctx %s, client %sClient, %s) {",
 			service.GoName,
 			file.QualifiedGoIdent(testingT),
 			file.QualifiedGoIdent(contextContext),
 			service.GoName,
+			contractTestOptionsParameter(file),
 		),
 	)
 
@@ -447,13 +984,25 @@ func generateSuccessAndFailureTests(
 				file.QualifiedGoIdent(testingT),
 			),
 		)
+		file.P(
+			fmt.Sprintf(
+				"options := %s(opts...)",
+				file.QualifiedGoIdent(dealRuntime.Ident("NewContractTestOptions")),
+			),
+		)
 
-		err := generateSuccessTestForServer(file, method, methodContract.SuccessCases)
+		successTestFunc := generateSuccessTestForServer
+		failureTestFunc := generateFailureTestForServer
+		if isStreaming(method) {
+			successTestFunc, failureTestFunc = streamingTestFuncs(method)
+		}
+
+		err := successTestFunc(file, method, methodContract.SuccessCases)
 		if err != nil {
 			return err
 		}
 
-		err = generateFailureTestForServer(file, method, methodContract.FailureCases)
+		err = failureTestFunc(file, method, methodContract.FailureCases)
 		if err != nil {
 			return err
 		}
@@ -461,13 +1010,18 @@ func generateSuccessAndFailureTests(
 		file.P("})")
 	}
 
+	// Scenarios run after the stateless cases, since they change the server state
+	if err := generateScenarioTests(file, service, scenarios); err != nil {
+		return err
+	}
+
 	file.P("}")
 
 	return nil
 }
 
 func generateSuccessTestForServer(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	method *protogen.Method,
 	successCases []entities.SuccessCase,
 ) error {
@@ -479,56 +1033,86 @@ func generateSuccessTestForServer(
 	)
 	file.P(
 		fmt.Sprintf(
-			"tests := []struct {name string\nrequest *%s\nexpectedResponse *%s} {",
+			"tests := []struct {name string\nrequest *%s\nexpectedResponse *%s\n%s\n%s} {",
 			file.QualifiedGoIdent(method.Input.GoIdent),
 			file.QualifiedGoIdent(method.Output.GoIdent),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
 		),
 	)
 
-	for _, successCase := range successCases {
+	for i, successCase := range successCases {
 		requestRepresentation, err := getProtoRepresentation(
 			successCase.Request, method.Input, file,
 		)
 		if err != nil {
-			return err
+			return successCaseError(method, i, successCase, err)
 		}
 
 		responseRepresentation, err := getProtoRepresentation(
 			successCase.Response, method.Output, file,
 		)
 		if err != nil {
-			return err
+			return successCaseError(method, i, successCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		given, err := providerStateTestField(file, successCase.Given)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
 		}
 
 		file.P(
 			fmt.Sprintf(
-				"{\nname: \"%s\",\nrequest: %s,\nexpectedResponse: %s,\n},",
+				"{\nname: %q,\nrequest: %s,\nexpectedResponse: %s,\n%s%s},",
 				successCase.Description,
 				requestRepresentation,
 				responseRepresentation,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
 			),
 		)
 	}
 	file.P("}")
 
+	declaration, callOptions := callResponseMetadata(file)
+
 	file.P()
 	file.P(
 		fmt.Sprintf(`for _, test := range tests {
 				t.Run(test.name, func(t *testing.T) {
-					response, err := client.%s(ctx, test.request)
+					options.SetupProviderState(ctx, t, test.given)
+					%s
+					response, err := client.%s(%s, test.request, %s)
 					if err != nil {
 						t.Fatalf("unexpected error happened: %%v", err)
 					}
 
-					if !proto.Equal(response, test.expectedResponse) {
+					if !%s(response, test.expectedResponse) {
 						t.Fatalf(
 							"expected response: %%v, given response: %%v",
 							test.expectedResponse, response,
 						)
 					}
+
+					%s
 				})
 			}`,
+			declaration,
 			method.GoName,
+			outgoingContext(file),
+			callOptions,
+			file.QualifiedGoIdent(dealRuntime.Ident("EqualMessages")),
+			assertResponseMetadata(file),
 		),
 	)
 	file.P("})")
@@ -537,7 +1121,7 @@ func generateSuccessTestForServer(
 }
 
 func generateFailureTestForServer(
-	file *protogen.GeneratedFile,
+	file *outputFile,
 	method *protogen.Method,
 	failureCases []entities.FailureCase,
 ) error {
@@ -550,35 +1134,65 @@ func generateFailureTestForServer(
 	)
 	file.P(
 		fmt.Sprintf(
-			"tests := []struct {name string\nrequest *%s\nexpectedError string} {",
+			"tests := []struct {name string\nrequest *%s\nexpectedError string\n%s\n%s\n%s} {",
 			file.QualifiedGoIdent(method.Input.GoIdent),
+			errorDetailsTestStructField(file),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
 		),
 	)
 
-	for _, failureCase := range failureCases {
+	for i, failureCase := range failureCases {
 		requestRepresentation, err := getProtoRepresentation(
 			failureCase.Request, method.Input, file,
 		)
 		if err != nil {
-			return err
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		given, err := providerStateTestField(file, failureCase.Given)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		detailsField, err := errorDetailsTestField(file, failureCase.Error)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
 		}
 
 		file.P(
 			fmt.Sprintf(
-				"{\nname: \"%s\",\nrequest: %s,\nexpectedError: \"%s\",\n},",
+				"{\nname: %q,\nrequest: %s,\nexpectedError: %q,\n%s%s%s},",
 				failureCase.Description,
 				requestRepresentation,
 				failureCase.Error,
+				detailsField,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
 			),
 		)
 	}
 	file.P("}")
 
+	declaration, callOptions := callResponseMetadata(file)
+
 	file.P()
 	file.P(
 		fmt.Sprintf(`for _, test := range tests {
 				t.Run(test.name, func(t *testing.T) {
-					_, err := client.%s(ctx, test.request)
+					options.SetupProviderState(ctx, t, test.given)
+					%s
+					_, err := client.%s(%s, test.request, %s)
 					if err == nil {
 						t.Fatalf("an error was expected but no one was returned")
 					}
@@ -586,9 +1200,18 @@ func generateFailureTestForServer(
 					if err.Error() != test.expectedError {
 						t.Fatalf("expected error: %%s, given error: %%s", test.expectedError, err)
 					}
+
+					%s
+
+					%s
 				})
 			}`,
+			declaration,
 			method.GoName,
+			outgoingContext(file),
+			callOptions,
+			assertErrorDetails(file),
+			assertResponseMetadata(file),
 		),
 	)
 	file.P("})")
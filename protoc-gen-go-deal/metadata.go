@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+const grpcMetadata = protogen.GoImportPath("google.golang.org/grpc/metadata")
+
+// caseMetadata holds the representation of the metadata of a case,
+// "nil" is used for the missing ones.
+type caseMetadata struct {
+	request string
+	header  string
+	trailer string
+}
+
+func getCaseMetadata(
+	file *outputFile,
+	requestMetadata map[string]string,
+	responseHeaders map[string]string,
+	responseTrailers map[string]string,
+) (caseMetadata, error) {
+	request, err := processors.FormatMetadata(file.QualifiedGoIdent, requestMetadata)
+	if err != nil {
+		return caseMetadata{}, fmt.Errorf("invalid request metadata: %w", err)
+	}
+
+	header, err := processors.FormatMetadata(file.QualifiedGoIdent, responseHeaders)
+	if err != nil {
+		return caseMetadata{}, fmt.Errorf("invalid response headers: %w", err)
+	}
+
+	trailer, err := processors.FormatMetadata(file.QualifiedGoIdent, responseTrailers)
+	if err != nil {
+		return caseMetadata{}, fmt.Errorf("invalid response trailers: %w", err)
+	}
+
+	return caseMetadata{request: request, header: header, trailer: trailer}, nil
+}
+
+// fields returns the struct literal fields holding the metadata using the given names,
+// the missing ones are omitted.
+func (m caseMetadata) fields(requestName string, headerName string, trailerName string) string {
+	fields := strings.Builder{}
+	for _, field := range [][2]string{
+		{requestName, m.request},
+		{headerName, m.header},
+		{trailerName, m.trailer},
+	} {
+		if field[1] != "nil" {
+			fields.WriteString(fmt.Sprintf("%s: %s,\n", field[0], field[1]))
+		}
+	}
+
+	return fields.String()
+}
+
+// usesRequestMetadata reports whether any case of the method matches the request metadata,
+// so the generated method needs to have the call context available.
+func usesRequestMetadata(methodContract entities.Method) bool {
+	for _, successCase := range methodContract.SuccessCases {
+		if len(successCase.RequestMetadata) > 0 {
+			return true
+		}
+	}
+
+	for _, failureCase := range methodContract.FailureCases {
+		if len(failureCase.RequestMetadata) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// streamContext returns the statement declaring the `ctx` used by the case conditions
+// of methods that don't receive it as a parameter.
+func streamContext(methodContract entities.Method, stream string) string {
+	if !usesRequestMetadata(methodContract) {
+		return ""
+	}
+
+	return fmt.Sprintf("ctx := %s.Context()\n", stream)
+}
+
+// withResponseMetadata prepends the statement setting the response metadata of the case
+// to the one returned by returnFunc, statement is formatted with the header and trailer.
+func withResponseMetadata(statement string, returnFunc caseReturnFunc) caseReturnFunc {
+	return func(outcome caseOutcome) string {
+		if outcome.header == "nil" && outcome.trailer == "nil" {
+			return returnFunc(outcome)
+		}
+
+		return fmt.Sprintf(
+			"%s\n%s",
+			fmt.Sprintf(statement, outcome.header, outcome.trailer),
+			returnFunc(outcome),
+		)
+	}
+}
+
+// metadataTestStructFields returns the declaration of the test case fields
+// holding the metadata.
+func metadataTestStructFields(file *outputFile) string {
+	md := file.QualifiedGoIdent(grpcMetadata.Ident("MD"))
+
+	return fmt.Sprintf("metadata %s\nexpectedHeader %s\nexpectedTrailer %s", md, md, md)
+}
+
+// outgoingContext returns the expression attaching the metadata of the test case
+// to the context used in the call.
+func outgoingContext(file *outputFile) string {
+	return fmt.Sprintf(
+		"%s(ctx, test.metadata)",
+		file.QualifiedGoIdent(dealRuntime.Ident("AppendMetadata")),
+	)
+}
+
+// assertResponseMetadata returns the code verifying that the `header` and `trailer`
+// received contain the ones expected by the test case.
+func assertResponseMetadata(file *outputFile) string {
+	return fmt.Sprintf(`if !%s(header, test.expectedHeader) {
+			t.Fatalf("expected header: %%v, given header: %%v", test.expectedHeader, header)
+		}
+
+		if !%s(trailer, test.expectedTrailer) {
+			t.Fatalf("expected trailer: %%v, given trailer: %%v", test.expectedTrailer, trailer)
+		}`,
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchMetadata")),
+		file.QualifiedGoIdent(dealRuntime.Ident("MatchMetadata")),
+	)
+}
+
+// callResponseMetadata returns the declaration of the `header` and `trailer` filled by
+// the call options, and the options themselves.
+func callResponseMetadata(file *outputFile) (string, string) {
+	return fmt.Sprintf(
+			"var header, trailer %s",
+			file.QualifiedGoIdent(grpcMetadata.Ident("MD")),
+		), fmt.Sprintf(
+			"%s(&header), %s(&trailer)",
+			file.QualifiedGoIdent(grpcPackage.Ident("Header")),
+			file.QualifiedGoIdent(grpcPackage.Ident("Trailer")),
+		)
+}
+
+// streamResponseMetadata returns the code reading the `header` and `trailer` of a
+// finished stream. The header error is ignored since a failed stream may not have one.
+func streamResponseMetadata() string {
+	return "header, _ := stream.Header()\ntrailer := stream.Trailer()"
+}
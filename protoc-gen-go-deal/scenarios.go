@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+var errStreamingScenario = errors.New("scenarios can only be used with unary methods")
+
+// validateScenarios verifies whether every step of the scenarios can be generated, steps
+// of methods that don't exist are ignored since they're reported by the strict mode.
+func validateScenarios(service *protogen.Service, scenarios []entities.Scenario) error {
+	for _, scenario := range scenarios {
+		for i, step := range scenario.Steps {
+			method := findMethod(service, step.Method)
+			if method != nil && isStreaming(method) {
+				return scenarioStepError(method, scenario, i, errStreamingScenario)
+			}
+		}
+	}
+
+	return nil
+}
+
+func findMethod(service *protogen.Service, name string) *protogen.Method {
+	for _, method := range service.Methods {
+		if method.GoName == name {
+			return method
+		}
+	}
+
+	return nil
+}
+
+// scenarioStepError is the same as successCaseError, but for scenario steps.
+func scenarioStepError(
+	method *protogen.Method,
+	scenario entities.Scenario,
+	index int,
+	err error,
+) error {
+	step := scenario.Steps[index]
+
+	return caseError(
+		method,
+		fmt.Sprintf("scenario '%s' steps[%d]", scenario.Name, index),
+		step.Description,
+		step.Source,
+		err,
+	)
+}
+
+// generateScenarioCases writes the switch cases of the scenario steps of the method, a step
+// only matches while its scenario is in the step state. The state is kept by `states`, the
+// runtime.Scenarios field of the generated client or stub server.
+func generateScenarioCases(
+	file *outputFile,
+	method *protogen.Method,
+	scenarios []entities.Scenario,
+	states string,
+	returnFunc caseReturnFunc,
+	writer io.StringWriter,
+) error {
+	for _, scenario := range scenarios {
+		for i, step := range scenario.Steps {
+			if step.Method != method.GoName {
+				continue
+			}
+
+			switchCase, err := getScenarioCase(file, method, scenario, step, states, returnFunc)
+			if err != nil {
+				return scenarioStepError(method, scenario, i, err)
+			}
+
+			if _, err = writer.WriteString(switchCase); err != nil {
+				return fmt.Errorf("error writing a scenario case: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func getScenarioCase(
+	file *outputFile,
+	method *protogen.Method,
+	scenario entities.Scenario,
+	step entities.ScenarioStep,
+	states string,
+	returnFunc caseReturnFunc,
+) (string, error) {
+	metadata, err := getCaseMetadata(file, step.RequestMetadata, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	requestCondition, err := getCaseCondition(
+		method, step.Request, nil, step.RequestMatchers, metadata.request, file,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	outcome := caseOutcome{description: step.Description, err: "nil", header: "nil", trailer: "nil"}
+	if step.Error != nil {
+		outcome.err, err = getErrorRepresentation(file, *step.Error)
+	} else {
+		outcome.responses, err = getResponsesRepresentation(method, step.Response, nil, file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The transition must be the last condition, so the state only changes
+	// when the request matches the step
+	return fmt.Sprintf(
+		"case %s && %s.Transition(%q, %q, %q):\n// Scenario: %s, Description: %s\n%s\n",
+		requestCondition,
+		states,
+		scenario.Name,
+		step.State,
+		step.NextState,
+		scenario.Name,
+		step.Description,
+		returnFunc(outcome),
+	), nil
+}
+
+// generateScenarioTests writes a test for each scenario of the service, calling the
+// steps in order against the server.
+func generateScenarioTests(
+	file *outputFile,
+	service *protogen.Service,
+	scenarios []entities.Scenario,
+) error {
+	for _, scenario := range scenarios {
+		file.P(
+			fmt.Sprintf(
+				"t.Run(%q, func(t *%s) {",
+				fmt.Sprintf("Scenario '%s'", scenario.Name),
+				file.QualifiedGoIdent(testingT),
+			),
+		)
+
+		for i, step := range scenario.Steps {
+			method := findMethod(service, step.Method)
+			if method == nil {
+				continue
+			}
+
+			if err := generateScenarioStepTest(file, method, i, step); err != nil {
+				return scenarioStepError(method, scenario, i, err)
+			}
+		}
+
+		file.P("})")
+	}
+
+	return nil
+}
+
+func generateScenarioStepTest(
+	file *outputFile,
+	method *protogen.Method,
+	index int,
+	step entities.ScenarioStep,
+) error {
+	requestRepresentation, err := getProtoRepresentation(step.Request, method.Input, file)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := getCaseMetadata(file, step.RequestMetadata, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	stepContext := "ctx"
+	if metadata.request != "nil" {
+		stepContext = fmt.Sprintf(
+			"%s(ctx, %s)",
+			file.QualifiedGoIdent(dealRuntime.Ident("AppendMetadata")),
+			metadata.request,
+		)
+	}
+
+	file.P(fmt.Sprintf("// Step %d: %s", index, step.Description))
+	file.P("{")
+
+	if step.Error != nil {
+		file.P(
+			fmt.Sprintf(`_, err := client.%s(%s, %s)
+				if err == nil || err.Error() != %q {
+					t.Fatalf("step %d: expected error: %%s, given error: %%v", %q, err)
+				}`,
+				method.GoName,
+				stepContext,
+				requestRepresentation,
+				step.Error.String(),
+				index,
+				step.Error.String(),
+			),
+		)
+		file.P("}")
+
+		return nil
+	}
+
+	responseRepresentation, err := getProtoRepresentation(step.Response, method.Output, file)
+	if err != nil {
+		return err
+	}
+
+	file.P(
+		fmt.Sprintf(`response, err := client.%s(%s, %s)
+			if err != nil {
+				t.Fatalf("step %d: unexpected error happened: %%v", err)
+			}
+
+			expectedResponse := %s
+			if !%s(response, expectedResponse) {
+				t.Fatalf(
+					"step %d: expected response: %%v, given response: %%v",
+					expectedResponse, response,
+				)
+			}`,
+			method.GoName,
+			stepContext,
+			requestRepresentation,
+			index,
+			responseRepresentation,
+			file.QualifiedGoIdent(dealRuntime.Ident("EqualMessages")),
+			index,
+		),
+	)
+	file.P("}")
+
+	return nil
+}
+
+// scenariosField returns the declaration of the field keeping the state of the scenarios,
+// services without scenarios don't need it.
+func scenariosField(file *outputFile, scenarios []entities.Scenario) string {
+	if len(scenarios) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("scenarios %s\n", file.QualifiedGoIdent(dealRuntime.Ident("Scenarios")))
+}
+
+// generateResetScenarios writes the method moving every scenario back to its initial state.
+func generateResetScenarios(
+	file *outputFile,
+	typeName string,
+	receiver string,
+	scenarios []entities.Scenario,
+) {
+	if len(scenarios) == 0 {
+		return
+	}
+
+	file.P()
+	file.P("// ResetScenarios moves every scenario back to its initial state.")
+	file.P(
+		fmt.Sprintf(
+			"func (%s *%s) ResetScenarios() {\n%s.scenarios.Reset()\n}",
+			receiver, typeName, receiver,
+		),
+	)
+	file.P()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+)
+
+// successCaseError tells which success case failed to be generated and where it was
+// written, so it can be found in the contract without guessing. The index is the one of the
+// merged cases, only used when the case has no source.
+func successCaseError(
+	method *protogen.Method,
+	index int,
+	successCase entities.SuccessCase,
+	err error,
+) error {
+	return caseError(
+		method,
+		successCase.Source.CasePath("successCases", index),
+		successCase.Description,
+		successCase.Source,
+		err,
+	)
+}
+
+// failureCaseError is the same as successCaseError, but for failure cases.
+func failureCaseError(
+	method *protogen.Method,
+	index int,
+	failureCase entities.FailureCase,
+	err error,
+) error {
+	return caseError(
+		method,
+		failureCase.Source.CasePath("failureCases", index),
+		failureCase.Description,
+		failureCase.Source,
+		err,
+	)
+}
+
+// caseError prefixes the error with the case location, e.g.
+// `contract.yml:12:11: acme.v1.MyService.MyMethod successCases[0] 'Should do something': ...`
+func caseError(
+	method *protogen.Method,
+	path string,
+	description string,
+	source entities.Source,
+	err error,
+) error {
+	location := fmt.Sprintf("%s %s '%s'", method.Desc.FullName(), path, description)
+	if source.File != "" {
+		location = fmt.Sprintf("%s: %s", source, location)
+	}
+
+	return fmt.Errorf("%s: %w", location, err)
+}
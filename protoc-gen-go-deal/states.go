@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+// providerStateTestStructField returns the declaration of the test case field holding
+// the provider state set up before the case runs.
+func providerStateTestStructField(file *outputFile) string {
+	return fmt.Sprintf("given %s", file.QualifiedGoIdent(dealRuntime.Ident("ProviderState")))
+}
+
+// providerStateTestField returns the test case field holding the provider state of the
+// case, it's omitted when the case doesn't declare one.
+func providerStateTestField(file *outputFile, given *entities.ProviderState) (string, error) {
+	if given == nil {
+		return "", nil
+	}
+
+	if given.Name == "" {
+		return "", errors.New("the provider state must have a name")
+	}
+
+	params, err := processors.FormatStateParams(given.Params)
+	if err != nil {
+		return "", fmt.Errorf("invalid provider state '%s': %w", given.Name, err)
+	}
+
+	return fmt.Sprintf(
+		"given: %s{Name: %q, Params: %s},\n",
+		file.QualifiedGoIdent(dealRuntime.Ident("ProviderState")),
+		given.Name,
+		params,
+	), nil
+}
+
+// contractTestOptionsParameter returns the declaration of the options received by
+// the generated contract test.
+func contractTestOptionsParameter(file *outputFile) string {
+	return fmt.Sprintf(
+		"opts ...%s",
+		file.QualifiedGoIdent(dealRuntime.Ident("ContractTestOption")),
+	)
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+// isStreaming reports whether any side of the method streams messages.
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+// generateStreamingClientMethod generates the contract client method for any kind of
+// streaming RPC, returning a stream built on top of runtime.ClientStream.
+func generateStreamingClientMethod(
+	file *outputFile,
+	clientName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) error {
+	streamName := fmt.Sprintf(
+		"%s%sContractStream",
+		processors.MakeUnexportedName(method.Parent.GoName),
+		method.GoName,
+	)
+
+	// Client-streaming methods only match the cases once the client closes the stream,
+	// so the stream keeps the behavior of the client for unmatched requests and its calls
+	unmatched := ""
+	if method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer() {
+		unmatched = fmt.Sprintf(
+			"unmatched %s\ncalls *%s\n",
+			file.QualifiedGoIdent(dealRuntime.Ident("UnmatchedBehavior")),
+			file.QualifiedGoIdent(dealRuntime.Ident("CallJournal")),
+		)
+	}
+
+	file.P(
+		fmt.Sprintf(
+			"type %s struct {\n*%s\n%s}",
+			streamName,
+			file.QualifiedGoIdent(dealRuntime.Ident("ClientStream")),
+			unmatched,
+		),
+	)
+	file.P()
+
+	if method.Desc.IsStreamingClient() {
+		file.P(
+			fmt.Sprintf(
+				"func (x *%s) Send(m *%s) error {\nreturn x.SendMsg(m)\n}",
+				streamName,
+				file.QualifiedGoIdent(method.Input.GoIdent),
+			),
+		)
+		file.P()
+	}
+
+	if method.Desc.IsStreamingServer() {
+		file.P(
+			fmt.Sprintf(
+				`func (x *%s) Recv() (*%s, error) {
+					m := new(%s)
+					if err := x.RecvMsg(m); err != nil {
+						return nil, err
+					}
+					return m, nil
+				}`,
+				streamName,
+				file.QualifiedGoIdent(method.Output.GoIdent),
+				file.QualifiedGoIdent(method.Output.GoIdent),
+			),
+		)
+		file.P()
+	}
+
+	var (
+		newStream string
+		err       error
+	)
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		newStream, err = newBidiStreamingClientStream(file, streamName, method, methodContract)
+	case method.Desc.IsStreamingClient():
+		newStream, err = newClientStreamingClientStream(file, streamName, method, methodContract)
+	default:
+		newStream, err = newServerStreamingClientStream(file, streamName, method, methodContract)
+	}
+	if err != nil {
+		return err
+	}
+
+	inputParameter := ""
+	if !method.Desc.IsStreamingClient() {
+		inputParameter = fmt.Sprintf("in *%s, ", file.QualifiedGoIdent(method.Input.GoIdent))
+	}
+
+	file.P(
+		fmt.Sprintf(
+			"func (c *%s) %s(ctx %s, %sopts ...%s) (%s_%sClient, error) {%s}",
+			clientName,
+			method.GoName,
+			file.QualifiedGoIdent(contextContext),
+			inputParameter,
+			file.QualifiedGoIdent(grpcPackage.Ident("CallOption")),
+			method.Parent.GoName,
+			method.GoName,
+			newStream,
+		),
+	)
+	file.P()
+
+	return nil
+}
+
+// newServerStreamingClientStream returns the body of a server-streaming client method,
+// the returned stream replays the responses of the matched case.
+func newServerStreamingClientStream(
+	file *outputFile,
+	streamName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) (string, error) {
+	returnFunc := func(outcome caseOutcome) string {
+		newStream := fmt.Sprintf(
+			"&%s{%s(ctx, %s)}",
+			streamName,
+			file.QualifiedGoIdent(dealRuntime.Ident("NewClientStream")),
+			strings.Join(append([]string{outcome.err}, outcome.responses...), ", "),
+		)
+
+		if outcome.header == "nil" && outcome.trailer == "nil" {
+			return fmt.Sprintf("return %s, nil", newStream)
+		}
+
+		return fmt.Sprintf(
+			"stream := %s\nstream.SetResponseMetadata(%s, %s)\nreturn stream, nil",
+			newStream,
+			outcome.header,
+			outcome.trailer,
+		)
+	}
+
+	unmatched := unaryUnmatchedCall(file, "c.Unmatched", "ctx")
+
+	return generateClientCases(
+		file, method, methodContract,
+		withCallRecord("c.calls", method, unmatched.requests, returnFunc), unmatched,
+	)
+}
+
+// newClientStreamingClientStream generates the CloseAndRecv method, where the sent
+// messages are matched against the cases, and returns the body of the client method.
+func newClientStreamingClientStream(
+	file *outputFile,
+	streamName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) (string, error) {
+	returnFunc := withCallRecord(
+		"x.calls",
+		method,
+		"in",
+		withResponseMetadata("x.SetResponseMetadata(%s, %s)", unaryCaseReturn),
+	)
+
+	switchCase, err := generateClientCases(
+		file, method, methodContract, returnFunc,
+		unmatchedCall{behavior: "x.unmatched", ctx: "x.Context()", requests: "in"},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	file.P(
+		fmt.Sprintf(
+			`func (x *%s) CloseAndRecv() (*%s, error) {
+				if err := x.CloseSend(); err != nil {
+					return nil, err
+				}
+				%sin := x.Requests()
+				%s
+			}`,
+			streamName,
+			file.QualifiedGoIdent(method.Output.GoIdent),
+			streamContext(methodContract, "x"),
+			switchCase,
+		),
+	)
+	file.P()
+
+	return fmt.Sprintf(
+		"return &%s{%s(ctx, nil), c.Unmatched, &c.calls}, nil",
+		streamName,
+		file.QualifiedGoIdent(dealRuntime.Ident("NewClientStream")),
+	), nil
+}
+
+// newBidiStreamingClientStream returns the body of a bidirectional streaming client
+// method, the returned stream plays the contract scripts as the messages are sent.
+func newBidiStreamingClientStream(
+	file *outputFile,
+	streamName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) (string, error) {
+	scripts, err := getScriptsRepresentation(file, method, methodContract)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"return &%s{%s(ctx, c.calls.Recorder(%q), %s, %s...)}, nil",
+		streamName,
+		file.QualifiedGoIdent(dealRuntime.Ident("NewScriptedClientStream")),
+		method.GoName,
+		scriptsUnmatched(file, method, "c.Unmatched"),
+		scripts,
+	), nil
+}
+
+// generateStreamingStubMethod generates the stub server method for any kind of
+// streaming RPC.
+func generateStreamingStubMethod(
+	file *outputFile,
+	serverName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) error {
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return generateBidiStreamingStubMethod(file, serverName, method, methodContract)
+	case method.Desc.IsStreamingClient():
+		return generateClientStreamingStubMethod(file, serverName, method, methodContract)
+	default:
+		return generateServerStreamingStubMethod(file, serverName, method, methodContract)
+	}
+}
+
+// streamingTestFuncs returns the functions generating the server tests for the
+// given kind of streaming method.
+func streamingTestFuncs(method *protogen.Method) (
+	func(*outputFile, *protogen.Method, []entities.SuccessCase) error,
+	func(*outputFile, *protogen.Method, []entities.FailureCase) error,
+) {
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return generateBidiStreamingSuccessTest, generateBidiStreamingFailureTest
+	case method.Desc.IsStreamingClient():
+		return generateClientStreamingSuccessTest, generateClientStreamingFailureTest
+	default:
+		return generateServerStreamingSuccessTest, generateServerStreamingFailureTest
+	}
+}
+
+// generateServerStreamingStubMethod generates the stub server method for a
+// server-streaming RPC, every response of the matched case is sent through the stream.
+func generateServerStreamingStubMethod(
+	file *outputFile,
+	serverName string,
+	method *protogen.Method,
+	methodContract entities.Method,
+) error {
+	returnFunc := withResponseMetadata(
+		streamMetadataStatement(file),
+		func(outcome caseOutcome) string {
+			return fmt.Sprintf(
+				"return %s(stream, %s)",
+				file.QualifiedGoIdent(dealRuntime.Ident("SendResponses")),
+				strings.Join(append([]string{outcome.err}, outcome.responses...), ", "),
+			)
+		},
+	)
+
+	unmatched := unaryUnmatchedCall(file, "s.Unmatched", "stream.Context()")
+	switchCase, err := generateClientCases(
+		file, method, methodContract,
+		withCallRecord("s.calls", method, unmatched.requests, returnFunc), unmatched,
+	)
+	if err != nil {
+		return err
+	}
+
+	file.P(
+		fmt.Sprintf(
+			"func (s *%s) %s(in *%s, stream %s_%sServer) error {\n%s%s}",
+			serverName,
+			method.GoName,
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			method.Parent.GoName,
+			method.GoName,
+			streamContext(methodContract, "stream"),
+			switchCase,
+		),
+	)
+	file.P()
+
+	return nil
+}
+
+func generateServerStreamingSuccessTest(
+	file *outputFile,
+	method *protogen.Method,
+	successCases []entities.SuccessCase,
+) error {
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Success Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nrequest *%s\nexpectedResponses []*%s\n%s\n%s} {",
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			file.QualifiedGoIdent(method.Output.GoIdent),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, successCase := range successCases {
+		requestRepresentation, err := getProtoRepresentation(
+			successCase.Request, method.Input, file,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		responsesRepresentation, err := getResponsesRepresentation(
+			method, successCase.Response, successCase.Responses, file,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			successCase.RequestMetadata,
+			successCase.ResponseHeaders,
+			successCase.ResponseTrailers,
+		)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		given, err := providerStateTestField(file, successCase.Given)
+		if err != nil {
+			return successCaseError(method, i, successCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nrequest: %s,\nexpectedResponses: []*%s{%s},\n%s%s},",
+				successCase.Description,
+				requestRepresentation,
+				file.QualifiedGoIdent(method.Output.GoIdent),
+				formatListItems(responsesRepresentation),
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					stream, err := client.%s(%s, test.request)
+					if err != nil {
+						t.Fatalf("unexpected error happened: %%v", err)
+					}
+
+					%s
+
+					if _, err = stream.Recv(); err != %s {
+						t.Fatalf("expected the end of the stream, given: %%v", err)
+					}
+
+					%s
+
+					%s
+				})
+			}`,
+			method.GoName,
+			outgoingContext(file),
+			receiveExpectedResponses(file),
+			file.QualifiedGoIdent(ioPackage.Ident("EOF")),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+func generateServerStreamingFailureTest(
+	file *outputFile,
+	method *protogen.Method,
+	failureCases []entities.FailureCase,
+) error {
+	file.P()
+	file.P(
+		fmt.Sprintf(
+			`t.Run("Failure Cases", func(t *%s) {`,
+			file.QualifiedGoIdent(testingT),
+		),
+	)
+	file.P(
+		fmt.Sprintf(
+			"tests := []struct {name string\nrequest *%s\nexpectedResponses []*%s\n"+
+				"expectedError string\n%s\n%s\n%s} {",
+			file.QualifiedGoIdent(method.Input.GoIdent),
+			file.QualifiedGoIdent(method.Output.GoIdent),
+			errorDetailsTestStructField(file),
+			metadataTestStructFields(file),
+			providerStateTestStructField(file),
+		),
+	)
+
+	for i, failureCase := range failureCases {
+		requestRepresentation, err := getProtoRepresentation(
+			failureCase.Request, method.Input, file,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		responsesRepresentation, err := getResponsesRepresentation(
+			method, nil, failureCase.Responses, file,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		metadata, err := getCaseMetadata(
+			file,
+			failureCase.RequestMetadata,
+			failureCase.ResponseHeaders,
+			failureCase.ResponseTrailers,
+		)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		given, err := providerStateTestField(file, failureCase.Given)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		detailsField, err := errorDetailsTestField(file, failureCase.Error)
+		if err != nil {
+			return failureCaseError(method, i, failureCase, err)
+		}
+
+		file.P(
+			fmt.Sprintf(
+				"{\nname: %q,\nrequest: %s,\nexpectedResponses: []*%s{%s},\n"+
+					"expectedError: %q,\n%s%s%s},",
+				failureCase.Description,
+				requestRepresentation,
+				file.QualifiedGoIdent(method.Output.GoIdent),
+				formatListItems(responsesRepresentation),
+				failureCase.Error,
+				detailsField,
+				metadata.fields("metadata", "expectedHeader", "expectedTrailer"),
+				given,
+			),
+		)
+	}
+	file.P("}")
+
+	file.P()
+	file.P(
+		fmt.Sprintf(`for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					options.SetupProviderState(ctx, t, test.given)
+					stream, err := client.%s(%s, test.request)
+					if err != nil {
+						t.Fatalf("unexpected error happened: %%v", err)
+					}
+
+					%s
+
+					_, err = stream.Recv()
+					if err == nil || err == %s {
+						t.Fatalf("an error was expected but the stream didn't return one: %%v", err)
+					}
+
+					if err.Error() != test.expectedError {
+						t.Fatalf("expected error: %%s, given error: %%s", test.expectedError, err)
+					}
+
+					%s
+
+					%s
+
+					%s
+				})
+			}`,
+			method.GoName,
+			outgoingContext(file),
+			receiveExpectedResponses(file),
+			file.QualifiedGoIdent(ioPackage.Ident("EOF")),
+			assertErrorDetails(file),
+			streamResponseMetadata(),
+			assertResponseMetadata(file),
+		),
+	)
+	file.P("})")
+
+	return nil
+}
+
+// streamMetadataStatement returns the statement used by the stub server to set the
+// response metadata of streaming methods, formatted later with the header and trailer.
+func streamMetadataStatement(file *outputFile) string {
+	return fmt.Sprintf(
+		"if err := %s(stream, %%s, %%s); err != nil {\nreturn err\n}",
+		file.QualifiedGoIdent(dealRuntime.Ident("SetStreamMetadata")),
+	)
+}
+
+// receiveExpectedResponses returns the loop that receives the stream responses
+// comparing them, one by one, with the expected ones.
+func receiveExpectedResponses(file *outputFile) string {
+	return fmt.Sprintf(`for i, expectedResponse := range test.expectedResponses {
+			response, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("unexpected error receiving the response %%d: %%v", i, err)
+			}
+
+			if !%s(response, expectedResponse) {
+				t.Fatalf(
+					"expected response %%d: %%v, given response: %%v",
+					i, expectedResponse, response,
+				)
+			}
+		}`,
+		file.QualifiedGoIdent(dealRuntime.Ident("EqualMessages")),
+	)
+}
+
+// formatListItems joins the items of a list literal placing each one in its own line.
+func formatListItems(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("\n%s,\n", strings.Join(items, ",\n"))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/processors"
+)
+
+// generateStartStub generates the helper running the stub server in memory, returning
+// the client generated by `protoc-gen-go-grpc` connected to it along with the stub server.
+func generateStartStub(file *outputFile, service *protogen.Service) {
+	serviceName := processors.MakeExportedName(service.GoName)
+	stubName := fmt.Sprintf("%sStubServer", serviceName)
+
+	file.P(
+		fmt.Sprintf(
+			`// Start%sStub runs a %s in memory, returning a client connected to it and the
+			// stub itself, so its calls, scenarios and unmatched behavior can be reached.
+			// The stub is stopped once the test finishes.
+			func Start%sStub(t %s, opts ...%s) (%sClient, *%s) {
+				t.Helper()
+
+				stub := &%s{}
+				register := func(server *%s) { Register%sServer(server, stub) }
+				return New%sClient(%s(t, register, opts...)), stub
+			}`,
+			serviceName,
+			stubName,
+			serviceName,
+			file.QualifiedGoIdent(testingPackage.Ident("TB")),
+			file.QualifiedGoIdent(dealRuntime.Ident("StubOption")),
+			service.GoName,
+			stubName,
+			stubName,
+			file.QualifiedGoIdent(grpcPackage.Ident("Server")),
+			service.GoName,
+			service.GoName,
+			file.QualifiedGoIdent(dealRuntime.Ident("StartStub")),
+		),
+	)
+	file.P()
+}
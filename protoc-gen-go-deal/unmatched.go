@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+)
+
+// unmatchedBehaviors maps the values of the `unmatched` option to the runtime behaviors.
+var unmatchedBehaviors = map[string]string{
+	"strict":     "UnmatchedStrict",
+	"permissive": "UnmatchedPermissive",
+}
+
+// unmatchedCall holds the expressions used to build the error of a request matching no
+// case: the behavior set on the generated type, the context and the received requests.
+type unmatchedCall struct {
+	behavior string
+	ctx      string
+	requests string
+}
+
+// unaryUnmatchedCall returns the unmatchedCall of a method receiving a single request.
+func unaryUnmatchedCall(file *outputFile, behavior string, ctx string) unmatchedCall {
+	return unmatchedCall{
+		behavior: behavior,
+		ctx:      ctx,
+		requests: fmt.Sprintf("[]%s{in}", file.QualifiedGoIdent(protoPackage.Ident("Message"))),
+	}
+}
+
+// unmatchedField returns the declaration of the field overriding the behavior chosen by
+// the `unmatched` option, used by the generated client and stub server.
+func unmatchedField(file *outputFile) string {
+	return fmt.Sprintf(
+		"// Unmatched overrides what happens when a request matches no case.\nUnmatched %s\n",
+		file.QualifiedGoIdent(dealRuntime.Ident("UnmatchedBehavior")),
+	)
+}
+
+// scriptsUnmatched returns the runtime.ScriptsUnmatched of a bidirectional streaming
+// method, telling how the stream finishes when a message matches no script.
+func scriptsUnmatched(file *outputFile, method *protogen.Method, behavior string) string {
+	return fmt.Sprintf(
+		"%s{Behavior: %s, Generated: %s, Method: %q}",
+		file.QualifiedGoIdent(dealRuntime.Ident("ScriptsUnmatched")),
+		behavior,
+		file.QualifiedGoIdent(dealRuntime.Ident(file.unmatched)),
+		method.Desc.FullName(),
+	)
+}
+
+// getUnmatchedError returns the expression building the error of a request matching no
+// case of the method, every case and scenario step is given as a candidate so the closest
+// one can be reported.
+func getUnmatchedError(
+	file *outputFile,
+	method *protogen.Method,
+	methodContract entities.Method,
+	scenarios []entities.Scenario,
+	call unmatchedCall,
+) (string, error) {
+	candidates, err := getCandidatesRepresentation(file, method, methodContract, scenarios)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s(%s, %s, %s, %q, %s%s)",
+		file.QualifiedGoIdent(dealRuntime.Ident("Unmatched")),
+		call.ctx,
+		call.behavior,
+		file.QualifiedGoIdent(dealRuntime.Ident(file.unmatched)),
+		method.Desc.FullName(),
+		call.requests,
+		candidates,
+	), nil
+}
+
+func getCandidatesRepresentation(
+	file *outputFile,
+	method *protogen.Method,
+	methodContract entities.Method,
+	scenarios []entities.Scenario,
+) (string, error) {
+	candidates := strings.Builder{}
+
+	for _, scenario := range scenarios {
+		for i, step := range scenario.Steps {
+			if step.Method != method.GoName {
+				continue
+			}
+
+			candidate, err := getCandidateRepresentation(
+				file, method, step.Description, step.Request, nil,
+				step.RequestMatchers, step.RequestMetadata,
+			)
+			if err != nil {
+				return "", scenarioStepError(method, scenario, i, err)
+			}
+			candidates.WriteString(candidate)
+		}
+	}
+
+	for i, successCase := range methodContract.SuccessCases {
+		candidate, err := getCandidateRepresentation(
+			file, method, successCase.Description, successCase.Request, successCase.Requests,
+			successCase.RequestMatchers, successCase.RequestMetadata,
+		)
+		if err != nil {
+			return "", successCaseError(method, i, successCase, err)
+		}
+		candidates.WriteString(candidate)
+	}
+
+	for i, failureCase := range methodContract.FailureCases {
+		candidate, err := getCandidateRepresentation(
+			file, method, failureCase.Description, failureCase.Request, failureCase.Requests,
+			failureCase.RequestMatchers, failureCase.RequestMetadata,
+		)
+		if err != nil {
+			return "", failureCaseError(method, i, failureCase, err)
+		}
+		candidates.WriteString(candidate)
+	}
+
+	return candidates.String(), nil
+}
+
+func getCandidateRepresentation(
+	file *outputFile,
+	method *protogen.Method,
+	description string,
+	request interface{},
+	requests []interface{},
+	matchers map[string]entities.FieldMatcher,
+	requestMetadata map[string]string,
+) (string, error) {
+	// Only client-streaming methods receive many requests
+	if !method.Desc.IsStreamingClient() {
+		requests = []interface{}{request}
+	}
+
+	requestsRepresentation, err := getProtoListRepresentation(requests, method.Input, file)
+	if err != nil {
+		return "", err
+	}
+
+	formattedMatchers, err := processors.FormatRequestMatchers(
+		file.QualifiedGoIdent, method.Input, matchers,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := processors.FormatMetadata(file.QualifiedGoIdent, requestMetadata)
+	if err != nil {
+		return "", fmt.Errorf("invalid request metadata: %w", err)
+	}
+
+	return fmt.Sprintf(
+		",\n%s{\nDescription: %q,\nRequests: []%s{%s},\nMatchers: []%s{%s},\nMetadata: %s,\n}",
+		file.QualifiedGoIdent(dealRuntime.Ident("Candidate")),
+		description,
+		file.QualifiedGoIdent(protoPackage.Ident("Message")),
+		formatListItems(requestsRepresentation),
+		file.QualifiedGoIdent(dealRuntime.Ident("FieldMatcher")),
+		strings.Join(formattedMatchers, ", "),
+		metadata,
+	), nil
+}
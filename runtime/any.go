@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const anyFullName = protoreflect.FullName("google.protobuf.Any")
+
+// EqualMessages is like proto.Equal, but the messages packed in Any fields are compared
+// instead of their bytes, which depend on the order their maps were marshaled. It's used by
+// the generated code to compare the responses.
+func EqualMessages(actual proto.Message, expected proto.Message) bool {
+	if proto.Equal(actual, expected) {
+		return true
+	}
+	if actual == nil || expected == nil {
+		return false
+	}
+
+	actualCopy, expectedCopy := proto.Clone(actual), proto.Clone(expected)
+	repackAny(actualCopy.ProtoReflect())
+	repackAny(expectedCopy.ProtoReflect())
+
+	return proto.Equal(actualCopy, expectedCopy)
+}
+
+// repackAny marshals the messages packed in the Any fields again, deterministically, so
+// Any fields holding the same message have the same bytes. Any fields whose type isn't
+// registered are left untouched.
+func repackAny(message protoreflect.Message) {
+	if !message.IsValid() {
+		return
+	}
+
+	if message.Descriptor().FullName() == anyFullName {
+		repackAnyValue(message)
+		return
+	}
+
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList():
+			repackAnyList(field, value.List())
+		case field.IsMap():
+			repackAnyMap(field, value.Map())
+		case field.Message() != nil:
+			repackAny(value.Message())
+		}
+
+		return true
+	})
+}
+
+func repackAnyList(field protoreflect.FieldDescriptor, list protoreflect.List) {
+	if field.Message() == nil {
+		return
+	}
+
+	for i := 0; i < list.Len(); i++ {
+		repackAny(list.Get(i).Message())
+	}
+}
+
+func repackAnyMap(field protoreflect.FieldDescriptor, m protoreflect.Map) {
+	if field.MapValue().Message() == nil {
+		return
+	}
+
+	m.Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+		repackAny(value.Message())
+		return true
+	})
+}
+
+func repackAnyValue(message protoreflect.Message) {
+	fields := message.Descriptor().Fields()
+	typeURLField, valueField := fields.ByName("type_url"), fields.ByName("value")
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByURL(
+		message.Get(typeURLField).String(),
+	)
+	if err != nil {
+		return
+	}
+
+	packed := messageType.New()
+	if err = proto.Unmarshal(message.Get(valueField).Bytes(), packed.Interface()); err != nil {
+		return
+	}
+	repackAny(packed)
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(packed.Interface())
+	if err != nil {
+		return
+	}
+
+	message.Set(valueField, protoreflect.ValueOfBytes(data))
+}
@@ -0,0 +1,87 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+// packStruct packs a Struct with the given fields, marshaled in the given order.
+func packStruct(t *testing.T, typeURL string, fields ...string) *anypb.Any {
+	t.Helper()
+
+	var value []byte
+	for _, field := range fields {
+		// Concatenated messages are merged, so each field is marshaled on its own
+		data, err := proto.Marshal(&structpb.Struct{
+			Fields: map[string]*structpb.Value{field: structpb.NewStringValue(field)},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		value = append(value, data...)
+	}
+
+	return &anypb.Any{TypeUrl: typeURL, Value: value}
+}
+
+func TestEqualMessages(t *testing.T) {
+	t.Parallel()
+
+	structURL := "type.googleapis.com/google.protobuf.Struct"
+	unknownURL := "type.googleapis.com/acme.Unknown"
+
+	tests := []struct {
+		name          string
+		actual        proto.Message
+		expected      proto.Message
+		expectedEqual bool
+	}{
+		{
+			name:          "should compare the messages packed in the Any",
+			actual:        packStruct(t, structURL, "first", "second"),
+			expected:      packStruct(t, structURL, "second", "first"),
+			expectedEqual: true,
+		},
+		{
+			name:          "should not be equal when the packed messages are different",
+			actual:        packStruct(t, structURL, "first", "second"),
+			expected:      packStruct(t, structURL, "first"),
+			expectedEqual: false,
+		},
+		{
+			name: "should compare the Any fields of messages",
+			actual: &typepb.Type{
+				Options: []*typepb.Option{
+					{Name: "option", Value: packStruct(t, structURL, "first", "second")},
+				},
+			},
+			expected: &typepb.Type{
+				Options: []*typepb.Option{
+					{Name: "option", Value: packStruct(t, structURL, "second", "first")},
+				},
+			},
+			expectedEqual: true,
+		},
+		{
+			name:          "should compare the bytes of unknown types",
+			actual:        packStruct(t, unknownURL, "first", "second"),
+			expected:      packStruct(t, unknownURL, "second", "first"),
+			expectedEqual: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualEqual := runtime.EqualMessages(test.actual, test.expected)
+			if actualEqual != test.expectedEqual {
+				t.Errorf("Given: %v, expected: %v", actualEqual, test.expectedEqual)
+			}
+		})
+	}
+}
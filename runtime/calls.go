@@ -0,0 +1,199 @@
+package runtime
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Call is a call received by the generated contract client or stub server.
+type Call struct {
+	// Method is the Go name of the called method, e.g. `GetUser`.
+	Method string
+	// Case is the description of the matched case, it's empty when no case matched.
+	Case string
+	// Requests holds the received requests, only streaming methods receive more than one.
+	Requests []proto.Message
+	// Code is the status code returned by the call, codes.OK when it succeeded.
+	Code codes.Code
+	Err  error
+	Time time.Time
+}
+
+// CallRecorder records a call of a single method, it's used by the streams that only
+// know the matched case once they're finished.
+type CallRecorder func(caseName string, requests []proto.Message, err error)
+
+// CallJournal keeps every call received by the generated contract client or stub server,
+// it's safe for concurrent use. The zero value is ready to use.
+type CallJournal struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Record adds a call of the method to the journal, an empty caseName means no case matched.
+func (j *CallJournal) Record(method string, caseName string, requests []proto.Message, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.calls = append(j.calls, Call{
+		Method:   method,
+		Case:     caseName,
+		Requests: requests,
+		Code:     status.Code(err),
+		Err:      err,
+		Time:     time.Now(),
+	})
+}
+
+// Recorder returns the CallRecorder of the method.
+func (j *CallJournal) Recorder(method string) CallRecorder {
+	return func(caseName string, requests []proto.Message, err error) {
+		j.Record(method, caseName, requests, err)
+	}
+}
+
+// Calls returns every call received so far, in order.
+func (j *CallJournal) Calls() []Call {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	calls := make([]Call, len(j.calls))
+	copy(calls, j.calls)
+
+	return calls
+}
+
+// Reset forgets every call received so far.
+func (j *CallJournal) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.calls = nil
+}
+
+// CountCalls returns how many calls of the method matched the case,
+// an empty caseName counts every call of the method.
+func (j *CallJournal) CountCalls(method string, caseName string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	count := 0
+	for _, call := range j.calls {
+		if call.Method == method && (caseName == "" || call.Case == caseName) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// AssertCalled fails the test when the method wasn't called with the case,
+// an empty caseName accepts any call of the method.
+func (j *CallJournal) AssertCalled(t testing.TB, method string, caseName string) bool {
+	t.Helper()
+
+	if j.CountCalls(method, caseName) == 0 {
+		t.Errorf("expected a call of %s, given calls: %s", describeCall(method, caseName), j)
+		return false
+	}
+
+	return true
+}
+
+// AssertNotCalled fails the test when the method was called with the case,
+// an empty caseName rejects any call of the method.
+func (j *CallJournal) AssertNotCalled(t testing.TB, method string, caseName string) bool {
+	t.Helper()
+
+	if count := j.CountCalls(method, caseName); count > 0 {
+		t.Errorf(
+			"expected no call of %s, given %d calls: %s", describeCall(method, caseName), count, j,
+		)
+		return false
+	}
+
+	return true
+}
+
+// AssertNumberOfCalls fails the test when the method wasn't called exactly `times` with the
+// case, an empty caseName counts every call of the method.
+func (j *CallJournal) AssertNumberOfCalls(
+	t testing.TB,
+	method string,
+	caseName string,
+	times int,
+) bool {
+	t.Helper()
+
+	if count := j.CountCalls(method, caseName); count != times {
+		t.Errorf(
+			"expected %d calls of %s, given %d calls: %s",
+			times, describeCall(method, caseName), count, j,
+		)
+		return false
+	}
+
+	return true
+}
+
+// AssertAllCasesExercised fails the test when any of the cases, given by method, wasn't
+// matched by a call. It's used by the generated types, which know the cases of the contract.
+func (j *CallJournal) AssertAllCasesExercised(t testing.TB, cases map[string][]string) bool {
+	t.Helper()
+
+	methods := make([]string, 0, len(cases))
+	for method := range cases {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var missing []string
+	for _, method := range methods {
+		for _, caseName := range cases[method] {
+			if j.CountCalls(method, caseName) == 0 {
+				missing = append(missing, describeCall(method, caseName))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		t.Errorf("cases not exercised: %s", strings.Join(missing, ", "))
+		return false
+	}
+
+	return true
+}
+
+// String describes every call of the journal, it's used by the failure messages.
+func (j *CallJournal) String() string {
+	calls := j.Calls()
+	if len(calls) == 0 {
+		return "none"
+	}
+
+	descriptions := make([]string, 0, len(calls))
+	for _, call := range calls {
+		description := describeCall(call.Method, call.Case)
+		if call.Case == "" {
+			description = call.Method + " matching no case"
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func describeCall(method string, caseName string) string {
+	if caseName == "" {
+		return method
+	}
+
+	return method + " '" + caseName + "'"
+}
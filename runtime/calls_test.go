@@ -0,0 +1,126 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestCallJournal_Record(t *testing.T) {
+	t.Parallel()
+
+	journal := &runtime.CallJournal{}
+	request := &typepb.Type{Name: "t"}
+	journal.Record("Find", "Should find the type", []proto.Message{request}, nil)
+	journal.Record("Find", "", []proto.Message{request}, status.Error(codes.NotFound, "not found"))
+
+	calls := journal.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("Wrong number of calls, given: %d expected: 2", len(calls))
+	}
+
+	if calls[0].Case != "Should find the type" || calls[0].Code != codes.OK {
+		t.Errorf("Wrong first call: %+v", calls[0])
+	}
+	if calls[1].Case != "" || calls[1].Code != codes.NotFound {
+		t.Errorf("Wrong second call: %+v", calls[1])
+	}
+	if calls[0].Time.IsZero() || calls[1].Time.Before(calls[0].Time) {
+		t.Errorf("Wrong call times: %v, %v", calls[0].Time, calls[1].Time)
+	}
+
+	journal.Reset()
+	if calls = journal.Calls(); len(calls) != 0 {
+		t.Errorf("Wrong calls after reset: %v", calls)
+	}
+}
+
+func TestCallJournal_Assertions(t *testing.T) {
+	t.Parallel()
+
+	journal := &runtime.CallJournal{}
+	journal.Record("Find", "Should find the type", nil, nil)
+	journal.Record("Find", "Should find the type", nil, nil)
+	journal.Record("List", "", nil, status.Error(codes.Unimplemented, "no case"))
+
+	tests := []struct {
+		name            string
+		assert          func(t testing.TB) bool
+		expectedFailure string
+	}{
+		{
+			name: "should pass when the method was called with the case",
+			assert: func(t testing.TB) bool {
+				return journal.AssertCalled(t, "Find", "Should find the type")
+			},
+		},
+		{
+			name: "should fail when the method wasn't called with the case",
+			assert: func(t testing.TB) bool {
+				return journal.AssertCalled(t, "Find", "Should fail")
+			},
+			expectedFailure: "expected a call of Find 'Should fail', given calls: " +
+				"Find 'Should find the type', Find 'Should find the type', List matching no case",
+		},
+		{
+			name: "should accept any case when it's empty",
+			assert: func(t testing.TB) bool {
+				return journal.AssertCalled(t, "List", "")
+			},
+		},
+		{
+			name: "should fail when the method was called",
+			assert: func(t testing.TB) bool {
+				return journal.AssertNotCalled(t, "List", "")
+			},
+			expectedFailure: "expected no call of List, given 1 calls: " +
+				"Find 'Should find the type', Find 'Should find the type', List matching no case",
+		},
+		{
+			name: "should pass when the method was called the given times",
+			assert: func(t testing.TB) bool {
+				return journal.AssertNumberOfCalls(t, "Find", "Should find the type", 2)
+			},
+		},
+		{
+			name: "should fail when the method was called other times",
+			assert: func(t testing.TB) bool {
+				return journal.AssertNumberOfCalls(t, "Find", "", 1)
+			},
+			expectedFailure: "expected 1 calls of Find, given 2 calls: " +
+				"Find 'Should find the type', Find 'Should find the type', List matching no case",
+		},
+		{
+			name: "should report every case not exercised",
+			assert: func(t testing.TB) bool {
+				return journal.AssertAllCasesExercised(t, map[string][]string{
+					"List": {"Should list the types"},
+					"Find": {"Should find the type", "Should fail"},
+				})
+			},
+			expectedFailure: "cases not exercised: " +
+				"Find 'Should fail', List 'Should list the types'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeT{}
+			passed := test.assert(fake)
+
+			if passed != (test.expectedFailure == "") {
+				t.Errorf("Wrong result, given: %v expected: %v", passed, !passed)
+			}
+			if fake.failure != test.expectedFailure {
+				t.Errorf(
+					"Wrong failure, given: %s expected: %s", fake.failure, test.expectedFailure,
+				)
+			}
+		})
+	}
+}
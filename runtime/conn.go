@@ -0,0 +1,297 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ grpc.ClientConnInterface = &ContractConn{}
+
+var errSendAfterRequest = errors.New("SendMsg called twice on a server-streaming method")
+
+// UnaryHandler plays a unary method, it's called with the request given to Invoke.
+type UnaryHandler func(
+	ctx context.Context,
+	request interface{},
+	opts ...grpc.CallOption,
+) (proto.Message, error)
+
+// StreamHandler opens the stream of a streaming method.
+type StreamHandler func(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStream, error)
+
+// ContractConnOption changes the settings of a ContractConn.
+type ContractConnOption func(conn *ContractConn)
+
+// WithUnaryInterceptors adds interceptors wrapping every unary call, they run in
+// the given order, like the ones given to grpc.WithChainUnaryInterceptor.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ContractConnOption {
+	return func(conn *ContractConn) {
+		conn.unaryInterceptors = append(conn.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds interceptors wrapping every stream, they run in
+// the given order, like the ones given to grpc.WithChainStreamInterceptor.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) ContractConnOption {
+	return func(conn *ContractConn) {
+		conn.streamInterceptors = append(conn.streamInterceptors, interceptors...)
+	}
+}
+
+// ContractConn is a grpc.ClientConnInterface dispatching every call to the handler of its
+// method, it's used by the generated contract conn so clients built on top of it run their
+// interceptors as usual. The interceptors receive a nil *grpc.ClientConn.
+type ContractConn struct {
+	unaryHandlers      map[string]UnaryHandler
+	streamHandlers     map[string]StreamHandler
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+// NewContractConn creates a ContractConn with the handlers of each method, the keys are
+// the full method names, e.g. `/acme.users.v1.UserService/GetUser`.
+func NewContractConn(
+	unaryHandlers map[string]UnaryHandler,
+	streamHandlers map[string]StreamHandler,
+	opts ...ContractConnOption,
+) *ContractConn {
+	conn := &ContractConn{unaryHandlers: unaryHandlers, streamHandlers: streamHandlers}
+	for _, opt := range opts {
+		opt(conn)
+	}
+
+	return conn
+}
+
+// Invoke plays the unary method through the interceptors, filling reply with the response.
+func (c *ContractConn) Invoke(
+	ctx context.Context,
+	method string,
+	args interface{},
+	reply interface{},
+	opts ...grpc.CallOption,
+) error {
+	invoker := grpc.UnaryInvoker(c.invoke)
+	for i := len(c.unaryInterceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.unaryInterceptors[i], invoker
+		invoker = func(
+			ctx context.Context,
+			method string,
+			req interface{},
+			reply interface{},
+			cc *grpc.ClientConn,
+			opts ...grpc.CallOption,
+		) error {
+			return interceptor(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+
+	return invoker(ctx, method, args, reply, nil, opts...)
+}
+
+func (c *ContractConn) invoke(
+	ctx context.Context,
+	method string,
+	args interface{},
+	reply interface{},
+	_ *grpc.ClientConn,
+	opts ...grpc.CallOption,
+) error {
+	handler, exists := c.unaryHandlers[method]
+	if !exists {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	response, err := handler(ctx, args, opts...)
+	if err != nil {
+		return err
+	}
+
+	return fillMessage(reply, response)
+}
+
+// NewStream opens the stream of the method through the interceptors.
+func (c *ContractConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	streamer := grpc.Streamer(c.newStream)
+	for i := len(c.streamInterceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.streamInterceptors[i], streamer
+		streamer = func(
+			ctx context.Context,
+			desc *grpc.StreamDesc,
+			cc *grpc.ClientConn,
+			method string,
+			opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			return interceptor(ctx, desc, cc, method, next, opts...)
+		}
+	}
+
+	return streamer(ctx, desc, nil, method, opts...)
+}
+
+func (c *ContractConn) newStream(
+	ctx context.Context,
+	_ *grpc.StreamDesc,
+	_ *grpc.ClientConn,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	handler, exists := c.streamHandlers[method]
+	if !exists {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	return handler(ctx, opts...)
+}
+
+// fillMessage replaces the content of m with the response, a missing response
+// leaves m empty.
+func fillMessage(m interface{}, response proto.Message) error {
+	message, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected response type %T", m)
+	}
+
+	proto.Reset(message)
+	if response != nil && response.ProtoReflect().IsValid() {
+		proto.Merge(message, response)
+	}
+
+	return nil
+}
+
+// NewServerStreamingStream returns the stream of a server-streaming method for the clients
+// of grpc.ClientConnInterface, which only send the request after opening the stream. The
+// stream given by open is created once the request is sent and the sending side is closed.
+func NewServerStreamingStream(
+	ctx context.Context,
+	open func(request interface{}) (grpc.ClientStream, error),
+) grpc.ClientStream {
+	return &serverStreamingStream{ctx: ctx, open: open}
+}
+
+type serverStreamingStream struct {
+	ctx     context.Context
+	open    func(request interface{}) (grpc.ClientStream, error)
+	mutex   sync.Mutex
+	request interface{}
+	started bool
+	stream  grpc.ClientStream
+	err     error
+}
+
+func (s *serverStreamingStream) Header() (metadata.MD, error) {
+	stream, err := s.opened()
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Header()
+}
+
+func (s *serverStreamingStream) Trailer() metadata.MD {
+	stream, err := s.opened()
+	if err != nil {
+		return metadata.MD{}
+	}
+
+	return stream.Trailer()
+}
+
+// CloseSend opens the stream, its errors are returned by RecvMsg like in a real stream.
+func (s *serverStreamingStream) CloseSend() error {
+	s.start()
+	return nil
+}
+
+func (s *serverStreamingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStreamingStream) SendMsg(m interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		return errSendAfterClose
+	}
+	if s.request != nil {
+		return errSendAfterRequest
+	}
+	s.request = m
+
+	return nil
+}
+
+func (s *serverStreamingStream) RecvMsg(m interface{}) error {
+	stream, err := s.opened()
+	if err != nil {
+		return err
+	}
+
+	return stream.RecvMsg(m)
+}
+
+// start opens the stream with the request sent so far, only the first call opens it.
+func (s *serverStreamingStream) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.started {
+		s.started = true
+		s.stream, s.err = s.open(s.request)
+	}
+}
+
+// opened returns the stream, opening it when it wasn't opened yet.
+func (s *serverStreamingStream) opened() (grpc.ClientStream, error) {
+	s.start()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.stream, s.err
+}
+
+// NewClientStreamingStream adapts the stream of a client-streaming contract client, whose
+// response is returned by closeAndRecv, to the clients of grpc.ClientConnInterface, which
+// receive the response through RecvMsg.
+func NewClientStreamingStream(
+	stream grpc.ClientStream,
+	closeAndRecv func() (proto.Message, error),
+) grpc.ClientStream {
+	return &clientStreamingStream{ClientStream: stream, closeAndRecv: closeAndRecv}
+}
+
+type clientStreamingStream struct {
+	grpc.ClientStream
+	closeAndRecv func() (proto.Message, error)
+	received     bool
+}
+
+func (s *clientStreamingStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+
+	response, err := s.closeAndRecv()
+	if err != nil {
+		return err
+	}
+
+	return fillMessage(m, response)
+}
@@ -0,0 +1,127 @@
+package runtime_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestContractConn_Invoke(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	interceptor := func(name string) grpc.UnaryClientInterceptor {
+		return func(
+			ctx context.Context,
+			method string,
+			req interface{},
+			reply interface{},
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			calls = append(calls, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+
+	conn := runtime.NewContractConn(
+		map[string]runtime.UnaryHandler{
+			"/acme.Types/Find": func(
+				_ context.Context,
+				request interface{},
+				_ ...grpc.CallOption,
+			) (proto.Message, error) {
+				calls = append(calls, "handler")
+				return &typepb.Type{Name: request.(*typepb.Type).Name + "-found"}, nil
+			},
+		},
+		nil,
+		runtime.WithUnaryInterceptors(interceptor("auth"), interceptor("tracing")),
+	)
+
+	reply := &typepb.Type{Name: "stale"}
+	err := conn.Invoke(context.Background(), "/acme.Types/Find", &typepb.Type{Name: "t"}, reply)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reply.Name != "t-found" {
+		t.Errorf("Wrong reply, given: %s expected: t-found", reply.Name)
+	}
+
+	expectedCalls := []string{"auth", "tracing", "handler"}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("Wrong calls, given: %v expected: %v", calls, expectedCalls)
+	}
+
+	err = conn.Invoke(context.Background(), "/acme.Types/Unknown", &typepb.Type{}, reply)
+	expectedError := "rpc error: code = Unimplemented desc = unknown method /acme.Types/Unknown"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Wrong error, given: %v expected: %s", err, expectedError)
+	}
+}
+
+func TestNewServerStreamingStream(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stream := runtime.NewServerStreamingStream(
+		ctx,
+		func(request interface{}) (grpc.ClientStream, error) {
+			name := request.(*typepb.Type).Name
+			return runtime.NewClientStream(ctx, nil, &typepb.Type{Name: name + "-1"}), nil
+		},
+	)
+
+	// Clients of grpc.ClientConnInterface send the request once the stream is open
+	if err := stream.SendMsg(&typepb.Type{Name: "t"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response := &typepb.Type{}
+	if err := stream.RecvMsg(response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Name != "t-1" {
+		t.Errorf("Wrong response, given: %s expected: t-1", response.Name)
+	}
+
+	if err := stream.RecvMsg(response); err != io.EOF {
+		t.Errorf("Wrong error, given: %v expected: %v", err, io.EOF)
+	}
+}
+
+func TestNewClientStreamingStream(t *testing.T) {
+	t.Parallel()
+
+	contractStream := runtime.NewClientStream(context.Background(), nil)
+	stream := runtime.NewClientStreamingStream(contractStream, func() (proto.Message, error) {
+		return &typepb.Type{Name: contractStream.Requests()[0].(*typepb.Type).Name}, nil
+	})
+
+	if err := stream.SendMsg(&typepb.Type{Name: "t"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response := &typepb.Type{}
+	if err := stream.RecvMsg(response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Name != "t" {
+		t.Errorf("Wrong response, given: %s expected: t", response.Name)
+	}
+}
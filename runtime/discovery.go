@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// reflectionV1Desc describes grpc.reflection.v1.ServerReflection. The grpc version this module
+// depends on only ships v1alpha, whose messages are the same on the wire, so the v1 calls
+// are handled by the v1alpha server.
+var reflectionV1Desc = grpc.ServiceDesc{
+	ServiceName: "grpc.reflection.v1.ServerReflection",
+	HandlerType: (*reflectionpb.ServerReflectionServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "ServerReflectionInfo",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				// The v1alpha server is the one registered with this description
+				server := srv.(reflectionpb.ServerReflectionServer) //nolint:errcheck // see above
+				return server.ServerReflectionInfo(reflectionStream{stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "grpc/reflection/v1/reflection.proto",
+}
+
+// reflectionStream is the v1alpha stream of a v1 call.
+type reflectionStream struct {
+	grpc.ServerStream
+}
+
+func (s reflectionStream) Send(response *reflectionpb.ServerReflectionResponse) error {
+	return s.ServerStream.SendMsg(response)
+}
+
+func (s reflectionStream) Recv() (*reflectionpb.ServerReflectionRequest, error) {
+	request := &reflectionpb.ServerReflectionRequest{}
+	if err := s.ServerStream.RecvMsg(request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// reflectionRegistrar keeps the v1alpha server registered by the reflection package,
+// so it can serve the v1 calls as well.
+type reflectionRegistrar struct {
+	*grpc.Server
+	reflectionServer interface{}
+}
+
+func (r *reflectionRegistrar) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	r.Server.RegisterService(desc, impl)
+	r.reflectionServer = impl
+}
+
+// RegisterDiscovery registers the server reflection and the health services, so tools like
+// grpcurl can discover the stub and orchestrators can health-check it. It must be called
+// once the stub services are registered, they're reported as serving when loadErr is nil
+// and as not serving otherwise, along with the overall status of the server.
+// The reflection is served as both grpc.reflection.v1 and grpc.reflection.v1alpha.
+func RegisterDiscovery(server *grpc.Server, loadErr error) *health.Server {
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if loadErr != nil {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", servingStatus)
+	for service := range server.GetServiceInfo() {
+		healthServer.SetServingStatus(service, servingStatus)
+	}
+
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	registrar := &reflectionRegistrar{Server: server}
+	reflection.Register(registrar)
+	server.RegisterService(&reflectionV1Desc, registrar.reflectionServer)
+
+	return healthServer
+}
@@ -0,0 +1,122 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+// listServices asks the server reflection for the name of every service, through the
+// given version of the reflection service, e.g. grpc.reflection.v1.ServerReflection.
+func listServices(t *testing.T, conn grpc.ClientConnInterface, reflectionService string) []string {
+	t.Helper()
+
+	stream, err := conn.NewStream(
+		context.Background(),
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		"/"+reflectionService+"/ServerReflectionInfo",
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every version of the reflection has the same messages on the wire
+	err = stream.SendMsg(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response := &reflectionpb.ServerReflectionResponse{}
+	if err = stream.RecvMsg(response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var services []string
+	for _, service := range response.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	sort.Strings(services)
+
+	return services
+}
+
+func TestStartStub_WithDiscovery(t *testing.T) {
+	t.Parallel()
+
+	conn := runtime.StartStub(
+		t,
+		func(server *grpc.Server) {
+			testpb.RegisterTestServiceServer(server, testpb.UnimplementedTestServiceServer{})
+		},
+		runtime.WithDiscovery(),
+	)
+
+	expectedServices := []string{
+		"grpc.health.v1.Health",
+		"grpc.reflection.v1.ServerReflection",
+		"grpc.reflection.v1alpha.ServerReflection",
+		"grpc.testing.TestService",
+	}
+	for _, reflectionService := range []string{
+		"grpc.reflection.v1.ServerReflection",
+		"grpc.reflection.v1alpha.ServerReflection",
+	} {
+		services := listServices(t, conn, reflectionService)
+		if strings.Join(services, ",") != strings.Join(expectedServices, ",") {
+			t.Errorf(
+				"Wrong services through %s, given: %v expected: %v",
+				reflectionService, services, expectedServices,
+			)
+		}
+	}
+
+	for _, service := range []string{"", "grpc.testing.TestService"} {
+		response, err := healthpb.NewHealthClient(conn).Check(
+			context.Background(), &healthpb.HealthCheckRequest{Service: service},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf(
+				"Wrong status of '%s', given: %v expected: %v",
+				service, response.Status, healthpb.HealthCheckResponse_SERVING,
+			)
+		}
+	}
+}
+
+func TestRegisterDiscovery_NotServingWhenLoadingFails(t *testing.T) {
+	t.Parallel()
+
+	conn := runtime.StartStub(t, func(server *grpc.Server) {
+		testpb.RegisterTestServiceServer(server, testpb.UnimplementedTestServiceServer{})
+		runtime.RegisterDiscovery(server, errors.New("invalid contract"))
+	})
+
+	for _, service := range []string{"", "grpc.testing.TestService"} {
+		response, err := healthpb.NewHealthClient(conn).Check(
+			context.Background(), &healthpb.HealthCheckRequest{Service: service},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf(
+				"Wrong status of '%s', given: %v expected: %v",
+				service, response.Status, healthpb.HealthCheckResponse_NOT_SERVING,
+			)
+		}
+	}
+}
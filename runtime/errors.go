@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// NewError returns a status error carrying the given details, like ErrorInfo or BadRequest,
+// it's used by the generated code when a failure case has details.
+func NewError(code codes.Code, message string, details ...proto.Message) error {
+	statusProto := &spb.Status{Code: int32(code), Message: message}
+
+	for _, detail := range details {
+		anyDetail, err := anypb.New(detail)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to marshal the error details: %v", err)
+		}
+
+		statusProto.Details = append(statusProto.Details, anyDetail)
+	}
+
+	return status.ErrorProto(statusProto)
+}
+
+// MatchErrorDetails reports whether every expected detail is present in the status of err,
+// any other detail is ignored.
+func MatchErrorDetails(err error, expected ...proto.Message) bool {
+	details := status.Convert(err).Proto().GetDetails()
+
+	for _, expectedDetail := range expected {
+		found := false
+		for _, detail := range details {
+			actualDetail, unmarshalErr := detail.UnmarshalNew()
+			if unmarshalErr == nil && EqualMessages(actualDetail, expectedDetail) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
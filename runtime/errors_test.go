@@ -0,0 +1,79 @@
+package runtime_test
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	errorInfo := &errdetails.ErrorInfo{Reason: "TENANT_UNKNOWN", Domain: "acme.com"}
+
+	err := runtime.NewError(codes.PermissionDenied, "unknown tenant", errorInfo)
+
+	if err.Error() != "rpc error: code = PermissionDenied desc = unknown tenant" {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	details := status.Convert(err).Details()
+	if len(details) != 1 || !proto.Equal(details[0].(proto.Message), errorInfo) {
+		t.Fatalf("wrong details: %v", details)
+	}
+}
+
+func TestMatchErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	errorInfo := &errdetails.ErrorInfo{Reason: "TENANT_UNKNOWN"}
+	retryInfo := &errdetails.RetryInfo{}
+
+	tests := []struct {
+		name          string
+		err           error
+		expected      []proto.Message
+		expectedMatch bool
+	}{
+		{
+			name:          "should match when no detail is expected",
+			err:           errors.New("some error"),
+			expected:      nil,
+			expectedMatch: true,
+		},
+		{
+			name:          "should match when every detail is present",
+			err:           runtime.NewError(codes.Internal, "error", retryInfo, errorInfo),
+			expected:      []proto.Message{errorInfo},
+			expectedMatch: true,
+		},
+		{
+			name: "should not match when the detail is different",
+			err: runtime.NewError(
+				codes.Internal, "error", &errdetails.ErrorInfo{Reason: "OTHER"},
+			),
+			expected:      []proto.Message{errorInfo},
+			expectedMatch: false,
+		},
+		{
+			name:          "should not match when the error has no details",
+			err:           status.Error(codes.Internal, "error"),
+			expected:      []proto.Message{errorInfo},
+			expectedMatch: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if runtime.MatchErrorDetails(test.err, test.expected...) != test.expectedMatch {
+				t.Fatalf("wrong match for %v, expected: %v", test.err, test.expectedMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Package runtime holds the helpers used by the code generated by protoc-gen-go-deal.
+// It's not meant to be used directly, the generated code is the one that calls it.
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldMatcher relaxes the comparison of a single request field, identified by its path.
+// A path is a dot separated list of field names, e.g. `user.id`, both the proto and the
+// JSON names are accepted.
+type FieldMatcher struct {
+	path string
+	// rule describes what the matcher expects, it's reported when a request doesn't match.
+	rule  string
+	match func(field protoreflect.FieldDescriptor, value protoreflect.Value, set bool) bool
+}
+
+// Present matches when the field is set, for repeated and map fields it means not empty.
+func Present(path string) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: "to be set",
+		match: func(_ protoreflect.FieldDescriptor, _ protoreflect.Value, set bool) bool {
+			return set
+		},
+	}
+}
+
+// Any matches any value, including an unset field.
+func Any(path string) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: "any value",
+		match: func(protoreflect.FieldDescriptor, protoreflect.Value, bool) bool {
+			return true
+		},
+	}
+}
+
+// Regex matches when a string field, or the name of an enum field, matches the pattern.
+func Regex(path string, pattern string) FieldMatcher {
+	expression := regexp.MustCompile(pattern)
+
+	return FieldMatcher{
+		path: path,
+		rule: fmt.Sprintf("to match %q", pattern),
+		match: func(field protoreflect.FieldDescriptor, value protoreflect.Value, _ bool) bool {
+			normalized, ok := normalizeValue(field, value).(string)
+			return ok && expression.MatchString(normalized)
+		},
+	}
+}
+
+// Prefix matches when the field is a string starting with the given prefix.
+func Prefix(path string, prefix string) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: fmt.Sprintf("to start with %q", prefix),
+		match: func(field protoreflect.FieldDescriptor, value protoreflect.Value, _ bool) bool {
+			normalized, ok := normalizeValue(field, value).(string)
+			return ok && strings.HasPrefix(normalized, prefix)
+		},
+	}
+}
+
+// Range matches when the field is a number between min and max, both inclusive.
+func Range(path string, min float64, max float64) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: fmt.Sprintf("to be between %v and %v", min, max),
+		match: func(field protoreflect.FieldDescriptor, value protoreflect.Value, _ bool) bool {
+			number, ok := normalizeValue(field, value).(float64)
+			return ok && number >= min && number <= max
+		},
+	}
+}
+
+// OneOf matches when the field is equal to one of the given values.
+// Enums can be provided either by name or by number.
+func OneOf(path string, values ...interface{}) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: fmt.Sprintf("to be one of %s", formatCandidates(values)),
+		match: func(field protoreflect.FieldDescriptor, value protoreflect.Value, _ bool) bool {
+			return containsValue(field, value, values)
+		},
+	}
+}
+
+// SubsetOf matches when every item of a repeated field is one of the given values.
+func SubsetOf(path string, values ...interface{}) FieldMatcher {
+	return FieldMatcher{
+		path: path,
+		rule: fmt.Sprintf("every item to be one of %s", formatCandidates(values)),
+		match: func(field protoreflect.FieldDescriptor, value protoreflect.Value, set bool) bool {
+			if !set {
+				return true
+			}
+			if !field.IsList() {
+				return false
+			}
+
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				if !containsValue(field, list.Get(i), values) {
+					return false
+				}
+			}
+
+			return true
+		},
+	}
+}
+
+// MatchRequest reports whether actual matches the expected request. Fields covered by
+// a matcher are checked by it, every other field must be equal to the expected one.
+// Without matchers, it behaves like proto.Equal, except Any fields are equal whenever they
+// pack the same message.
+func MatchRequest(actual proto.Message, expected proto.Message, matchers ...FieldMatcher) bool {
+	if len(matchers) == 0 || actual == nil || !actual.ProtoReflect().IsValid() {
+		return EqualMessages(actual, expected)
+	}
+
+	for _, matcher := range matchers {
+		field, value, set, found := lookupPath(actual.ProtoReflect(), matcher.path)
+		if !found {
+			return false
+		}
+		if !matcher.match(field, value, set) {
+			return false
+		}
+	}
+
+	// Fields covered by matchers were already verified, so we remove them from
+	// both messages and compare the remaining fields as usual.
+	actualCopy := proto.Clone(actual)
+	expectedCopy := proto.Clone(expected)
+	for _, matcher := range matchers {
+		clearPath(actualCopy.ProtoReflect(), matcher.path)
+		clearPath(expectedCopy.ProtoReflect(), matcher.path)
+	}
+
+	return EqualMessages(actualCopy, expectedCopy)
+}
+
+// MatchRequests reports whether every request matches the expected one at the same
+// position, it's used by client-streaming methods. The matchers apply to all of them.
+func MatchRequests(
+	actual []proto.Message,
+	expected []proto.Message,
+	matchers ...FieldMatcher,
+) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+
+	for i := range actual {
+		if !MatchRequest(actual[i], expected[i], matchers...) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookupPath walks through the message following the given path, `found` is false only
+// when the path doesn't exist in the message descriptor. When some message in the middle
+// of the path is not set the field is reported as not set, like the fields that track their
+// presence and are not set.
+func lookupPath(
+	message protoreflect.Message,
+	path string,
+) (protoreflect.FieldDescriptor, protoreflect.Value, bool, bool) {
+	names := strings.Split(path, ".")
+
+	for i, name := range names {
+		field := findField(message.Descriptor(), name)
+		if field == nil {
+			return nil, protoreflect.Value{}, false, false
+		}
+
+		if i == len(names)-1 {
+			// Fields tracking their presence, like the optional ones, have no value
+			// when unset, so they never match a value set to its default
+			if field.HasPresence() && !message.Has(field) {
+				return field, protoreflect.Value{}, false, true
+			}
+
+			return field, message.Get(field), message.Has(field), true
+		}
+
+		if field.Message() == nil || field.IsList() || field.IsMap() {
+			return nil, protoreflect.Value{}, false, false
+		}
+		if !message.Has(field) {
+			return field, protoreflect.Value{}, false, true
+		}
+
+		message = message.Get(field).Message()
+	}
+
+	return nil, protoreflect.Value{}, false, false
+}
+
+func clearPath(message protoreflect.Message, path string) {
+	names := strings.Split(path, ".")
+
+	for i, name := range names {
+		field := findField(message.Descriptor(), name)
+		if field == nil {
+			return
+		}
+
+		if i == len(names)-1 {
+			message.Clear(field)
+			return
+		}
+
+		if !message.Has(field) {
+			return
+		}
+
+		message = message.Mutable(field).Message()
+	}
+}
+
+func findField(
+	descriptor protoreflect.MessageDescriptor,
+	name string,
+) protoreflect.FieldDescriptor {
+	if field := descriptor.Fields().ByName(protoreflect.Name(name)); field != nil {
+		return field
+	}
+
+	return descriptor.Fields().ByJSONName(name)
+}
+
+// normalizeValue converts a scalar field value to the same representation used by the
+// values written in the contract file: strings, booleans and float64 for any number.
+func normalizeValue(field protoreflect.FieldDescriptor, value protoreflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return value.String()
+	case protoreflect.BoolKind:
+		return value.Bool()
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(value.Bytes())
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByNumber(value.Enum())
+		if enumValue == nil {
+			return float64(value.Enum())
+		}
+		return string(enumValue.Name())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(value.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(value.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float()
+	default:
+		return nil
+	}
+}
+
+func containsValue(
+	field protoreflect.FieldDescriptor,
+	value protoreflect.Value,
+	values []interface{},
+) bool {
+	normalized := normalizeValue(field, value)
+	if normalized == nil {
+		return false
+	}
+
+	for _, candidate := range values {
+		if normalized == normalizeCandidate(candidate) {
+			return true
+		}
+
+		// Enums may also be referenced by their numbers
+		if field.Kind() == protoreflect.EnumKind &&
+			normalizeCandidate(candidate) == float64(value.Enum()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatCandidates formats the values given to a matcher, e.g. `["a", "b"]`.
+func formatCandidates(values []interface{}) string {
+	formattedValues := make([]string, 0, len(values))
+	for _, value := range values {
+		formattedValues = append(formattedValues, formatValue(normalizeCandidate(value)))
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(formattedValues, ", "))
+}
+
+func normalizeCandidate(candidate interface{}) interface{} {
+	switch v := candidate.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case string, bool, float64:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
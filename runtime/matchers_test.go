@@ -0,0 +1,357 @@
+package runtime_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestMatchRequest(t *testing.T) {
+	t.Parallel()
+
+	expected := &typepb.Type{
+		Name:          "expected-name",
+		Oneofs:        []string{"first"},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+	}
+
+	tests := []struct {
+		name          string
+		actual        proto.Message
+		matchers      []runtime.FieldMatcher
+		expectedMatch bool
+	}{
+		{
+			name:          "should behave like proto.Equal without matchers",
+			actual:        proto.Clone(expected),
+			matchers:      nil,
+			expectedMatch: true,
+		},
+		{
+			name: "should not match different values without matchers",
+			actual: &typepb.Type{
+				Name:          "another-name",
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      nil,
+			expectedMatch: false,
+		},
+		{
+			name: "should match any value when using Any",
+			actual: &typepb.Type{
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      []runtime.FieldMatcher{runtime.Any("name")},
+			expectedMatch: true,
+		},
+		{
+			name: "should not match when a field is absent using Present",
+			actual: &typepb.Type{
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      []runtime.FieldMatcher{runtime.Present("name")},
+			expectedMatch: false,
+		},
+		{
+			name: "should match using Regex",
+			actual: &typepb.Type{
+				Name:          "another-name",
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      []runtime.FieldMatcher{runtime.Regex("name", "^[a-z]+-name$")},
+			expectedMatch: true,
+		},
+		{
+			name: "should still compare the fields without matchers",
+			actual: &typepb.Type{
+				Name:          "another-name",
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      []runtime.FieldMatcher{runtime.Regex("name", "^[a-z]+-name$")},
+			expectedMatch: false,
+		},
+		{
+			name: "should match nested fields using Prefix",
+			actual: &typepb.Type{
+				Name:          "expected-name",
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file_v2.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers:      []runtime.FieldMatcher{runtime.Prefix("sourceContext.fileName", "file")},
+			expectedMatch: true,
+		},
+		{
+			name: "should match enums by name using OneOf",
+			actual: &typepb.Type{
+				Name:          "expected-name",
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO2,
+			},
+			matchers: []runtime.FieldMatcher{
+				runtime.OneOf("syntax", "SYNTAX_PROTO2", "SYNTAX_PROTO3"),
+			},
+			expectedMatch: true,
+		},
+		{
+			name: "should match repeated fields using SubsetOf",
+			actual: &typepb.Type{
+				Name:          "expected-name",
+				Oneofs:        []string{"second", "first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers: []runtime.FieldMatcher{
+				runtime.SubsetOf("oneofs", "first", "second", "third"),
+			},
+			expectedMatch: true,
+		},
+		{
+			name: "should not match when an item is not in the SubsetOf values",
+			actual: &typepb.Type{
+				Name:          "expected-name",
+				Oneofs:        []string{"fourth"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			},
+			matchers: []runtime.FieldMatcher{
+				runtime.SubsetOf("oneofs", "first", "second", "third"),
+			},
+			expectedMatch: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualMatch := runtime.MatchRequest(test.actual, expected, test.matchers...)
+			if actualMatch != test.expectedMatch {
+				t.Errorf("Given: %v, expected: %v", actualMatch, test.expectedMatch)
+			}
+		})
+	}
+}
+
+func TestMatchRequest_Range(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		number        int32
+		matcher       runtime.FieldMatcher
+		expectedMatch bool
+	}{
+		{
+			name:          "should match a number inside the range",
+			number:        5, //nolint:revive // random number
+			matcher:       runtime.Range("number", 1, 10),
+			expectedMatch: true,
+		},
+		{
+			name:          "should match the boundaries",
+			number:        10, //nolint:revive // random number
+			matcher:       runtime.Range("number", 1, 10),
+			expectedMatch: true,
+		},
+		{
+			name:          "should not match a number outside the range",
+			number:        11, //nolint:revive // random number
+			matcher:       runtime.Range("number", 1, 10),
+			expectedMatch: false,
+		},
+		{
+			name:          "should match an open range",
+			number:        1000, //nolint:revive // random number
+			matcher:       runtime.Range("number", 1, math.Inf(1)),
+			expectedMatch: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualMatch := runtime.MatchRequest(
+				&typepb.Field{Number: test.number},
+				&typepb.Field{},
+				test.matcher,
+			)
+			if actualMatch != test.expectedMatch {
+				t.Errorf("Given: %v, expected: %v", actualMatch, test.expectedMatch)
+			}
+		})
+	}
+}
+
+func TestMatchRequest_Presence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		actual        *pluginpb.Version
+		expected      *pluginpb.Version
+		matchers      []runtime.FieldMatcher
+		expectedMatch bool
+	}{
+		{
+			name:          "should match a field set to the zero value",
+			actual:        &pluginpb.Version{Major: proto.Int32(0)},
+			expected:      &pluginpb.Version{Major: proto.Int32(0)},
+			expectedMatch: true,
+		},
+		{
+			name:          "should not match an unset field expecting the zero value",
+			actual:        &pluginpb.Version{},
+			expected:      &pluginpb.Version{Major: proto.Int32(0)},
+			expectedMatch: false,
+		},
+		{
+			name:          "should not match a field set to the zero value expecting it unset",
+			actual:        &pluginpb.Version{Major: proto.Int32(0)},
+			expected:      &pluginpb.Version{},
+			expectedMatch: false,
+		},
+		{
+			name:          "should match a field set to the zero value using OneOf",
+			actual:        &pluginpb.Version{Major: proto.Int32(0)},
+			expected:      &pluginpb.Version{},
+			matchers:      []runtime.FieldMatcher{runtime.OneOf("major", 0)},
+			expectedMatch: true,
+		},
+		{
+			name:          "should not match an unset field using OneOf with the zero value",
+			actual:        &pluginpb.Version{},
+			expected:      &pluginpb.Version{},
+			matchers:      []runtime.FieldMatcher{runtime.OneOf("major", 0)},
+			expectedMatch: false,
+		},
+		{
+			name:          "should not match an unset field using Present",
+			actual:        &pluginpb.Version{Suffix: proto.String("")},
+			expected:      &pluginpb.Version{},
+			matchers:      []runtime.FieldMatcher{runtime.Present("major")},
+			expectedMatch: false,
+		},
+		{
+			name:          "should match a field set to the zero value using Present",
+			actual:        &pluginpb.Version{Suffix: proto.String("")},
+			expected:      &pluginpb.Version{},
+			matchers:      []runtime.FieldMatcher{runtime.Present("suffix")},
+			expectedMatch: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualMatch := runtime.MatchRequest(test.actual, test.expected, test.matchers...)
+			if actualMatch != test.expectedMatch {
+				t.Errorf("Given: %v, expected: %v", actualMatch, test.expectedMatch)
+			}
+		})
+	}
+}
+
+func TestFieldMatcher_Unmatched(t *testing.T) {
+	t.Parallel()
+
+	request := &typepb.Type{
+		Name:          "given-name",
+		Oneofs:        []string{"first", "second"},
+		Syntax:        typepb.Syntax_SYNTAX_PROTO2,
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+	}
+
+	tests := []struct {
+		name         string
+		request      proto.Message
+		matcher      runtime.FieldMatcher
+		expectedDiff string
+	}{
+		{
+			name:         "should report the value given to Regex",
+			request:      request,
+			matcher:      runtime.Regex("name", "^[0-9]+$"),
+			expectedDiff: `name: expected to match "^[0-9]+$", given "given-name"`,
+		},
+		{
+			name:    "should report the value given to Prefix",
+			request: request,
+			matcher: runtime.Prefix("sourceContext.fileName", "another"),
+			expectedDiff: `sourceContext.fileName: expected to start with "another", ` +
+				`given "file.proto"`,
+		},
+		{
+			name:         "should report the value given to Range",
+			request:      &typepb.Field{Number: 11}, //nolint:revive // random number
+			matcher:      runtime.Range("number", 1, 10),
+			expectedDiff: "number: expected to be between 1 and 10, given 11",
+		},
+		{
+			name:    "should report the value given to OneOf",
+			request: request,
+			matcher: runtime.OneOf("syntax", "SYNTAX_PROTO3", 2),
+			expectedDiff: `syntax: expected to be one of ["SYNTAX_PROTO3", 2], ` +
+				`given "SYNTAX_PROTO2"`,
+		},
+		{
+			name:    "should report the items given to SubsetOf",
+			request: request,
+			matcher: runtime.SubsetOf("oneofs", "first"),
+			expectedDiff: `oneofs: expected every item to be one of ["first"], ` +
+				`given ["first", "second"]`,
+		},
+		{
+			name:         "should report an unset field given to Present",
+			request:      &typepb.Type{},
+			matcher:      runtime.Present("sourceContext"),
+			expectedDiff: "sourceContext: expected to be set, given unset",
+		},
+		{
+			name:         "should report a path that doesn't exist",
+			request:      request,
+			matcher:      runtime.Any("unknown"),
+			expectedDiff: "unknown: expected any value, given no such field",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := runtime.Unmatched(
+				context.Background(),
+				runtime.UnmatchedStrict,
+				runtime.UnmatchedStrict,
+				"acme.Types.Find",
+				[]proto.Message{test.request},
+				runtime.Candidate{
+					Description: "Should find the type",
+					Requests:    []proto.Message{proto.Clone(test.request)},
+					Matchers:    []runtime.FieldMatcher{test.matcher},
+				},
+			)
+
+			expectedError := "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, closest case 'Should find the type': " + test.expectedDiff
+			if err == nil || err.Error() != expectedError {
+				t.Errorf("Wrong error, given: %v expected: %s", err, expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MatchMetadata reports whether every value of expected is present in actual under
+// the same key, any other key or value in actual is ignored.
+func MatchMetadata(actual metadata.MD, expected metadata.MD) bool {
+	for key, expectedValues := range expected {
+		actualValues := actual.Get(key)
+
+		for _, expectedValue := range expectedValues {
+			if !containsString(actualValues, expectedValue) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// MatchRequestMetadata reports whether the metadata of the call matches the expected one.
+// The stub server receives it as incoming metadata, while the contract client, which never
+// goes through the network, reads the metadata attached to the outgoing context.
+func MatchRequestMetadata(ctx context.Context, expected metadata.MD) bool {
+	incoming, _ := metadata.FromIncomingContext(ctx)
+	outgoing, _ := metadata.FromOutgoingContext(ctx)
+
+	return MatchMetadata(metadata.Join(incoming, outgoing), expected)
+}
+
+// AppendMetadata attaches md to the outgoing metadata of ctx, keeping the existing one.
+func AppendMetadata(ctx context.Context, md metadata.MD) context.Context {
+	for key, values := range md {
+		for _, value := range values {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+		}
+	}
+
+	return ctx
+}
+
+// SetCallMetadata fills the grpc.Header and grpc.Trailer call options, it's used by the
+// generated contract client to return the headers and trailers of the matched case.
+func SetCallMetadata(opts []grpc.CallOption, header metadata.MD, trailer metadata.MD) {
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = header.Copy()
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = trailer.Copy()
+		}
+	}
+}
+
+// SetResponseMetadata sets the headers and trailers of a unary call, it's used by the
+// generated stub server to send the metadata of the matched case.
+func SetResponseMetadata(ctx context.Context, header metadata.MD, trailer metadata.MD) error {
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		return err
+	}
+
+	return grpc.SetTrailer(ctx, trailer)
+}
+
+// MetadataSetter is implemented by any grpc stream able to set the response metadata,
+// like grpc.ServerStream.
+type MetadataSetter interface {
+	SetHeader(metadata.MD) error
+	SetTrailer(metadata.MD)
+}
+
+// SetStreamMetadata is the streaming version of SetResponseMetadata.
+func SetStreamMetadata(stream MetadataSetter, header metadata.MD, trailer metadata.MD) error {
+	if header.Len() > 0 {
+		if err := stream.SetHeader(header); err != nil {
+			return err
+		}
+	}
+
+	if trailer.Len() > 0 {
+		stream.SetTrailer(trailer)
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestMatchMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		actual        metadata.MD
+		expected      metadata.MD
+		expectedMatch bool
+	}{
+		{
+			name:          "should match when nothing is expected",
+			actual:        metadata.Pairs("x-tenant-id", "acme"),
+			expected:      nil,
+			expectedMatch: true,
+		},
+		{
+			name:          "should match when the values are equal",
+			actual:        metadata.Pairs("x-tenant-id", "acme"),
+			expected:      metadata.Pairs("x-tenant-id", "acme"),
+			expectedMatch: true,
+		},
+		{
+			name:          "should ignore extra keys and values",
+			actual:        metadata.Pairs("x-tenant-id", "acme", "x-tenant-id", "other", "x", "y"),
+			expected:      metadata.Pairs("x-tenant-id", "acme"),
+			expectedMatch: true,
+		},
+		{
+			name:          "should not match when the value is different",
+			actual:        metadata.Pairs("x-tenant-id", "other"),
+			expected:      metadata.Pairs("x-tenant-id", "acme"),
+			expectedMatch: false,
+		},
+		{
+			name:          "should not match when the key is missing",
+			actual:        nil,
+			expected:      metadata.Pairs("x-tenant-id", "acme"),
+			expectedMatch: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if runtime.MatchMetadata(test.actual, test.expected) != test.expectedMatch {
+				t.Fatalf(
+					"wrong match for %v and %v, expected: %v",
+					test.actual, test.expected, test.expectedMatch,
+				)
+			}
+		})
+	}
+}
+
+func TestMatchRequestMetadata(t *testing.T) {
+	t.Parallel()
+
+	expected := metadata.Pairs("x-tenant-id", "acme")
+
+	incomingCtx := metadata.NewIncomingContext(context.Background(), expected)
+	if !runtime.MatchRequestMetadata(incomingCtx, expected) {
+		t.Fatal("the incoming metadata should be matched")
+	}
+
+	outgoingCtx := runtime.AppendMetadata(context.Background(), expected)
+	if !runtime.MatchRequestMetadata(outgoingCtx, expected) {
+		t.Fatal("the outgoing metadata should be matched")
+	}
+
+	if runtime.MatchRequestMetadata(context.Background(), expected) {
+		t.Fatal("an empty context should not be matched")
+	}
+}
+
+func TestSetCallMetadata(t *testing.T) {
+	t.Parallel()
+
+	var header, trailer metadata.MD
+	runtime.SetCallMetadata(
+		[]grpc.CallOption{grpc.Header(&header), grpc.Trailer(&trailer)},
+		metadata.Pairs("x-header", "header"),
+		metadata.Pairs("x-trailer", "trailer"),
+	)
+
+	if !runtime.MatchMetadata(header, metadata.Pairs("x-header", "header")) {
+		t.Fatalf("wrong header: %v", header)
+	}
+
+	if !runtime.MatchMetadata(trailer, metadata.Pairs("x-trailer", "trailer")) {
+		t.Fatalf("wrong trailer: %v", trailer)
+	}
+}
@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// DialProvider connects to a provider running apart, e.g. a binary or a container, so its
+// contract can be verified by the generated `<Service>ContractTestConn`. The dial options set
+// the transport security and credentials, plaintext providers are dialed with
+// grpc.WithTransportCredentials(insecure.NewCredentials()).
+// The connection is closed once the test finishes.
+func DialProvider(
+	t testing.TB,
+	ctx context.Context,
+	address string,
+	opts ...grpc.DialOption,
+) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		t.Fatalf("failed to dial the provider at '%s': %v", address, err)
+		return nil
+	}
+
+	t.Cleanup(func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			t.Errorf("failed to close the provider connection: %v", closeErr)
+		}
+	})
+
+	return conn
+}
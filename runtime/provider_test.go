@@ -0,0 +1,61 @@
+package runtime_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestDialProvider(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		if serveErr := server.Serve(listener); serveErr != nil {
+			t.Errorf("Unexpected error: %v", serveErr)
+		}
+	}()
+	defer server.Stop()
+
+	ctx := context.Background()
+	conn := runtime.DialProvider(
+		t, ctx, listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf(
+			"Wrong status, given: %v expected: %v",
+			response.Status, healthpb.HealthCheckResponse_SERVING,
+		)
+	}
+}
+
+func TestDialProvider_FailsWithoutTransportSecurity(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeT{}
+	conn := runtime.DialProvider(fake, context.Background(), "localhost:50051")
+
+	expectedFailure := "failed to dial the provider at 'localhost:50051': grpc: no transport " +
+		"security set (use grpc.WithInsecure() explicitly or set credentials)"
+	if conn != nil || fake.failure != expectedFailure {
+		t.Errorf("Wrong failure, given: %s expected: %s", fake.failure, expectedFailure)
+	}
+}
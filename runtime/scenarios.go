@@ -0,0 +1,58 @@
+package runtime
+
+import "sync"
+
+// ScenarioStarted is the state of every scenario before its first transition.
+const ScenarioStarted = "Started"
+
+// Scenarios keeps the current state of each scenario, it's used by the generated contract
+// client and stub server to play stateful scenarios. The zero value is ready to use.
+type Scenarios struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+// State returns the current state of the scenario.
+func (s *Scenarios) State(scenario string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state(scenario)
+}
+
+func (s *Scenarios) state(scenario string) string {
+	if state, exists := s.states[scenario]; exists {
+		return state
+	}
+
+	return ScenarioStarted
+}
+
+// Transition moves the scenario to `next` when it's in the `required` state, it reports
+// whether the scenario was in the required state. An empty `required` matches any state,
+// while an empty `next` keeps the current one.
+func (s *Scenarios) Transition(scenario string, required string, next string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if required != "" && s.state(scenario) != required {
+		return false
+	}
+
+	if next != "" {
+		if s.states == nil {
+			s.states = make(map[string]string)
+		}
+		s.states[scenario] = next
+	}
+
+	return true
+}
+
+// Reset moves every scenario back to ScenarioStarted.
+func (s *Scenarios) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states = nil
+}
@@ -0,0 +1,75 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestScenarios(t *testing.T) {
+	t.Parallel()
+
+	var scenarios runtime.Scenarios
+
+	steps := []struct {
+		name          string
+		required      string
+		next          string
+		expectedMatch bool
+		expectedState string
+	}{
+		{
+			name:          "should not match a state other than the current one",
+			required:      "Created",
+			next:          "Deleted",
+			expectedMatch: false,
+			expectedState: runtime.ScenarioStarted,
+		},
+		{
+			name:          "should move from the initial state",
+			required:      runtime.ScenarioStarted,
+			next:          "Created",
+			expectedMatch: true,
+			expectedState: "Created",
+		},
+		{
+			name:          "should keep the state when there's no next state",
+			required:      "Created",
+			next:          "",
+			expectedMatch: true,
+			expectedState: "Created",
+		},
+		{
+			name:          "should match any state when there's no required state",
+			required:      "",
+			next:          "Deleted",
+			expectedMatch: true,
+			expectedState: "Deleted",
+		},
+	}
+
+	// The steps share the same scenario, so they must run in order
+	for _, step := range steps {
+		match := scenarios.Transition("lifecycle", step.required, step.next)
+		if match != step.expectedMatch {
+			t.Fatalf(
+				"%s: wrong match, given: %v expected: %v", step.name, match, step.expectedMatch,
+			)
+		}
+
+		if state := scenarios.State("lifecycle"); state != step.expectedState {
+			t.Fatalf(
+				"%s: wrong state, given: %s expected: %s", step.name, state, step.expectedState,
+			)
+		}
+	}
+
+	if state := scenarios.State("another"); state != runtime.ScenarioStarted {
+		t.Fatalf("Scenarios should be independent, given: %s", state)
+	}
+
+	scenarios.Reset()
+	if state := scenarios.State("lifecycle"); state != runtime.ScenarioStarted {
+		t.Fatalf("Reset should move back to the initial state, given: %s", state)
+	}
+}
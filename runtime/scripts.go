@@ -0,0 +1,294 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Step is a single interaction of a bidirectional streaming case,
+// once Request is received every one of the Responses is sent back.
+type Step struct {
+	Request   proto.Message
+	Matchers  []FieldMatcher
+	Responses []proto.Message
+}
+
+// Script is the ordered list of steps of a bidirectional streaming case. Once every
+// step is done the stream finishes with Err, a nil Err means it finishes successfully.
+// The script is only played when the request metadata matches Metadata, and the
+// stream returns Header and Trailer as its response metadata.
+type Script struct {
+	// Description is the description of the case, it's used to record the calls.
+	Description string
+	Steps       []Step
+	Err         error
+	Metadata    metadata.MD
+	Header      metadata.MD
+	Trailer     metadata.MD
+}
+
+// ScriptsUnmatched tells how a bidirectional stream is finished when its messages
+// match no script, the fields are given to Unmatched along with the method full name.
+type ScriptsUnmatched struct {
+	Behavior  UnmatchedBehavior
+	Generated UnmatchedBehavior
+	Method    string
+}
+
+// scriptPlayer moves the scripts forward as the requests arrive, keeping only the
+// ones matching every request received so far. The first remaining script drives
+// the responses, so the order of the cases is respected.
+type scriptPlayer struct {
+	ctx        context.Context
+	unmatched  ScriptsUnmatched
+	scripts    []Script
+	candidates []Script
+	requests   []proto.Message
+	step       int
+	finished   *Script
+}
+
+func newScriptPlayer(
+	ctx context.Context,
+	unmatched ScriptsUnmatched,
+	scripts []Script,
+) *scriptPlayer {
+	candidates := make([]Script, 0, len(scripts))
+	for _, script := range scripts {
+		if MatchRequestMetadata(ctx, script.Metadata) {
+			candidates = append(candidates, script)
+		}
+	}
+
+	return &scriptPlayer{
+		ctx:        ctx,
+		unmatched:  unmatched,
+		scripts:    scripts,
+		candidates: candidates,
+	}
+}
+
+// receive returns the responses for the given request, `done` is true when the stream
+// must be finished with err right after sending them. When no script matches, the
+// stream is finished with the error of the unmatched behavior.
+func (p *scriptPlayer) receive(request proto.Message) ([]proto.Message, bool, error) {
+	p.requests = append(p.requests, request)
+
+	candidates := make([]Script, 0, len(p.candidates))
+	for _, script := range p.candidates {
+		if len(script.Steps) <= p.step {
+			continue
+		}
+
+		step := script.Steps[p.step]
+		if MatchRequest(request, step.Request, step.Matchers...) {
+			candidates = append(candidates, script)
+		}
+	}
+
+	if len(candidates) == 0 {
+		err := p.stepUnmatched(request)
+		p.candidates = nil
+
+		return nil, true, err
+	}
+
+	p.candidates = candidates
+	p.step++
+
+	script := candidates[0]
+	responses := script.Steps[p.step-1].Responses
+
+	// A failing script doesn't wait for the client, it fails right after its last step
+	if script.Err != nil && len(script.Steps) == p.step {
+		p.finished = &script
+		return responses, true, script.Err
+	}
+
+	return responses, false, nil
+}
+
+// close returns how the stream must be finished once the client closes its sending side.
+func (p *scriptPlayer) close() error {
+	for i := range p.candidates {
+		if len(p.candidates[i].Steps) == p.step {
+			p.finished = &p.candidates[i]
+			return p.finished.Err
+		}
+	}
+
+	// No remaining script ends with the messages sent by the client
+	candidates := make([]Candidate, 0, len(p.candidates))
+	for _, script := range p.reported() {
+		requests := make([]proto.Message, 0, len(script.Steps))
+		for _, step := range script.Steps {
+			requests = append(requests, step.Request)
+		}
+
+		candidates = append(candidates, Candidate{
+			Description: script.Description,
+			Requests:    requests,
+			Metadata:    script.Metadata,
+		})
+	}
+
+	return p.unmatchedError(p.requests, candidates)
+}
+
+// stepUnmatched returns the error of a request matching the current step of no script,
+// the step of every remaining script is given as a candidate.
+func (p *scriptPlayer) stepUnmatched(request proto.Message) error {
+	candidates := make([]Candidate, 0, len(p.candidates))
+	for _, script := range p.reported() {
+		if len(script.Steps) <= p.step {
+			continue
+		}
+
+		step := script.Steps[p.step]
+		candidates = append(candidates, Candidate{
+			Description: script.Description,
+			Requests:    []proto.Message{step.Request},
+			Matchers:    step.Matchers,
+			Metadata:    script.Metadata,
+		})
+	}
+
+	return p.unmatchedError([]proto.Message{request}, candidates)
+}
+
+// reported returns the scripts compared against the requests once nothing matches.
+// Before the first request every script is reported, so the closest one may be a
+// script with different metadata.
+func (p *scriptPlayer) reported() []Script {
+	if p.step == 0 {
+		return p.scripts
+	}
+
+	return p.candidates
+}
+
+func (p *scriptPlayer) unmatchedError(requests []proto.Message, candidates []Candidate) error {
+	return Unmatched(
+		p.ctx,
+		p.unmatched.Behavior,
+		p.unmatched.Generated,
+		p.unmatched.Method,
+		requests,
+		candidates...,
+	)
+}
+
+// header returns the headers of the script being played, or of the one that
+// finished the stream.
+func (p *scriptPlayer) header() metadata.MD {
+	if p.finished != nil {
+		return p.finished.Header
+	}
+	if len(p.candidates) > 0 {
+		return p.candidates[0].Header
+	}
+
+	return nil
+}
+
+// trailer returns the trailers of the script that finished the stream.
+func (p *scriptPlayer) trailer() metadata.MD {
+	if p.finished != nil {
+		return p.finished.Trailer
+	}
+
+	return nil
+}
+
+// finishedCase returns the description of the script that finished the stream, it's empty
+// when no script matched the requests.
+func (p *scriptPlayer) finishedCase() string {
+	if p.finished != nil {
+		return p.finished.Description
+	}
+
+	return ""
+}
+
+// ReplayScripts plays the scripts through the stream, it's used by the generated stub
+// server to implement bidirectional streaming methods. Once the stream is finished the
+// call is given to record, when it's not nil. Messages matching no script finish the
+// stream as told by unmatched.
+func ReplayScripts(
+	stream grpc.ServerStream,
+	newRequest func() proto.Message,
+	record CallRecorder,
+	unmatched ScriptsUnmatched,
+	scripts ...Script,
+) error {
+	player := newScriptPlayer(stream.Context(), unmatched, scripts)
+
+	requests, err := playScripts(stream, newRequest, player)
+	if record != nil {
+		record(player.finishedCase(), requests, err)
+	}
+
+	return err
+}
+
+// playScripts moves the player forward until the stream is finished, returning
+// every received request.
+func playScripts(
+	stream grpc.ServerStream,
+	newRequest func() proto.Message,
+	player *scriptPlayer,
+) ([]proto.Message, error) {
+	// Headers can only be set once, before the first response is sent
+	headerSet := false
+	setHeader := func() error {
+		if headerSet {
+			return nil
+		}
+		headerSet = true
+
+		return SetStreamMetadata(stream, player.header(), nil)
+	}
+	finish := func(err error) error {
+		if headerErr := setHeader(); headerErr != nil {
+			return headerErr
+		}
+
+		if setErr := SetStreamMetadata(stream, nil, player.trailer()); setErr != nil {
+			return setErr
+		}
+
+		return err
+	}
+
+	var requests []proto.Message
+	for {
+		request := newRequest()
+
+		err := stream.RecvMsg(request)
+		if errors.Is(err, io.EOF) {
+			return requests, finish(player.close())
+		}
+		if err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+
+		responses, done, err := player.receive(request)
+		if len(responses) > 0 {
+			if headerErr := setHeader(); headerErr != nil {
+				return requests, headerErr
+			}
+		}
+		if sendErr := SendResponses(stream, nil, responses...); sendErr != nil {
+			return requests, sendErr
+		}
+		if done {
+			return requests, finish(err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestNewScriptedClientStream(t *testing.T) {
+	t.Parallel()
+
+	scriptError := errors.New("script error")
+	scripts := []runtime.Script{
+		{
+			Steps: []runtime.Step{
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Responses: []proto.Message{&typepb.Field{Name: "hello"}},
+				},
+				{
+					Request:   &typepb.Field{Name: "how are you?"},
+					Responses: []proto.Message{&typepb.Field{Name: "fine"}},
+				},
+			},
+			Err: nil,
+		},
+		{
+			Steps: []runtime.Step{
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Matchers:  []runtime.FieldMatcher{runtime.Any("number")},
+					Responses: []proto.Message{&typepb.Field{Name: "hello"}},
+				},
+				{
+					Request:   &typepb.Field{Name: "bye"},
+					Responses: []proto.Message{&typepb.Field{Name: "ciao"}},
+				},
+			},
+			Err: scriptError,
+		},
+	}
+
+	tests := []struct {
+		name              string
+		unmatched         runtime.UnmatchedBehavior
+		requests          []proto.Message
+		expectedResponses []proto.Message
+		expectedError     error
+		expectedCode      codes.Code
+	}{
+		{
+			name: "should play the first script until the end",
+			requests: []proto.Message{
+				&typepb.Field{Name: "hi"},
+				&typepb.Field{Name: "how are you?"},
+			},
+			expectedResponses: []proto.Message{
+				&typepb.Field{Name: "hello"},
+				&typepb.Field{Name: "fine"},
+			},
+			expectedError: io.EOF,
+		},
+		{
+			name: "should move to the script matching every request",
+			requests: []proto.Message{
+				&typepb.Field{Name: "hi"},
+				&typepb.Field{Name: "bye"},
+			},
+			expectedResponses: []proto.Message{
+				&typepb.Field{Name: "hello"},
+				&typepb.Field{Name: "ciao"},
+			},
+			expectedError: scriptError,
+		},
+		{
+			name: "should apply the step matchers",
+			requests: []proto.Message{
+				&typepb.Field{Name: "hi", Number: 42}, //nolint:revive // random number
+				&typepb.Field{Name: "bye"},
+			},
+			expectedResponses: []proto.Message{
+				&typepb.Field{Name: "hello"},
+				&typepb.Field{Name: "ciao"},
+			},
+			expectedError: scriptError,
+		},
+		{
+			name:              "should fail the stream when no script matches",
+			unmatched:         runtime.UnmatchedStrict,
+			requests:          []proto.Message{&typepb.Field{Name: "unknown"}},
+			expectedResponses: nil,
+			expectedCode:      codes.Unimplemented,
+		},
+		{
+			name:              "should fail the stream when a script isn't finished",
+			unmatched:         runtime.UnmatchedStrict,
+			requests:          []proto.Message{&typepb.Field{Name: "hi"}},
+			expectedResponses: []proto.Message{&typepb.Field{Name: "hello"}},
+			expectedCode:      codes.Unimplemented,
+		},
+		{
+			name:              "should finish a permissive stream when no script matches",
+			unmatched:         runtime.UnmatchedPermissive,
+			requests:          []proto.Message{&typepb.Field{Name: "unknown"}},
+			expectedResponses: nil,
+			expectedError:     io.EOF,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := runtime.NewScriptedClientStream(
+				context.Background(),
+				nil,
+				runtime.ScriptsUnmatched{
+					Behavior:  test.unmatched,
+					Generated: runtime.UnmatchedStrict,
+					Method:    "acme.Chat",
+				},
+				scripts...,
+			)
+
+			for i, request := range test.requests {
+				if err := stream.SendMsg(request); err != nil {
+					t.Fatalf("unexpected error sending the request %d: %v", i, err)
+				}
+			}
+			if err := stream.CloseSend(); err != nil {
+				t.Fatalf("unexpected error closing the stream: %v", err)
+			}
+
+			for i, expectedResponse := range test.expectedResponses {
+				response := &typepb.Field{}
+				if err := stream.RecvMsg(response); err != nil {
+					t.Fatalf("unexpected error receiving the response %d: %v", i, err)
+				}
+
+				if !proto.Equal(response, expectedResponse) {
+					t.Fatalf("Given: %v, expected: %v", response, expectedResponse)
+				}
+			}
+
+			err := stream.RecvMsg(&typepb.Field{})
+			if test.expectedCode != codes.OK {
+				if status.Code(err) != test.expectedCode {
+					t.Fatalf("wrong code, given: %v expected: %v", err, test.expectedCode)
+				}
+				return
+			}
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("wrong error, given: %v expected: %v", err, test.expectedError)
+			}
+		})
+	}
+}
+
+type serverStreamMock struct {
+	grpc.ServerStream
+	ctx       context.Context
+	requests  []proto.Message
+	responses []proto.Message
+	header    metadata.MD
+	trailer   metadata.MD
+}
+
+func (s *serverStreamMock) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStreamMock) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *serverStreamMock) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *serverStreamMock) SendMsg(m interface{}) error {
+	s.responses = append(s.responses, m.(proto.Message))
+	return nil
+}
+
+func (s *serverStreamMock) RecvMsg(m interface{}) error {
+	if len(s.requests) == 0 {
+		return io.EOF
+	}
+
+	proto.Merge(m.(proto.Message), s.requests[0])
+	s.requests = s.requests[1:]
+
+	return nil
+}
+
+func TestReplayScripts(t *testing.T) {
+	t.Parallel()
+
+	scriptError := errors.New("script error")
+	stream := &serverStreamMock{
+		ctx: metadata.NewIncomingContext(
+			context.Background(), metadata.Pairs("x-tenant-id", "acme"),
+		),
+		requests: []proto.Message{
+			&typepb.Field{Name: "hi"},
+			&typepb.Field{Name: "bye"},
+			&typepb.Field{Name: "never received"},
+		},
+	}
+
+	journal := &runtime.CallJournal{}
+	err := runtime.ReplayScripts(
+		stream,
+		func() proto.Message { return &typepb.Field{} },
+		journal.Recorder("Chat"),
+		runtime.ScriptsUnmatched{},
+		runtime.Script{
+			Steps: []runtime.Step{
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Responses: []proto.Message{&typepb.Field{Name: "wrong tenant"}},
+				},
+			},
+			Metadata: metadata.Pairs("x-tenant-id", "other"),
+		},
+		runtime.Script{
+			Description: "Should say goodbye",
+			Steps: []runtime.Step{
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Responses: []proto.Message{&typepb.Field{Name: "hello"}},
+				},
+				{
+					Request:   &typepb.Field{Name: "bye"},
+					Responses: []proto.Message{&typepb.Field{Name: "ciao"}},
+				},
+			},
+			Err:      scriptError,
+			Metadata: metadata.Pairs("x-tenant-id", "acme"),
+			Header:   metadata.Pairs("x-header", "header"),
+			Trailer:  metadata.Pairs("x-trailer", "trailer"),
+		},
+	)
+
+	if !errors.Is(err, scriptError) {
+		t.Fatalf("wrong error, given: %v expected: %v", err, scriptError)
+	}
+
+	if len(stream.responses) != 2 {
+		t.Fatalf("wrong number of responses, given: %d expected: 2", len(stream.responses))
+	}
+
+	if len(stream.requests) != 1 {
+		t.Fatalf("the stream should fail right after the last step")
+	}
+
+	if !proto.Equal(stream.responses[0], &typepb.Field{Name: "hello"}) {
+		t.Fatalf("the script should be chosen by the request metadata")
+	}
+
+	if !runtime.MatchMetadata(stream.header, metadata.Pairs("x-header", "header")) {
+		t.Fatalf("wrong header: %v", stream.header)
+	}
+
+	if !runtime.MatchMetadata(stream.trailer, metadata.Pairs("x-trailer", "trailer")) {
+		t.Fatalf("wrong trailer: %v", stream.trailer)
+	}
+
+	calls := journal.Calls()
+	if len(calls) != 1 || calls[0].Case != "Should say goodbye" || len(calls[0].Requests) != 2 {
+		t.Fatalf("wrong calls: %v", calls)
+	}
+}
+
+func TestReplayScripts_Unmatched(t *testing.T) {
+	t.Parallel()
+
+	stream := &serverStreamMock{
+		ctx:      context.Background(),
+		requests: []proto.Message{&typepb.Field{Name: "hi"}, &typepb.Field{Name: "bye"}},
+	}
+
+	journal := &runtime.CallJournal{}
+	err := runtime.ReplayScripts(
+		stream,
+		func() proto.Message { return &typepb.Field{} },
+		journal.Recorder("Chat"),
+		runtime.ScriptsUnmatched{Generated: runtime.UnmatchedStrict, Method: "acme.Chat"},
+		runtime.Script{
+			Description: "Should say hello twice",
+			Steps: []runtime.Step{
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Responses: []proto.Message{&typepb.Field{Name: "hello"}},
+				},
+				{
+					Request:   &typepb.Field{Name: "hi"},
+					Responses: []proto.Message{&typepb.Field{Name: "hello again"}},
+				},
+			},
+		},
+	)
+
+	expectedMessage := "no case of acme.Chat matches the request, " +
+		`closest case 'Should say hello twice': name: expected "hi", given "bye"`
+	if status.Code(err) != codes.Unimplemented || status.Convert(err).Message() != expectedMessage {
+		t.Fatalf("Wrong error, given: %v expected: %v", err, expectedMessage)
+	}
+
+	if len(stream.responses) != 1 {
+		t.Fatalf("wrong number of responses, given: %d expected: 1", len(stream.responses))
+	}
+
+	calls := journal.Calls()
+	if len(calls) != 1 || calls[0].Case != "" || !errors.Is(calls[0].Err, err) {
+		t.Fatalf("wrong calls: %v", calls)
+	}
+}
@@ -0,0 +1,477 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	_ grpc.ClientConnInterface   = &ServerConn{}
+	_ grpc.ServerTransportStream = &serverMetadata{}
+	_ grpc.ServerStream          = &serverPipeStream{}
+	_ grpc.ClientStream          = &clientPipeStream{}
+)
+
+var errHeaderSent = errors.New("the header was already sent")
+
+// ServerConn is a grpc.ClientConnInterface calling the handlers of a service implementation
+// in the same process, without a grpc.Server or a network in between. Messages are copied
+// through their wire format, so the caller and the implementation never share them, while
+// the metadata goes from the outgoing context to the incoming one, as a server would do.
+type ServerConn struct {
+	service           string
+	impl              interface{}
+	methods           map[string]grpc.MethodDesc
+	streams           map[string]grpc.StreamDesc
+	unaryInterceptor  grpc.UnaryServerInterceptor
+	streamInterceptor grpc.StreamServerInterceptor
+}
+
+// NewServerConn creates a ServerConn calling impl, the implementation of the service
+// described by desc, e.g. the `<Service>_ServiceDesc` generated by protoc-gen-go-grpc.
+// The server interceptors of the options wrap every call.
+func NewServerConn(
+	desc *grpc.ServiceDesc,
+	impl interface{},
+	opts ...ContractTestOption,
+) *ServerConn {
+	options := NewContractTestOptions(opts...)
+	conn := &ServerConn{
+		service:           desc.ServiceName,
+		impl:              impl,
+		methods:           make(map[string]grpc.MethodDesc, len(desc.Methods)),
+		streams:           make(map[string]grpc.StreamDesc, len(desc.Streams)),
+		unaryInterceptor:  chainUnaryServerInterceptors(options.UnaryInterceptors),
+		streamInterceptor: chainStreamServerInterceptors(options.StreamInterceptors),
+	}
+
+	for _, method := range desc.Methods {
+		conn.methods[method.MethodName] = method
+	}
+	for _, stream := range desc.Streams {
+		conn.streams[stream.StreamName] = stream
+	}
+
+	return conn
+}
+
+// Invoke calls the unary method of the implementation, filling reply with its response.
+func (c *ServerConn) Invoke(
+	ctx context.Context,
+	method string,
+	args interface{},
+	reply interface{},
+	opts ...grpc.CallOption,
+) error {
+	methodDesc, ok := c.methods[c.methodName(method)]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	transport := &serverMetadata{method: method}
+	ctx = grpc.NewContextWithServerTransportStream(incomingContext(ctx), transport)
+
+	response, err := methodDesc.Handler(c.impl, ctx, func(request interface{}) error {
+		return copyMessage(request, args)
+	}, c.unaryInterceptor)
+
+	header, trailer := transport.metadata()
+	SetCallMetadata(opts, header, trailer)
+
+	if err != nil {
+		return status.Convert(err).Err()
+	}
+
+	return copyMessage(reply, response)
+}
+
+// NewStream starts the streaming method of the implementation, its handler runs apart until
+// it returns, sending and receiving messages through the returned stream.
+func (c *ServerConn) NewStream(
+	ctx context.Context,
+	_ *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	streamDesc, ok := c.streams[c.methodName(method)]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	pipe := newStreamPipe(ctx, method)
+	go pipe.serve(opts, func(stream grpc.ServerStream) error {
+		if c.streamInterceptor == nil {
+			return streamDesc.Handler(c.impl, stream)
+		}
+
+		info := &grpc.StreamServerInfo{
+			FullMethod:     method,
+			IsClientStream: streamDesc.ClientStreams,
+			IsServerStream: streamDesc.ServerStreams,
+		}
+		return c.streamInterceptor(c.impl, stream, info, streamDesc.Handler)
+	})
+
+	return &clientPipeStream{ctx: ctx, pipe: pipe}, nil
+}
+
+// methodName returns the name of the method when the full method belongs to the service.
+func (c *ServerConn) methodName(fullMethod string) string {
+	prefix := "/" + c.service + "/"
+	if !strings.HasPrefix(fullMethod, prefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(fullMethod, prefix)
+}
+
+// incomingContext moves the outgoing metadata of the caller to the incoming metadata seen
+// by the implementation.
+func incomingContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(metadata.NewOutgoingContext(ctx, nil), md.Copy())
+}
+
+func copyMessage(dst interface{}, src interface{}) error {
+	dstMessage, dstOk := dst.(proto.Message)
+	srcMessage, srcOk := src.(proto.Message)
+	if !dstOk || !srcOk {
+		return status.Errorf(codes.Internal, "failed to copy %T into %T", src, dst)
+	}
+
+	data, err := proto.Marshal(srcMessage)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal %T: %v", src, err)
+	}
+
+	if err = proto.Unmarshal(data, dstMessage); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal %T: %v", dst, err)
+	}
+
+	return nil
+}
+
+func chainUnaryServerInterceptors(
+	interceptors []grpc.UnaryServerInterceptor,
+) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+
+	return func(
+		ctx context.Context,
+		request interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, nextHandler := interceptors[i], next
+			next = func(ctx context.Context, request interface{}) (interface{}, error) {
+				return interceptor(ctx, request, info, nextHandler)
+			}
+		}
+
+		return next(ctx, request)
+	}
+}
+
+func chainStreamServerInterceptors(
+	interceptors []grpc.StreamServerInterceptor,
+) grpc.StreamServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, nextHandler := interceptors[i], next
+			next = func(srv interface{}, stream grpc.ServerStream) error {
+				return interceptor(srv, stream, info, nextHandler)
+			}
+		}
+
+		return next(srv, stream)
+	}
+}
+
+// serverMetadata collects the headers and trailers set by the implementation.
+type serverMetadata struct {
+	method     string
+	mutex      sync.Mutex
+	header     metadata.MD
+	trailer    metadata.MD
+	headerSent bool
+}
+
+func (m *serverMetadata) Method() string {
+	return m.method
+}
+
+func (m *serverMetadata) SetHeader(md metadata.MD) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.headerSent {
+		return errHeaderSent
+	}
+	m.header = metadata.Join(m.header, md)
+
+	return nil
+}
+
+func (m *serverMetadata) SendHeader(md metadata.MD) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.headerSent {
+		return errHeaderSent
+	}
+	m.header = metadata.Join(m.header, md)
+	m.headerSent = true
+
+	return nil
+}
+
+func (m *serverMetadata) SetTrailer(md metadata.MD) error {
+	m.addTrailer(md)
+	return nil
+}
+
+func (m *serverMetadata) addTrailer(md metadata.MD) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.trailer = metadata.Join(m.trailer, md)
+}
+
+func (m *serverMetadata) metadata() (metadata.MD, metadata.MD) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.header.Copy(), m.trailer.Copy()
+}
+
+// streamPipe connects the stream of the caller to the stream given to the implementation.
+// The header is ready once the implementation sends it, along with its first message or
+// when it returns, while the trailer and the error are only known once it returns.
+type streamPipe struct {
+	serverMetadata
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	requests   chan []byte
+	responses  chan []byte
+	closeSend  chan struct{}
+	closeOnce  sync.Once
+	headerOnce sync.Once
+	headerDone chan struct{}
+	done       chan struct{}
+	err        error
+}
+
+func newStreamPipe(ctx context.Context, method string) *streamPipe {
+	pipe := &streamPipe{
+		serverMetadata: serverMetadata{method: method},
+		requests:       make(chan []byte),
+		responses:      make(chan []byte),
+		closeSend:      make(chan struct{}),
+		headerDone:     make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	pipe.ctx, pipe.cancel = context.WithCancel(incomingContext(ctx))
+	pipe.ctx = grpc.NewContextWithServerTransportStream(pipe.ctx, pipe)
+
+	return pipe
+}
+
+// serve runs the handler, finishing the stream with its error once it returns.
+func (p *streamPipe) serve(opts []grpc.CallOption, handler func(grpc.ServerStream) error) {
+	defer p.cancel()
+
+	err := handler(&serverPipeStream{pipe: p})
+
+	p.sendHeader()
+
+	header, trailer := p.metadata()
+	SetCallMetadata(opts, header, trailer)
+
+	if err != nil {
+		p.err = status.Convert(err).Err()
+	}
+	close(p.done)
+}
+
+func (p *streamPipe) SendHeader(md metadata.MD) error {
+	if err := p.serverMetadata.SendHeader(md); err != nil {
+		return err
+	}
+	p.sendHeader()
+
+	return nil
+}
+
+func (p *streamPipe) sendHeader() {
+	p.headerOnce.Do(func() {
+		p.mutex.Lock()
+		p.headerSent = true
+		p.mutex.Unlock()
+
+		close(p.headerDone)
+	})
+}
+
+// send hands the message to the other side, failing once the done channel is closed.
+func (p *streamPipe) send(
+	ctx context.Context,
+	messages chan<- []byte,
+	done <-chan struct{},
+	message interface{},
+) error {
+	protoMessage, ok := message.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "failed to marshal %T: not a proto message", message)
+	}
+
+	data, err := proto.Marshal(protoMessage)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal %T: %v", message, err)
+	}
+
+	select {
+	case messages <- data:
+		return nil
+	case <-done:
+		return io.EOF
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// serverPipeStream is the grpc.ServerStream given to the implementation.
+type serverPipeStream struct {
+	pipe *streamPipe
+}
+
+func (s *serverPipeStream) SetHeader(md metadata.MD) error {
+	return s.pipe.SetHeader(md)
+}
+
+func (s *serverPipeStream) SendHeader(md metadata.MD) error {
+	return s.pipe.SendHeader(md)
+}
+
+func (s *serverPipeStream) SetTrailer(md metadata.MD) {
+	s.pipe.addTrailer(md)
+}
+
+func (s *serverPipeStream) Context() context.Context {
+	return s.pipe.ctx
+}
+
+func (s *serverPipeStream) SendMsg(m interface{}) error {
+	s.pipe.sendHeader()
+	return s.pipe.send(s.pipe.ctx, s.pipe.responses, nil, m)
+}
+
+func (s *serverPipeStream) RecvMsg(m interface{}) error {
+	select {
+	case data := <-s.pipe.requests:
+		return unmarshalMessage(data, m)
+	case <-s.pipe.closeSend:
+		return io.EOF
+	case <-s.pipe.ctx.Done():
+		return status.FromContextError(s.pipe.ctx.Err()).Err()
+	}
+}
+
+// clientPipeStream is the grpc.ClientStream returned to the caller.
+type clientPipeStream struct {
+	ctx  context.Context
+	pipe *streamPipe
+}
+
+func (s *clientPipeStream) Header() (metadata.MD, error) {
+	select {
+	case <-s.pipe.headerDone:
+		header, _ := s.pipe.metadata()
+		return header, nil
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+func (s *clientPipeStream) Trailer() metadata.MD {
+	select {
+	case <-s.pipe.done:
+		_, trailer := s.pipe.metadata()
+		return trailer
+	default:
+		return nil
+	}
+}
+
+func (s *clientPipeStream) CloseSend() error {
+	s.pipe.closeOnce.Do(func() {
+		close(s.pipe.closeSend)
+	})
+
+	return nil
+}
+
+func (s *clientPipeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *clientPipeStream) SendMsg(m interface{}) error {
+	select {
+	case <-s.pipe.closeSend:
+		return errSendAfterClose
+	default:
+	}
+
+	return s.pipe.send(s.ctx, s.pipe.requests, s.pipe.done, m)
+}
+
+func (s *clientPipeStream) RecvMsg(m interface{}) error {
+	select {
+	case data := <-s.pipe.responses:
+		return unmarshalMessage(data, m)
+	case <-s.pipe.done:
+		if s.pipe.err != nil {
+			return s.pipe.err
+		}
+		return io.EOF
+	case <-s.ctx.Done():
+		return status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+func unmarshalMessage(data []byte, m interface{}) error {
+	message, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "failed to unmarshal %T: not a proto message", m)
+	}
+
+	if err := proto.Unmarshal(data, message); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal %T: %v", m, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+// testService answers with the messages it's given, failing when a payload says so.
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (testService) UnaryCall(
+	ctx context.Context,
+	request *testpb.SimpleRequest,
+) (*testpb.SimpleResponse, error) {
+	if string(request.GetPayload().GetBody()) == "fail" {
+		return nil, status.Error(codes.NotFound, "payload not found")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs("header", "value")); err != nil {
+		return nil, err
+	}
+	if err := grpc.SetTrailer(ctx, metadata.Pairs("trailer", "value")); err != nil {
+		return nil, err
+	}
+
+	// Changing the request must not reach the caller
+	request.Payload = nil
+
+	return &testpb.SimpleResponse{Username: md.Get("user")[0]}, nil
+}
+
+func (testService) StreamingOutputCall(
+	request *testpb.StreamingOutputCallRequest,
+	stream testpb.TestService_StreamingOutputCallServer,
+) error {
+	stream.SetTrailer(metadata.Pairs("trailer", "value"))
+	for _, parameters := range request.ResponseParameters {
+		err := stream.Send(&testpb.StreamingOutputCallResponse{
+			Payload: &testpb.Payload{Body: make([]byte, parameters.Size)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (testService) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	var size int32
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{
+				AggregatedPayloadSize: size,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		size += int32(len(request.GetPayload().GetBody()))
+	}
+}
+
+func (testService) FullDuplexCall(stream testpb.TestService_FullDuplexCallServer) error {
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.Aborted, "stream closed")
+		}
+		if err != nil {
+			return err
+		}
+
+		err = stream.Send(&testpb.StreamingOutputCallResponse{Payload: request.Payload})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestServerConn_Unary(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mutex sync.Mutex
+		calls []string
+	)
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(
+			ctx context.Context,
+			request interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			mutex.Lock()
+			calls = append(calls, name+" "+info.FullMethod)
+			mutex.Unlock()
+
+			return handler(ctx, request)
+		}
+	}
+
+	conn := runtime.NewServerConn(
+		&testpb.TestService_ServiceDesc,
+		testService{},
+		runtime.WithServerUnaryInterceptors(interceptor("first"), interceptor("second")),
+	)
+	client := testpb.NewTestServiceClient(conn)
+
+	var header, trailer metadata.MD
+	request := &testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("body")}}
+	response, err := client.UnaryCall(
+		metadata.AppendToOutgoingContext(context.Background(), "user", "john"),
+		request,
+		grpc.Header(&header),
+		grpc.Trailer(&trailer),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Username != "john" {
+		t.Errorf("Wrong username, given: %s expected: john", response.Username)
+	}
+	if request.Payload == nil {
+		t.Errorf("Wrong request, given: %v expected: the payload untouched", request)
+	}
+	if header.Get("header")[0] != "value" || trailer.Get("trailer")[0] != "value" {
+		t.Errorf("Wrong metadata, given: %v and %v expected: the ones set", header, trailer)
+	}
+
+	expectedCalls := []string{
+		"first /grpc.testing.TestService/UnaryCall",
+		"second /grpc.testing.TestService/UnaryCall",
+	}
+	if len(calls) != len(expectedCalls) || calls[0] != expectedCalls[0] ||
+		calls[1] != expectedCalls[1] {
+		t.Errorf("Wrong calls, given: %v expected: %v", calls, expectedCalls)
+	}
+
+	_, err = client.UnaryCall(
+		context.Background(),
+		&testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("fail")}},
+	)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Wrong error, given: %v expected: %v", err, codes.NotFound)
+	}
+
+	_, err = client.EmptyCall(context.Background(), &testpb.Empty{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Wrong error, given: %v expected: %v", err, codes.Unimplemented)
+	}
+}
+
+func TestServerConn_ServerStreaming(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	conn := runtime.NewServerConn(
+		&testpb.TestService_ServiceDesc,
+		testService{},
+		runtime.WithServerStreamInterceptors(func(
+			srv interface{},
+			stream grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			calls++
+			return handler(srv, stream)
+		}),
+	)
+
+	stream, err := testpb.NewTestServiceClient(conn).StreamingOutputCall(
+		context.Background(),
+		&testpb.StreamingOutputCallRequest{
+			ResponseParameters: []*testpb.ResponseParameters{{Size: 1}, {Size: 2}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var sizes []int
+	for {
+		response, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			t.Fatalf("Unexpected error: %v", recvErr)
+		}
+
+		sizes = append(sizes, len(response.Payload.Body))
+	}
+
+	if len(sizes) != 2 || sizes[0] != 1 || sizes[1] != 2 {
+		t.Errorf("Wrong sizes, given: %v expected: [1 2]", sizes)
+	}
+	if trailer := stream.Trailer(); trailer.Get("trailer")[0] != "value" {
+		t.Errorf("Wrong trailer, given: %v expected: the one set", trailer)
+	}
+	if calls != 1 {
+		t.Errorf("Wrong calls, given: %d expected: 1", calls)
+	}
+}
+
+func TestServerConn_ClientStreaming(t *testing.T) {
+	t.Parallel()
+
+	conn := runtime.NewServerConn(&testpb.TestService_ServiceDesc, testService{})
+	stream, err := testpb.NewTestServiceClient(conn).StreamingInputCall(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, body := range []string{"a", "bc"} {
+		err = stream.Send(&testpb.StreamingInputCallRequest{
+			Payload: &testpb.Payload{Body: []byte(body)},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.AggregatedPayloadSize != 3 {
+		t.Errorf("Wrong size, given: %d expected: 3", response.AggregatedPayloadSize)
+	}
+}
+
+func TestServerConn_BidiStreaming(t *testing.T) {
+	t.Parallel()
+
+	conn := runtime.NewServerConn(&testpb.TestService_ServiceDesc, testService{})
+	stream, err := testpb.NewTestServiceClient(conn).FullDuplexCall(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, body := range []string{"ping", "pong"} {
+		err = stream.Send(&testpb.StreamingOutputCallRequest{
+			Payload: &testpb.Payload{Body: []byte(body)},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		response, recvErr := stream.Recv()
+		if recvErr != nil {
+			t.Fatalf("Unexpected error: %v", recvErr)
+		}
+		if string(response.Payload.Body) != body {
+			t.Errorf("Wrong body, given: %s expected: %s", response.Payload.Body, body)
+		}
+	}
+
+	if err = stream.CloseSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = stream.Recv(); status.Code(err) != codes.Aborted {
+		t.Errorf("Wrong error, given: %v expected: %v", err, codes.Aborted)
+	}
+	if err = stream.Send(&testpb.StreamingOutputCallRequest{}); err == nil {
+		t.Errorf("Wrong error, given: %v expected: an error sending after finishing", err)
+	}
+}
@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// ProviderState is the state the provider must be in before a case runs, it's declared
+// by the `given` field of the case along with its optional parameters, whose numbers are
+// always float64.
+type ProviderState struct {
+	Name   string
+	Params map[string]interface{}
+}
+
+// ProviderStateHandler prepares the provider for a state, e.g. inserting the data the case
+// expects. The returned teardown, when not nil, runs once the case finishes.
+type ProviderStateHandler func(
+	ctx context.Context,
+	params map[string]interface{},
+) (teardown func(), err error)
+
+// ProviderStates maps each provider state name to its handler.
+type ProviderStates map[string]ProviderStateHandler
+
+// ContractTestOptions holds the settings of a generated contract test.
+type ContractTestOptions struct {
+	ProviderStates     ProviderStates
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+}
+
+// ContractTestOption changes the settings of a generated contract test.
+type ContractTestOption func(options *ContractTestOptions)
+
+// WithProviderStates sets the handlers called before the cases declaring a provider state,
+// it may be given many times and the last handler of a state wins.
+func WithProviderStates(states ProviderStates) ContractTestOption {
+	return func(options *ContractTestOptions) {
+		if options.ProviderStates == nil {
+			options.ProviderStates = make(ProviderStates, len(states))
+		}
+
+		for name, handler := range states {
+			options.ProviderStates[name] = handler
+		}
+	}
+}
+
+// WithServerUnaryInterceptors adds server interceptors wrapping every unary method of the
+// implementation verified by the generated `<Service>ContractTestImpl`, they run in the
+// given order, like the ones given to grpc.ChainUnaryInterceptor.
+func WithServerUnaryInterceptors(
+	interceptors ...grpc.UnaryServerInterceptor,
+) ContractTestOption {
+	return func(options *ContractTestOptions) {
+		options.UnaryInterceptors = append(options.UnaryInterceptors, interceptors...)
+	}
+}
+
+// WithServerStreamInterceptors adds server interceptors wrapping every streaming method of
+// the implementation verified by the generated `<Service>ContractTestImpl`, they run in the
+// given order, like the ones given to grpc.ChainStreamInterceptor.
+func WithServerStreamInterceptors(
+	interceptors ...grpc.StreamServerInterceptor,
+) ContractTestOption {
+	return func(options *ContractTestOptions) {
+		options.StreamInterceptors = append(options.StreamInterceptors, interceptors...)
+	}
+}
+
+// NewContractTestOptions applies the given options over the default settings.
+func NewContractTestOptions(opts ...ContractTestOption) ContractTestOptions {
+	var options ContractTestOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// SetupProviderState calls the handler of the state, registering its teardown as a cleanup
+// of the test. The test fails when the state has no handler or the handler fails, while
+// cases without a provider state are left untouched.
+func (o ContractTestOptions) SetupProviderState(
+	ctx context.Context,
+	t testing.TB,
+	state ProviderState,
+) {
+	t.Helper()
+
+	if state.Name == "" {
+		return
+	}
+
+	handler, exists := o.ProviderStates[state.Name]
+	if !exists {
+		t.Fatalf("no handler for the provider state '%s'", state.Name)
+		return
+	}
+
+	teardown, err := handler(ctx, state.Params)
+	if err != nil {
+		t.Fatalf("failed to set up the provider state '%s': %v", state.Name, err)
+		return
+	}
+
+	if teardown != nil {
+		t.Cleanup(teardown)
+	}
+}
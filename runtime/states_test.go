@@ -0,0 +1,105 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+// fakeT records the failures and cleanups of the runtime helpers, without stopping the test.
+type fakeT struct {
+	testing.TB
+	failure  string
+	cleanups []func()
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+func (f *fakeT) Cleanup(cleanup func()) {
+	f.cleanups = append(f.cleanups, cleanup)
+}
+
+func TestContractTestOptions_SetupProviderState(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	states := runtime.ProviderStates{
+		"user exists": func(_ context.Context, params map[string]interface{}) (func(), error) {
+			calls = append(calls, fmt.Sprintf("setup %v", params["id"]))
+
+			return func() { calls = append(calls, "teardown") }, nil
+		},
+		"broken": func(context.Context, map[string]interface{}) (func(), error) {
+			return nil, errors.New("database is down")
+		},
+	}
+
+	tests := []struct {
+		name            string
+		state           runtime.ProviderState
+		expectedCalls   []string
+		expectedFailure string
+	}{
+		{
+			name: "should call the handler and register its teardown",
+			state: runtime.ProviderState{
+				Name:   "user exists",
+				Params: map[string]interface{}{"id": "42"},
+			},
+			expectedCalls: []string{"setup 42", "teardown"},
+		},
+		{
+			name:          "should do nothing when the case has no provider state",
+			state:         runtime.ProviderState{},
+			expectedCalls: []string{},
+		},
+		{
+			name:            "should fail when the state has no handler",
+			state:           runtime.ProviderState{Name: "user is banned"},
+			expectedCalls:   []string{},
+			expectedFailure: "no handler for the provider state 'user is banned'",
+		},
+		{
+			name:          "should fail when the handler fails",
+			state:         runtime.ProviderState{Name: "broken"},
+			expectedCalls: []string{},
+			expectedFailure: "failed to set up the provider state 'broken': " +
+				"database is down",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls = []string{}
+			fake := &fakeT{}
+
+			options := runtime.NewContractTestOptions(runtime.WithProviderStates(states))
+			options.SetupProviderState(context.Background(), fake, test.state)
+			for _, cleanup := range fake.cleanups {
+				cleanup()
+			}
+
+			if fake.failure != test.expectedFailure {
+				t.Errorf(
+					"Wrong failure, given: %s expected: %s", fake.failure, test.expectedFailure,
+				)
+			}
+
+			if !reflect.DeepEqual(calls, test.expectedCalls) {
+				t.Errorf("Wrong calls, given: %v expected: %v", calls, test.expectedCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ grpc.ClientStream = &ClientStream{}
+
+var errSendAfterClose = errors.New("SendMsg called after CloseSend")
+
+// ClientStream is a grpc.ClientStream that replays the responses of a contract case,
+// it's used by the generated contract client to implement the streaming methods.
+// Once every response is received it returns the case error, or io.EOF when there's none.
+// Messages sent through it are recorded, so client-streaming methods can match them.
+type ClientStream struct {
+	ctx       context.Context
+	mutex     sync.Mutex
+	notify    chan struct{}
+	requests  []proto.Message
+	responses []proto.Message
+	err       error
+	finished  bool
+	closed    bool
+	player    *scriptPlayer
+	record    CallRecorder
+	header    metadata.MD
+	trailer   metadata.MD
+}
+
+// NewClientStream creates a ClientStream that will replay the given responses and
+// finish with err, a nil err means the stream finishes successfully.
+func NewClientStream(ctx context.Context, err error, responses ...proto.Message) *ClientStream {
+	return &ClientStream{
+		ctx:       ctx,
+		notify:    make(chan struct{}),
+		responses: responses,
+		err:       err,
+		finished:  true,
+	}
+}
+
+// NewScriptedClientStream creates a ClientStream for bidirectional streaming methods,
+// every sent message moves the scripts forward and queues the responses of the step.
+// Once the stream is finished the call is given to record, when it's not nil.
+// Messages matching no script finish the stream as told by unmatched.
+func NewScriptedClientStream(
+	ctx context.Context,
+	record CallRecorder,
+	unmatched ScriptsUnmatched,
+	scripts ...Script,
+) *ClientStream {
+	return &ClientStream{
+		ctx:    ctx,
+		notify: make(chan struct{}),
+		player: newScriptPlayer(ctx, unmatched, scripts),
+		record: record,
+	}
+}
+
+// SetResponseMetadata sets the headers and trailers returned by the stream.
+func (s *ClientStream) SetResponseMetadata(header metadata.MD, trailer metadata.MD) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.header = header
+	s.trailer = trailer
+}
+
+// Header returns the headers of the case, scripted streams return the ones
+// of the script currently being played.
+func (s *ClientStream) Header() (metadata.MD, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.player != nil {
+		return s.player.header().Copy(), nil
+	}
+
+	return s.header.Copy(), nil
+}
+
+// Trailer returns the trailers of the case, they're only available once the stream
+// is finished, like in a real stream.
+func (s *ClientStream) Trailer() metadata.MD {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.finished {
+		return metadata.MD{}
+	}
+	if s.player != nil {
+		return s.player.trailer().Copy()
+	}
+
+	return s.trailer.Copy()
+}
+
+// CloseSend closes the sending side of the stream.
+func (s *ClientStream) CloseSend() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+
+	if !s.finished {
+		s.finish(s.player.close())
+	}
+
+	return nil
+}
+
+// Context returns the context used to create the stream.
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg records a copy of m, it fails when the sending side was already closed.
+// As a real stream, it returns io.EOF when a scripted stream was already finished.
+func (s *ClientStream) SendMsg(m interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return errSendAfterClose
+	}
+
+	message := m.(proto.Message) //nolint:errcheck // generated code always sends a message
+	request := proto.Clone(message)
+	s.requests = append(s.requests, request)
+
+	if s.player == nil {
+		return nil
+	}
+	if s.finished {
+		return io.EOF
+	}
+
+	responses, done, err := s.player.receive(request)
+	s.responses = append(s.responses, responses...)
+	if done {
+		s.finish(err)
+		return nil
+	}
+
+	s.signal()
+
+	return nil
+}
+
+// Requests returns every message sent through the stream.
+func (s *ClientStream) Requests() []proto.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+// RecvMsg fills m with the next response of the case. Scripted streams block until
+// the next step is reached, the stream finishes or the context is done.
+func (s *ClientStream) RecvMsg(m interface{}) error {
+	for {
+		s.mutex.Lock()
+
+		if len(s.responses) > 0 {
+			response := s.responses[0]
+			s.responses = s.responses[1:]
+			s.mutex.Unlock()
+
+			message := m.(proto.Message) //nolint:errcheck // generated code always sends a message
+			proto.Reset(message)
+			proto.Merge(message, response)
+
+			return nil
+		}
+
+		if s.finished {
+			err := s.err
+			s.mutex.Unlock()
+
+			if err != nil {
+				return err
+			}
+			return io.EOF
+		}
+
+		notify := s.notify
+		s.mutex.Unlock()
+
+		select {
+		case <-notify:
+		case <-s.ctx.Done():
+			return status.FromContextError(s.ctx.Err()).Err()
+		}
+	}
+}
+
+// finish must be called holding the mutex.
+func (s *ClientStream) finish(err error) {
+	s.finished = true
+	s.err = err
+	s.signal()
+
+	if s.record != nil {
+		s.record(s.player.finishedCase(), s.requests, err)
+	}
+}
+
+// signal wakes up every RecvMsg waiting for a change, it must be called holding the mutex.
+func (s *ClientStream) signal() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// MessageSender is implemented by any grpc stream able to send messages,
+// like grpc.ServerStream.
+type MessageSender interface {
+	SendMsg(m interface{}) error
+}
+
+// SendResponses sends every response through the stream and then returns err,
+// it's used by the generated stub server to implement the streaming methods.
+func SendResponses(stream MessageSender, err error, responses ...proto.Message) error {
+	for _, response := range responses {
+		if sendErr := stream.SendMsg(response); sendErr != nil {
+			return sendErr
+		}
+	}
+
+	return err
+}
+
+// MessageReceiver is implemented by any grpc stream able to receive messages,
+// like grpc.ServerStream.
+type MessageReceiver interface {
+	RecvMsg(m interface{}) error
+}
+
+// ReceiveRequests receives messages from the stream until the client closes it,
+// it's used by the generated stub server to implement client-streaming methods.
+func ReceiveRequests(
+	stream MessageReceiver,
+	newRequest func() proto.Message,
+) ([]proto.Message, error) {
+	requests := make([]proto.Message, 0)
+	for {
+		request := newRequest()
+
+		err := stream.RecvMsg(request)
+		if errors.Is(err, io.EOF) {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, request)
+	}
+}
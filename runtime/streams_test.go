@@ -0,0 +1,133 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestClientStream(t *testing.T) {
+	t.Parallel()
+
+	caseError := errors.New("case error")
+
+	tests := []struct {
+		name              string
+		err               error
+		responses         []proto.Message
+		expectedResponses []proto.Message
+		expectedError     error
+	}{
+		{
+			name:              "should finish with io.EOF when there's no error",
+			err:               nil,
+			responses:         []proto.Message{&typepb.Field{Name: "first"}},
+			expectedResponses: []proto.Message{&typepb.Field{Name: "first"}},
+			expectedError:     io.EOF,
+		},
+		{
+			name: "should replay the responses in order",
+			err:  nil,
+			responses: []proto.Message{
+				&typepb.Field{Name: "first"},
+				&typepb.Field{Name: "second"},
+			},
+			expectedResponses: []proto.Message{
+				&typepb.Field{Name: "first"},
+				&typepb.Field{Name: "second"},
+			},
+			expectedError: io.EOF,
+		},
+		{
+			name:              "should finish with the case error after the responses",
+			err:               caseError,
+			responses:         []proto.Message{&typepb.Field{Name: "first"}},
+			expectedResponses: []proto.Message{&typepb.Field{Name: "first"}},
+			expectedError:     caseError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := runtime.NewClientStream(context.Background(), test.err, test.responses...)
+
+			for i, expectedResponse := range test.expectedResponses {
+				response := &typepb.Field{}
+				if err := stream.RecvMsg(response); err != nil {
+					t.Fatalf("unexpected error receiving the response %d: %v", i, err)
+				}
+
+				if !proto.Equal(response, expectedResponse) {
+					t.Fatalf("Given: %v, expected: %v", response, expectedResponse)
+				}
+			}
+
+			err := stream.RecvMsg(&typepb.Field{})
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("wrong error, given: %v expected: %v", err, test.expectedError)
+			}
+		})
+	}
+}
+
+type senderMock struct {
+	messages []interface{}
+}
+
+func (s *senderMock) SendMsg(m interface{}) error {
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+func TestSendResponses(t *testing.T) {
+	t.Parallel()
+
+	caseError := errors.New("case error")
+	sender := &senderMock{}
+
+	err := runtime.SendResponses(
+		sender,
+		caseError,
+		&typepb.Field{Name: "first"},
+		&typepb.Field{Name: "second"},
+	)
+
+	if !errors.Is(err, caseError) {
+		t.Fatalf("wrong error, given: %v expected: %v", err, caseError)
+	}
+
+	if len(sender.messages) != 2 {
+		t.Fatalf("wrong number of messages, given: %d expected: 2", len(sender.messages))
+	}
+}
+
+func TestClientStream_Requests(t *testing.T) {
+	t.Parallel()
+
+	stream := runtime.NewClientStream(context.Background(), nil)
+
+	requests := []proto.Message{&typepb.Field{Name: "first"}, &typepb.Field{Name: "second"}}
+	for i, request := range requests {
+		if err := stream.SendMsg(request); err != nil {
+			t.Fatalf("unexpected error sending the request %d: %v", i, err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("unexpected error closing the stream: %v", err)
+	}
+
+	if err := stream.SendMsg(&typepb.Field{}); err == nil {
+		t.Fatal("expected an error sending after closing the stream")
+	}
+
+	if !runtime.MatchRequests(stream.Requests(), requests) {
+		t.Fatalf("Given: %v, expected: %v", stream.Requests(), requests)
+	}
+}
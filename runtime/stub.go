@@ -0,0 +1,256 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const stubBufferSize = 1024 * 1024
+
+// StubCase is a case added to the stub server by the test, it's matched before the
+// contract cases and only applies to unary methods.
+type StubCase struct {
+	// Method is the name of the method, e.g. `GetUser`.
+	Method   string
+	Request  proto.Message
+	Matchers []FieldMatcher
+	Response proto.Message
+	Err      error
+}
+
+// StubOptions holds the settings of a stub server started by the generated helpers.
+type StubOptions struct {
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	Latency            time.Duration
+	Cases              []StubCase
+	Discovery          bool
+}
+
+// StubOption changes the settings of a stub server started by the generated helpers.
+type StubOption func(options *StubOptions)
+
+// WithStubUnaryInterceptors adds server interceptors wrapping every unary call,
+// they run in the given order.
+func WithStubUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) StubOption {
+	return func(options *StubOptions) {
+		options.UnaryInterceptors = append(options.UnaryInterceptors, interceptors...)
+	}
+}
+
+// WithStubStreamInterceptors adds server interceptors wrapping every stream,
+// they run in the given order.
+func WithStubStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) StubOption {
+	return func(options *StubOptions) {
+		options.StreamInterceptors = append(options.StreamInterceptors, interceptors...)
+	}
+}
+
+// WithLatency delays every call by the given duration before it reaches the stub,
+// a call whose context is done meanwhile fails with its status.
+func WithLatency(latency time.Duration) StubOption {
+	return func(options *StubOptions) {
+		options.Latency = latency
+	}
+}
+
+// WithCases adds cases to the stub, they're matched in order before the contract cases.
+// Only unary methods are supported, StartStub fails the test when a case names a
+// streaming method or a method the stub doesn't have.
+func WithCases(cases ...StubCase) StubOption {
+	return func(options *StubOptions) {
+		options.Cases = append(options.Cases, cases...)
+	}
+}
+
+// WithDiscovery also registers the server reflection and the health services,
+// see RegisterDiscovery.
+func WithDiscovery() StubOption {
+	return func(options *StubOptions) {
+		options.Discovery = true
+	}
+}
+
+// NewStubOptions applies the given options over the default settings.
+func NewStubOptions(opts ...StubOption) StubOptions {
+	var options StubOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// ServerOptions returns the options of the grpc.Server running the stub. The interceptors
+// run first, then the latency is applied and finally the cases given by the test are matched.
+func (o StubOptions) ServerOptions() []grpc.ServerOption {
+	unaryInterceptors := append([]grpc.UnaryServerInterceptor{}, o.UnaryInterceptors...)
+	streamInterceptors := append([]grpc.StreamServerInterceptor{}, o.StreamInterceptors...)
+
+	if o.Latency > 0 {
+		unaryInterceptors = append(unaryInterceptors, o.unaryLatency)
+		streamInterceptors = append(streamInterceptors, o.streamLatency)
+	}
+	if len(o.Cases) > 0 {
+		unaryInterceptors = append(unaryInterceptors, o.unaryCases)
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+}
+
+func (o StubOptions) unaryLatency(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := o.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (o StubOptions) streamLatency(
+	srv interface{},
+	stream grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := o.wait(stream.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+// wait blocks for the latency, or until the context is done.
+func (o StubOptions) wait(ctx context.Context) error {
+	timer := time.NewTimer(o.Latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (o StubOptions) unaryCases(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	request, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	method := path.Base(info.FullMethod)
+	for _, stubCase := range o.Cases {
+		if stubCase.Method == method &&
+			MatchRequest(request, stubCase.Request, stubCase.Matchers...) {
+			if stubCase.Err != nil {
+				return nil, stubCase.Err
+			}
+
+			return stubCase.Response, nil
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// CheckCases returns an error when a case given by the test can't be matched, because
+// its method is a streaming one or none of the services has it.
+func (o StubOptions) CheckCases(services map[string]grpc.ServiceInfo) error {
+	unary := make(map[string]bool)
+	for _, service := range services {
+		for _, method := range service.Methods {
+			unary[method.Name] = !method.IsClientStream && !method.IsServerStream
+		}
+	}
+
+	for i, stubCase := range o.Cases {
+		isUnary, found := unary[stubCase.Method]
+		switch {
+		case !found:
+			return fmt.Errorf("case %d: unknown method '%s'", i, stubCase.Method)
+		case !isUnary:
+			return fmt.Errorf(
+				"case %d: cases only apply to unary methods, '%s' is a streaming one",
+				i, stubCase.Method,
+			)
+		}
+	}
+
+	return nil
+}
+
+// StartStub runs a grpc.Server in memory, through bufconn, returning a connection to it.
+// The services are registered by register, it's used by the generated helpers starting
+// the stub servers. The server and the connection are closed once the test finishes.
+func StartStub(
+	t testing.TB,
+	register func(server *grpc.Server),
+	opts ...StubOption,
+) *grpc.ClientConn {
+	t.Helper()
+
+	options := NewStubOptions(opts...)
+
+	listener := bufconn.Listen(stubBufferSize)
+	server := grpc.NewServer(options.ServerOptions()...)
+	register(server)
+	if err := options.CheckCases(server.GetServiceInfo()); err != nil {
+		t.Fatalf("invalid stub cases: %v", err)
+		return nil
+	}
+	if options.Discovery {
+		RegisterDiscovery(server, nil)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	dialer := func(context.Context, string) (net.Conn, error) { return listener.Dial() }
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"bufnet",
+		grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		server.Stop()
+		t.Fatalf("failed to dial the stub server: %v", err)
+		return nil
+	}
+
+	t.Cleanup(func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			t.Errorf("failed to close the stub connection: %v", closeErr)
+		}
+
+		server.Stop()
+		if serveErr := <-served; serveErr != nil {
+			t.Errorf("stub server exited with error: %v", serveErr)
+		}
+	})
+
+	return conn
+}
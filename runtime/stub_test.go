@@ -0,0 +1,181 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestStartStub(t *testing.T) {
+	t.Parallel()
+
+	var intercepted []string
+	interceptor := func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		intercepted = append(intercepted, info.FullMethod)
+		return handler(ctx, req)
+	}
+
+	conn := runtime.StartStub(
+		t,
+		func(server *grpc.Server) { healthpb.RegisterHealthServer(server, health.NewServer()) },
+		runtime.WithStubUnaryInterceptors(interceptor),
+		runtime.WithLatency(20*time.Millisecond),
+		runtime.WithCases(
+			runtime.StubCase{
+				Method:  "Check",
+				Request: &healthpb.HealthCheckRequest{Service: "users"},
+				Response: &healthpb.HealthCheckResponse{
+					Status: healthpb.HealthCheckResponse_NOT_SERVING,
+				},
+			},
+			runtime.StubCase{
+				Method:  "Check",
+				Request: &healthpb.HealthCheckRequest{Service: "orders"},
+				Err:     status.Error(codes.Unavailable, "orders are down"),
+			},
+		),
+	)
+	client := healthpb.NewHealthClient(conn)
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		service        string
+		expectedStatus healthpb.HealthCheckResponse_ServingStatus
+		expectedError  string
+	}{
+		{
+			name:           "should answer with the given case",
+			service:        "users",
+			expectedStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name:          "should fail with the error of the given case",
+			service:       "orders",
+			expectedError: "rpc error: code = Unavailable desc = orders are down",
+		},
+		{
+			name:           "should reach the registered service when no case matches",
+			expectedStatus: healthpb.HealthCheckResponse_SERVING,
+		},
+	}
+
+	for _, test := range tests {
+		start := time.Now()
+		response, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: test.service})
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("%s: the latency wasn't applied, given: %v", test.name, elapsed)
+		}
+
+		if test.expectedError != "" {
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf(
+					"%s: Wrong error, given: %v expected: %s", test.name, err, test.expectedError,
+				)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", test.name, err)
+		}
+		if response.Status != test.expectedStatus {
+			t.Errorf(
+				"%s: Wrong status, given: %v expected: %v",
+				test.name, response.Status, test.expectedStatus,
+			)
+		}
+	}
+
+	if len(intercepted) != len(tests) {
+		t.Errorf("Wrong intercepted calls, given: %v expected: %d calls", intercepted, len(tests))
+	}
+}
+
+func TestStartStub_LatencyRespectsTheContext(t *testing.T) {
+	t.Parallel()
+
+	conn := runtime.StartStub(
+		t,
+		func(server *grpc.Server) { healthpb.RegisterHealthServer(server, health.NewServer()) },
+		runtime.WithLatency(time.Minute),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Wrong error, given: %v expected: %v", err, codes.DeadlineExceeded)
+	}
+}
+
+func TestStubOptions_CheckCases(t *testing.T) {
+	t.Parallel()
+
+	services := map[string]grpc.ServiceInfo{
+		"grpc.health.v1.Health": {
+			Methods: []grpc.MethodInfo{
+				{Name: "Check"},
+				{Name: "Watch", IsServerStream: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		expectedError string
+	}{
+		{
+			name:   "should accept a case of a unary method",
+			method: "Check",
+		},
+		{
+			name:          "should reject a case of a streaming method",
+			method:        "Watch",
+			expectedError: "case 0: cases only apply to unary methods, 'Watch' is a streaming one",
+		},
+		{
+			name:          "should reject a case of an unknown method",
+			method:        "List",
+			expectedError: "case 0: unknown method 'List'",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := runtime.NewStubOptions(
+				runtime.WithCases(runtime.StubCase{Method: test.method}),
+			)
+
+			err := options.CheckCases(services)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expectedError {
+				t.Fatalf("Wrong error, given: %v expected: %s", err, test.expectedError)
+			}
+		})
+	}
+}
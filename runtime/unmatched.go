@@ -0,0 +1,372 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnmatchedBehavior tells what the generated contract client and stub server do when
+// a request matches no case of the contract.
+type UnmatchedBehavior int
+
+const (
+	// UnmatchedDefault keeps the behavior chosen by the `unmatched` plugin option.
+	UnmatchedDefault UnmatchedBehavior = iota
+	// UnmatchedStrict fails the call with an Unimplemented status describing the closest case.
+	UnmatchedStrict
+	// UnmatchedPermissive finishes the call without a response and without an error, the
+	// stub servers answer the unary and client-streaming calls with an empty response.
+	UnmatchedPermissive
+)
+
+// Candidate is a case the request is compared against when it matches no case,
+// so the closest one can be reported.
+type Candidate struct {
+	Description string
+	Requests    []proto.Message
+	Matchers    []FieldMatcher
+	Metadata    metadata.MD
+}
+
+// Unmatched returns the error of a request matching no case of the method, or nil when
+// the behavior is permissive. The behavior falls back to the generated one when it's
+// UnmatchedDefault. The error describes the differences between the requests and the
+// closest candidate, the one with fewer differences.
+func Unmatched(
+	ctx context.Context,
+	behavior UnmatchedBehavior,
+	generated UnmatchedBehavior,
+	method string,
+	requests []proto.Message,
+	candidates ...Candidate,
+) error {
+	if behavior == UnmatchedDefault {
+		behavior = generated
+	}
+	if behavior == UnmatchedPermissive {
+		return nil
+	}
+
+	message := fmt.Sprintf("no case of %s matches the request", method)
+
+	var (
+		closest      *Candidate
+		closestDiffs []string
+	)
+	for i := range candidates {
+		diffs := diffCandidate(ctx, requests, candidates[i])
+		if closest == nil || len(diffs) < len(closestDiffs) {
+			closest, closestDiffs = &candidates[i], diffs
+		}
+	}
+
+	switch {
+	case closest == nil:
+	case len(closestDiffs) == 0:
+		// Only a scenario step in another state matches every field and the metadata
+		message += fmt.Sprintf(
+			", case '%s' matches it but not in the current scenario state", closest.Description,
+		)
+	default:
+		message += fmt.Sprintf(
+			", closest case '%s': %s", closest.Description, strings.Join(closestDiffs, "; "),
+		)
+	}
+
+	return status.Error(codes.Unimplemented, message)
+}
+
+func diffCandidate(ctx context.Context, requests []proto.Message, candidate Candidate) []string {
+	diffs := make([]string, 0)
+
+	if len(requests) != len(candidate.Requests) {
+		diffs = append(diffs, fmt.Sprintf(
+			"expected %d requests, given %d", len(candidate.Requests), len(requests),
+		))
+	} else {
+		// Field paths are only prefixed when the method streams many requests
+		prefixed := len(requests) > 1
+		for i := range requests {
+			prefix := ""
+			if prefixed {
+				prefix = fmt.Sprintf("[%d].", i)
+			}
+
+			diffs = append(
+				diffs,
+				diffRequest(prefix, requests[i], candidate.Requests[i], candidate.Matchers)...,
+			)
+		}
+	}
+
+	if !MatchRequestMetadata(ctx, candidate.Metadata) {
+		diffs = append(
+			diffs, fmt.Sprintf("metadata: expected %v", map[string][]string(candidate.Metadata)),
+		)
+	}
+
+	return diffs
+}
+
+// diffRequest returns the differences between the request and the expected one, fields
+// covered by a matcher are only reported when the matcher doesn't accept them.
+func diffRequest(
+	prefix string,
+	actual proto.Message,
+	expected proto.Message,
+	matchers []FieldMatcher,
+) []string {
+	diffs := make([]string, 0)
+
+	// A nil request is compared as an empty one
+	if actual == nil || !actual.ProtoReflect().IsValid() {
+		actual = expected.ProtoReflect().New().Interface()
+	}
+
+	actualCopy := proto.Clone(actual)
+	expectedCopy := proto.Clone(expected)
+	for _, matcher := range matchers {
+		field, value, set, found := lookupPath(actual.ProtoReflect(), matcher.path)
+		if !found || !matcher.match(field, value, set) {
+			diffs = append(diffs, fmt.Sprintf(
+				"%s%s: expected %s, given %s",
+				prefix, matcher.path, matcher.rule, formatMatchedValue(field, value, found),
+			))
+		}
+
+		clearPath(actualCopy.ProtoReflect(), matcher.path)
+		clearPath(expectedCopy.ProtoReflect(), matcher.path)
+	}
+
+	// Any fields packing the same message must not be reported because of their bytes
+	repackAny(actualCopy.ProtoReflect())
+	repackAny(expectedCopy.ProtoReflect())
+
+	return append(
+		diffs, diffMessages(prefix, actualCopy.ProtoReflect(), expectedCopy.ProtoReflect())...,
+	)
+}
+
+func diffMessages(
+	prefix string,
+	actual protoreflect.Message,
+	expected protoreflect.Message,
+) []string {
+	diffs := make([]string, 0)
+
+	fields := expected.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		diffs = append(diffs, diffField(prefix+string(field.Name()), field, actual, expected)...)
+	}
+
+	// Extensions are named by their full names, like in the JSON written in the contract
+	for _, field := range setExtensions(actual, expected) {
+		path := fmt.Sprintf("%s[%s]", prefix, field.FullName())
+		diffs = append(diffs, diffField(path, field, actual, expected)...)
+	}
+
+	return diffs
+}
+
+func diffField(
+	path string,
+	field protoreflect.FieldDescriptor,
+	actual protoreflect.Message,
+	expected protoreflect.Message,
+) []string {
+	actualValue, expectedValue := actual.Get(field), expected.Get(field)
+
+	switch {
+	case field.IsList():
+		return diffLists(path, field, actualValue.List(), expectedValue.List())
+	case field.IsMap():
+		return diffMaps(path, field, actualValue.Map(), expectedValue.Map())
+	case field.Message() != nil:
+		if actual.Has(field) || expected.Has(field) {
+			return diffMessages(path+".", actualValue.Message(), expectedValue.Message())
+		}
+
+		return nil
+	}
+
+	// Unset fields tracking their presence are reported as not set, instead of their
+	// default values
+	if field.HasPresence() && !actual.Has(field) {
+		actualValue = protoreflect.Value{}
+	}
+	if field.HasPresence() && !expected.Has(field) {
+		expectedValue = protoreflect.Value{}
+	}
+
+	return diffValues(path, field, actualValue, expectedValue)
+}
+
+// setExtensions returns the extensions set in any of the messages, sorted by their numbers.
+func setExtensions(
+	actual protoreflect.Message,
+	expected protoreflect.Message,
+) []protoreflect.FieldDescriptor {
+	extensions := make(map[protoreflect.FieldNumber]protoreflect.FieldDescriptor)
+	collect := func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if field.IsExtension() {
+			extensions[field.Number()] = field
+		}
+
+		return true
+	}
+	actual.Range(collect)
+	expected.Range(collect)
+
+	fields := make([]protoreflect.FieldDescriptor, 0, len(extensions))
+	for _, field := range extensions {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Number() < fields[j].Number()
+	})
+
+	return fields
+}
+
+func diffLists(
+	path string,
+	field protoreflect.FieldDescriptor,
+	actual protoreflect.List,
+	expected protoreflect.List,
+) []string {
+	if actual.Len() != expected.Len() {
+		return []string{
+			fmt.Sprintf("%s: expected %d items, given %d", path, expected.Len(), actual.Len()),
+		}
+	}
+
+	diffs := make([]string, 0)
+	for i := 0; i < actual.Len(); i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if field.Message() != nil {
+			diffs = append(diffs, diffMessages(
+				itemPath+".", actual.Get(i).Message(), expected.Get(i).Message(),
+			)...)
+			continue
+		}
+
+		diffs = append(diffs, diffValues(itemPath, field, actual.Get(i), expected.Get(i))...)
+	}
+
+	return diffs
+}
+
+func diffMaps(
+	path string,
+	field protoreflect.FieldDescriptor,
+	actual protoreflect.Map,
+	expected protoreflect.Map,
+) []string {
+	keys := make(map[string]protoreflect.MapKey)
+	for _, entries := range []protoreflect.Map{actual, expected} {
+		entries.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+			keys[key.String()] = key
+			return true
+		})
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	diffs := make([]string, 0)
+	for _, name := range names {
+		key := keys[name]
+		entryPath := fmt.Sprintf("%s[%s]", path, name)
+
+		switch {
+		case !actual.Has(key):
+			diffs = append(diffs, fmt.Sprintf("%s: expected to be set, given unset", entryPath))
+		case !expected.Has(key):
+			diffs = append(diffs, fmt.Sprintf("%s: expected unset, given set", entryPath))
+		case field.MapValue().Message() != nil:
+			diffs = append(diffs, diffMessages(
+				entryPath+".", actual.Get(key).Message(), expected.Get(key).Message(),
+			)...)
+		default:
+			diffs = append(diffs, diffValues(
+				entryPath, field.MapValue(), actual.Get(key), expected.Get(key),
+			)...)
+		}
+	}
+
+	return diffs
+}
+
+func diffValues(
+	path string,
+	field protoreflect.FieldDescriptor,
+	actual protoreflect.Value,
+	expected protoreflect.Value,
+) []string {
+	actualValue := normalizeValue(field, actual)
+	expectedValue := normalizeValue(field, expected)
+	if actualValue == expectedValue {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf(
+			"%s: expected %s, given %s", path, formatValue(expectedValue), formatValue(actualValue),
+		),
+	}
+}
+
+// formatMatchedValue formats the value given to a matcher that didn't accept it.
+func formatMatchedValue(
+	field protoreflect.FieldDescriptor,
+	value protoreflect.Value,
+	found bool,
+) string {
+	switch {
+	case !found:
+		return "no such field"
+	case !value.IsValid():
+		return "unset"
+	case field.IsMap():
+		return fmt.Sprintf("%d entries", value.Map().Len())
+	case field.IsList():
+		list := value.List()
+		items := make([]string, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			items = append(items, formatItem(field, list.Get(i)))
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	default:
+		return formatItem(field, value)
+	}
+}
+
+// formatItem formats a single value of the field, messages in their text format.
+func formatItem(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	if field.Message() != nil {
+		return fmt.Sprintf("{%v}", value.Message().Interface())
+	}
+
+	return formatValue(normalizeValue(field, value))
+}
+
+func formatValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return fmt.Sprintf("%q", text)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
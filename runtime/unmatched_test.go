@@ -0,0 +1,177 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	extpb "google.golang.org/grpc/reflection/grpc_testing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestUnmatched(t *testing.T) {
+	t.Parallel()
+
+	candidates := []runtime.Candidate{
+		{
+			Description: "Should find the type",
+			Requests: []proto.Message{&typepb.Type{
+				Name:          "expected-name",
+				Oneofs:        []string{"first"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+			}},
+		},
+		{
+			Description: "Should find any type of the file",
+			Requests: []proto.Message{&typepb.Type{
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "another.proto"},
+			}},
+			Matchers: []runtime.FieldMatcher{runtime.Prefix("name", "another")},
+			Metadata: metadata.Pairs("x-tenant-id", "acme"),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		behavior      runtime.UnmatchedBehavior
+		generated     runtime.UnmatchedBehavior
+		requests      []proto.Message
+		candidates    []runtime.Candidate
+		expectedError string
+	}{
+		{
+			name:      "should report the differences from the closest case",
+			generated: runtime.UnmatchedStrict,
+			requests: []proto.Message{&typepb.Type{
+				Name:          "given-name",
+				Oneofs:        []string{"first", "second"},
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "file.proto"},
+				Syntax:        typepb.Syntax_SYNTAX_PROTO2,
+			}},
+			candidates: candidates,
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, closest case 'Should find the type': " +
+				`name: expected "expected-name", given "given-name"; ` +
+				"oneofs: expected 1 items, given 2; " +
+				`syntax: expected "SYNTAX_PROTO3", given "SYNTAX_PROTO2"`,
+		},
+		{
+			name:      "should report the matchers and the metadata that don't match",
+			generated: runtime.UnmatchedStrict,
+			requests: []proto.Message{&typepb.Type{
+				Name:          "unknown",
+				SourceContext: &sourcecontextpb.SourceContext{FileName: "another.proto"},
+			}},
+			candidates: candidates,
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, closest case 'Should find any type of the file': " +
+				`name: expected to start with "another", given "unknown"; ` +
+				"metadata: expected map[x-tenant-id:[acme]]",
+		},
+		{
+			name:      "should report the unset fields and the extensions",
+			generated: runtime.UnmatchedStrict,
+			requests: []proto.Message{
+				newExtended(&extpb.ToBeExtended{}, "given", nil),
+			},
+			candidates: []runtime.Candidate{
+				{
+					Description: "Should find the extended message",
+					Requests: []proto.Message{newExtended(
+						&extpb.ToBeExtended{Foo: proto.Int32(0)},
+						"expected",
+						&extpb.AnotherExtension{Whatchamacallit: proto.Int32(1)},
+					)},
+				},
+			},
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, closest case 'Should find the extended message': " +
+				"foo: expected 0, given <nil>; " +
+				`[grpc.testing.frob]: expected "expected", given "given"; ` +
+				"[grpc.testing.nitz].whatchamacallit: expected 1, given <nil>",
+		},
+		{
+			name:      "should report the number of requests when it's different",
+			generated: runtime.UnmatchedStrict,
+			requests:  []proto.Message{},
+			candidates: []runtime.Candidate{
+				{Description: "Should find the type", Requests: candidates[0].Requests},
+			},
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, closest case 'Should find the type': " +
+				"expected 1 requests, given 0",
+		},
+		{
+			name:      "should tell when only the scenario state doesn't match",
+			generated: runtime.UnmatchedStrict,
+			requests:  candidates[0].Requests,
+			candidates: []runtime.Candidate{
+				{Description: "Should find the type", Requests: candidates[0].Requests},
+			},
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request, case 'Should find the type' matches it but not in the " +
+				"current scenario state",
+		},
+		{
+			name:      "should fail without the closest case when the method has no cases",
+			generated: runtime.UnmatchedStrict,
+			requests:  []proto.Message{&typepb.Type{}},
+			expectedError: "rpc error: code = Unimplemented desc = no case of acme.Types.Find " +
+				"matches the request",
+		},
+		{
+			name:       "should not fail when the generated behavior is permissive",
+			generated:  runtime.UnmatchedPermissive,
+			requests:   []proto.Message{&typepb.Type{}},
+			candidates: candidates,
+		},
+		{
+			name:       "should prefer the given behavior over the generated one",
+			behavior:   runtime.UnmatchedPermissive,
+			generated:  runtime.UnmatchedStrict,
+			requests:   []proto.Message{&typepb.Type{}},
+			candidates: candidates,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runtime.Unmatched(
+				context.Background(),
+				test.behavior,
+				test.generated,
+				"acme.Types.Find",
+				test.requests,
+				test.candidates...,
+			)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Wrong error, given: %v expected: %s", err, test.expectedError)
+			}
+		})
+	}
+}
+
+func newExtended(
+	message *extpb.ToBeExtended,
+	frob string,
+	nitz *extpb.AnotherExtension,
+) *extpb.ToBeExtended {
+	proto.SetExtension(message, extpb.E_Frob, frob)
+	if nitz != nil {
+		proto.SetExtension(message, extpb.E_Nitz, nitz)
+	}
+
+	return message
+}
@@ -0,0 +1,45 @@
+package runtime
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// MustNewStruct creates a Struct like structpb.NewStruct, panicking when some value can't
+// be converted. It's used by the generated code, whose values are read from the contract
+// JSON and can always be converted.
+func MustNewStruct(fields map[string]interface{}) *structpb.Struct {
+	value, err := structpb.NewStruct(fields)
+	if err != nil {
+		panic(fmt.Sprintf("invalid struct: %v", err))
+	}
+
+	return value
+}
+
+// MustNewList creates a ListValue like structpb.NewList, panicking when some value can't
+// be converted. It's used by the generated code, like MustNewStruct.
+func MustNewList(values []interface{}) *structpb.ListValue {
+	value, err := structpb.NewList(values)
+	if err != nil {
+		panic(fmt.Sprintf("invalid list: %v", err))
+	}
+
+	return value
+}
+
+// MustNewAny packs the message like anypb.New, panicking when it can't be marshaled. It's
+// used by the generated code, like MustNewStruct. The message is marshaled deterministically,
+// so messages with maps are always packed the same way and can be compared.
+func MustNewAny(message proto.Message) *anypb.Any {
+	value := &anypb.Any{}
+	err := anypb.MarshalFrom(value, message, proto.MarshalOptions{Deterministic: true})
+	if err != nil {
+		panic(fmt.Sprintf("invalid any: %v", err))
+	}
+
+	return value
+}
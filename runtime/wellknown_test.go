@@ -0,0 +1,81 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/faunists/deal-go/runtime"
+)
+
+func TestMustNewStruct(t *testing.T) {
+	t.Parallel()
+
+	value := runtime.MustNewStruct(map[string]interface{}{
+		"name": "deal",
+		"tags": []interface{}{"a", nil},
+	})
+
+	expectedValue := &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"name": structpb.NewStringValue("deal"),
+			"tags": structpb.NewListValue(&structpb.ListValue{
+				Values: []*structpb.Value{
+					structpb.NewStringValue("a"),
+					structpb.NewNullValue(),
+				},
+			}),
+		},
+	}
+	if !proto.Equal(value, expectedValue) {
+		t.Errorf("Wrong struct, given: %v expected: %v", value, expectedValue)
+	}
+}
+
+func TestMustNewList_PanicsWithInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recovered := recover(); recovered == nil {
+			t.Errorf("Wrong result, given: no panic expected: a panic")
+		}
+	}()
+
+	runtime.MustNewList([]interface{}{struct{}{}})
+}
+
+func TestMustNewAny(t *testing.T) {
+	t.Parallel()
+
+	message := &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"first":  structpb.NewStringValue("a"),
+			"second": structpb.NewStringValue("b"),
+			"third":  structpb.NewStringValue("c"),
+		},
+	}
+
+	value := runtime.MustNewAny(message)
+	if value.TypeUrl != "type.googleapis.com/google.protobuf.Struct" {
+		t.Errorf(
+			"Wrong type, given: %s expected: type.googleapis.com/google.protobuf.Struct",
+			value.TypeUrl,
+		)
+	}
+
+	unpacked, err := value.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !proto.Equal(unpacked, message) {
+		t.Errorf("Wrong message, given: %v expected: %v", unpacked, message)
+	}
+
+	// Maps are marshaled deterministically, so the same message is always packed the same way
+	for i := 0; i < 10; i++ { //nolint:revive // random number
+		if !proto.Equal(runtime.MustNewAny(message), value) {
+			t.Fatalf("Wrong any, given: %v expected: %v", runtime.MustNewAny(message), value)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package stub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+)
+
+var errBidiCaseFields = errors.New(
+	"bidirectional streaming methods must only use 'steps' to describe the requests and responses",
+)
+
+// playedCase is a case of the contract with its messages already built, it's matched
+// against the received requests exactly like the cases of the generated stub server.
+type playedCase struct {
+	description string
+	requests    []proto.Message
+	matchers    []runtime.FieldMatcher
+	metadata    metadata.MD
+	responses   []proto.Message
+	err         error
+	header      metadata.MD
+	trailer     metadata.MD
+	// scenario is only set for the scenario steps
+	scenario *scenarioTransition
+}
+
+// scenarioTransition moves the scenario from state to next, see runtime.Scenarios.
+type scenarioTransition struct {
+	name  string
+	state string
+	next  string
+}
+
+// contractCase holds the fields shared by success and failure cases.
+type contractCase struct {
+	description      string
+	request          interface{}
+	requests         []interface{}
+	matchers         map[string]entities.FieldMatcher
+	requestMetadata  map[string]string
+	response         interface{}
+	responses        []interface{}
+	responseHeaders  map[string]string
+	responseTrailers map[string]string
+	steps            []entities.Step
+	err              *entities.GRPCError
+}
+
+func newSuccessCase(c entities.SuccessCase) contractCase {
+	return contractCase{
+		description:      c.Description,
+		request:          c.Request,
+		requests:         c.Requests,
+		matchers:         c.RequestMatchers,
+		requestMetadata:  c.RequestMetadata,
+		response:         c.Response,
+		responses:        c.Responses,
+		responseHeaders:  c.ResponseHeaders,
+		responseTrailers: c.ResponseTrailers,
+		steps:            c.Steps,
+	}
+}
+
+func newFailureCase(c entities.FailureCase) contractCase {
+	grpcError := c.Error
+
+	return contractCase{
+		description:      c.Description,
+		request:          c.Request,
+		requests:         c.Requests,
+		matchers:         c.RequestMatchers,
+		requestMetadata:  c.RequestMetadata,
+		responses:        c.Responses,
+		responseHeaders:  c.ResponseHeaders,
+		responseTrailers: c.ResponseTrailers,
+		steps:            c.Steps,
+		err:              &grpcError,
+	}
+}
+
+func newScenarioStep(step entities.ScenarioStep) contractCase {
+	return contractCase{
+		description:     step.Description,
+		request:         step.Request,
+		matchers:        step.RequestMatchers,
+		requestMetadata: step.RequestMetadata,
+		response:        step.Response,
+		err:             step.Error,
+	}
+}
+
+// newPlayedCase builds a case of a method that isn't bidirectional, the same rules of
+// the generated code are applied.
+func newPlayedCase(
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	c contractCase,
+) (*playedCase, error) {
+	if len(c.steps) > 0 {
+		return nil, errors.New("'steps' can only be used with bidirectional streaming methods")
+	}
+
+	played := &playedCase{description: c.description}
+
+	var err error
+	if played.requests, err = newRequests(registry, method, c); err != nil {
+		return nil, err
+	}
+
+	played.matchers, err = processors.NewRequestMatchers(method.Input.Desc, c.matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setCaseMetadata(&played.metadata, &played.header, &played.trailer, c); err != nil {
+		return nil, err
+	}
+
+	if c.err != nil {
+		played.err, err = newError(registry, *c.err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	played.responses, err = newResponses(registry, method, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return played, nil
+}
+
+// newRequests builds the requests of a case, client-streaming methods use the `requests`
+// list while the others use the single `request`.
+func newRequests(
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	c contractCase,
+) ([]proto.Message, error) {
+	if !method.Desc.IsStreamingClient() {
+		if len(c.requests) > 0 {
+			return nil, errors.New("'requests' can only be used with client-streaming methods")
+		}
+
+		return newMessages(registry, method.Input.Desc, []interface{}{c.request})
+	}
+
+	if c.request != nil {
+		return nil, errors.New("client-streaming methods must use 'requests' instead of 'request'")
+	}
+
+	return newMessages(registry, method.Input.Desc, c.requests)
+}
+
+// newResponses builds the responses of a case, unary methods use the single `response`
+// while streaming ones use the `responses` list. Failing unary methods have no response.
+func newResponses(
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	c contractCase,
+) ([]proto.Message, error) {
+	if !method.Desc.IsStreamingServer() {
+		if len(c.responses) > 0 {
+			return nil, errors.New("'responses' can only be used with streaming methods")
+		}
+		if c.err != nil {
+			return nil, nil
+		}
+
+		return newMessages(registry, method.Output.Desc, []interface{}{c.response})
+	}
+
+	if c.response != nil {
+		return nil, errors.New("streaming methods must use 'responses' instead of 'response'")
+	}
+
+	return newMessages(registry, method.Output.Desc, c.responses)
+}
+
+// newScript builds the script of a bidirectional streaming case.
+func newScript(
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	c contractCase,
+) (runtime.Script, error) {
+	if c.request != nil || c.requests != nil || c.matchers != nil ||
+		c.response != nil || c.responses != nil {
+		return runtime.Script{}, errBidiCaseFields
+	}
+
+	script := runtime.Script{Description: c.description}
+	if err := setCaseMetadata(&script.Metadata, &script.Header, &script.Trailer, c); err != nil {
+		return runtime.Script{}, err
+	}
+
+	if c.err != nil {
+		var err error
+		if script.Err, err = newError(registry, *c.err); err != nil {
+			return runtime.Script{}, err
+		}
+	}
+
+	for _, step := range c.steps {
+		scriptStep, err := newStep(registry, method, step)
+		if err != nil {
+			return runtime.Script{}, err
+		}
+
+		script.Steps = append(script.Steps, scriptStep)
+	}
+
+	return script, nil
+}
+
+func newStep(
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	step entities.Step,
+) (runtime.Step, error) {
+	requests, err := newMessages(registry, method.Input.Desc, []interface{}{step.Request})
+	if err != nil {
+		return runtime.Step{}, err
+	}
+
+	matchers, err := processors.NewRequestMatchers(method.Input.Desc, step.RequestMatchers)
+	if err != nil {
+		return runtime.Step{}, err
+	}
+
+	responses, err := newMessages(registry, method.Output.Desc, step.Responses)
+	if err != nil {
+		return runtime.Step{}, err
+	}
+
+	return runtime.Step{Request: requests[0], Matchers: matchers, Responses: responses}, nil
+}
+
+func setCaseMetadata(
+	request *metadata.MD,
+	header *metadata.MD,
+	trailer *metadata.MD,
+	c contractCase,
+) error {
+	var err error
+	if *request, err = processors.NewMetadata(c.requestMetadata); err != nil {
+		return fmt.Errorf("invalid request metadata: %w", err)
+	}
+	if *header, err = processors.NewMetadata(c.responseHeaders); err != nil {
+		return fmt.Errorf("invalid response headers: %w", err)
+	}
+	if *trailer, err = processors.NewMetadata(c.responseTrailers); err != nil {
+		return fmt.Errorf("invalid response trailers: %w", err)
+	}
+
+	return nil
+}
+
+func newError(registry *processors.MessageRegistry, grpcError entities.GRPCError) (error, error) {
+	st, err := processors.NewStatus(registry, grpcError)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.Err(), nil
+}
+
+// newMessages builds a dynamic message of the descriptor for every value written in the
+// contract, the values use the JSON representation of the messages.
+func newMessages(
+	registry *processors.MessageRegistry,
+	descriptor protoreflect.MessageDescriptor,
+	values []interface{},
+) ([]proto.Message, error) {
+	unmarshaler := protojson.UnmarshalOptions{Resolver: registry}
+
+	messages := make([]proto.Message, 0, len(values))
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		message := dynamicpb.NewMessage(descriptor)
+		if err = unmarshaler.Unmarshal(data, message); err != nil {
+			return nil, fmt.Errorf("failed to build a %s: %w", descriptor.FullName(), err)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,53 @@
+package stub
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// RegisterFiles adds the files to the global registry read by the server reflection, so the
+// services played without generated code can be discovered. Files already registered, like
+// the well-known types, are skipped along with the ones declaring names already registered
+// by another file, since the registry doesn't accept conflicts.
+func RegisterFiles(files []*protogen.File) error {
+	for _, file := range files {
+		if isRegistered(file.Desc) {
+			continue
+		}
+
+		if err := protoregistry.GlobalFiles.RegisterFile(file.Desc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isRegistered(file protoreflect.FileDescriptor) bool {
+	if _, err := protoregistry.GlobalFiles.FindFileByPath(file.Path()); err == nil {
+		return true
+	}
+
+	var names []protoreflect.FullName
+	for i := 0; i < file.Messages().Len(); i++ {
+		names = append(names, file.Messages().Get(i).FullName())
+	}
+	for i := 0; i < file.Enums().Len(); i++ {
+		names = append(names, file.Enums().Get(i).FullName())
+	}
+	for i := 0; i < file.Extensions().Len(); i++ {
+		names = append(names, file.Extensions().Get(i).FullName())
+	}
+	for i := 0; i < file.Services().Len(); i++ {
+		names = append(names, file.Services().Get(i).FullName())
+	}
+
+	for _, name := range names {
+		if _, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package stub_test
+
+import (
+	"context"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+	"github.com/faunists/deal-go/stub"
+)
+
+// echoFile is a proto file only known through the descriptor set, there's no generated
+// code registering it.
+func echoFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("deal/stub/echo.proto"),
+		Package: proto.String("deal.stub.echo"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("EchoMessage"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("text"),
+						JsonName: proto.String("text"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Echo"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{
+						Name:       proto.String("Echo"),
+						InputType:  proto.String(".deal.stub.echo.EchoMessage"),
+						OutputType: proto.String(".deal.stub.echo.EchoMessage"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRegisterFiles(t *testing.T) {
+	t.Parallel()
+
+	files := readDescriptors(t, echoFile())
+	if err := stub.RegisterFiles(files); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	registry, err := processors.NewMessageRegistry(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	contract := entities.Contract{
+		Services: map[string]entities.Service{
+			"deal.stub.echo.Echo": {
+				"Echo": {
+					SuccessCases: []entities.SuccessCase{
+						{
+							Description: "Should echo",
+							Request:     map[string]interface{}{"text": "hi"},
+							Response:    map[string]interface{}{"text": "hi"},
+						},
+					},
+				},
+			},
+		},
+	}
+	server, err := stub.NewServer(files, registry, contract, runtime.UnmatchedDefault)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	conn := runtime.StartStub(t, server.Register, runtime.WithDiscovery())
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(
+		context.Background(),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: "deal.stub.echo.Echo",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	descriptors := response.GetFileDescriptorResponse().GetFileDescriptorProto()
+	if len(descriptors) != 1 {
+		t.Fatalf("Wrong descriptors, given: %v expected: the echo file", response)
+	}
+
+	file := &descriptorpb.FileDescriptorProto{}
+	if err = proto.Unmarshal(descriptors[0], file); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file.GetName() != "deal/stub/echo.proto" {
+		t.Errorf("Wrong file, given: %s expected: deal/stub/echo.proto", file.GetName())
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(
+		context.Background(),
+		&healthpb.HealthCheckRequest{Service: "deal.stub.echo.Echo"},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf(
+			"Wrong status, given: %v expected: %v",
+			health.Status, healthpb.HealthCheckResponse_SERVING,
+		)
+	}
+
+	// Registering the same files again is a no-op
+	if err = stub.RegisterFiles(files); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
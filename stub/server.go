@@ -0,0 +1,460 @@
+// Package stub serves the contract without generated code, the messages of the cases
+// are built at runtime through dynamicpb from the descriptors of the services.
+package stub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+)
+
+var errStreamingScenario = errors.New("scenarios can only be used with unary methods")
+
+// Server plays the contract cases of every service found in the descriptors, the same
+// way the generated stub servers do.
+type Server struct {
+	services  []*grpc.ServiceDesc
+	unmatched runtime.UnmatchedBehavior
+	scenarios runtime.Scenarios
+	calls     runtime.CallJournal
+}
+
+// stubMethod holds the cases of a single method of the contract.
+type stubMethod struct {
+	server     *Server
+	registry   *processors.MessageRegistry
+	desc       *protogen.Method
+	cases      []*playedCase
+	candidates []runtime.Candidate
+	scripts    []runtime.Script
+}
+
+// NewServer builds the cases of every service of the files having a contract, services
+// without contract are left out. The unmatched behavior tells what happens when a request
+// matches no case, UnmatchedDefault fails the call like the strict behavior.
+func NewServer(
+	files []*protogen.File,
+	registry *processors.MessageRegistry,
+	contract entities.Contract,
+	unmatched runtime.UnmatchedBehavior,
+) (*Server, error) {
+	server := &Server{unmatched: unmatched}
+
+	for _, file := range files {
+		for _, service := range file.Services {
+			serviceName := string(service.Desc.FullName())
+			serviceContract, hasContract := contract.Services[serviceName]
+			scenarios := processors.ServiceScenarios(contract, serviceName)
+			if !hasContract && len(scenarios) == 0 {
+				continue
+			}
+
+			desc := &grpc.ServiceDesc{
+				ServiceName: serviceName,
+				HandlerType: (*interface{})(nil),
+				Metadata:    file.Desc.Path(),
+			}
+
+			for _, method := range service.Methods {
+				stub, err := newStubMethod(
+					server, registry, method, serviceContract[method.GoName], scenarios,
+				)
+				if err != nil {
+					return nil, err
+				}
+
+				stub.register(desc)
+			}
+
+			server.services = append(server.services, desc)
+		}
+	}
+
+	return server, nil
+}
+
+// Register adds the services of the contract to the grpc.Server.
+func (s *Server) Register(server *grpc.Server) {
+	for _, desc := range s.services {
+		server.RegisterService(desc, s)
+	}
+}
+
+// Services returns the full name of every service played by the server.
+func (s *Server) Services() []string {
+	names := make([]string, 0, len(s.services))
+	for _, desc := range s.services {
+		names = append(names, desc.ServiceName)
+	}
+
+	return names
+}
+
+// Calls returns every call received so far, in order.
+func (s *Server) Calls() []runtime.Call {
+	return s.calls.Calls()
+}
+
+// ResetScenarios moves every scenario back to its initial state.
+func (s *Server) ResetScenarios() {
+	s.scenarios.Reset()
+}
+
+func newStubMethod(
+	server *Server,
+	registry *processors.MessageRegistry,
+	method *protogen.Method,
+	methodContract entities.Method,
+	scenarios []entities.Scenario,
+) (*stubMethod, error) {
+	stub := &stubMethod{server: server, registry: registry, desc: method}
+
+	if err := stub.addScenarioSteps(registry, scenarios); err != nil {
+		return nil, err
+	}
+
+	for i, successCase := range methodContract.SuccessCases {
+		if err := stub.addContractCase(registry, newSuccessCase(successCase)); err != nil {
+			path := successCase.Source.CasePath("successCases", i)
+			return nil, caseError(
+				method, path, successCase.Description, successCase.Source, err,
+			)
+		}
+	}
+
+	for i, failureCase := range methodContract.FailureCases {
+		if err := stub.addContractCase(registry, newFailureCase(failureCase)); err != nil {
+			path := failureCase.Source.CasePath("failureCases", i)
+			return nil, caseError(
+				method, path, failureCase.Description, failureCase.Source, err,
+			)
+		}
+	}
+
+	return stub, nil
+}
+
+// addScenarioSteps adds the steps of the method, they come first so the stateless cases
+// are only used when no scenario matches.
+func (m *stubMethod) addScenarioSteps(
+	registry *processors.MessageRegistry,
+	scenarios []entities.Scenario,
+) error {
+	for _, scenario := range scenarios {
+		for i, step := range scenario.Steps {
+			if step.Method != m.desc.GoName {
+				continue
+			}
+
+			if err := m.addScenarioStep(registry, scenario, step); err != nil {
+				path := fmt.Sprintf("scenario '%s' steps[%d]", scenario.Name, i)
+				return caseError(m.desc, path, step.Description, step.Source, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *stubMethod) addScenarioStep(
+	registry *processors.MessageRegistry,
+	scenario entities.Scenario,
+	step entities.ScenarioStep,
+) error {
+	if m.desc.Desc.IsStreamingClient() || m.desc.Desc.IsStreamingServer() {
+		return errStreamingScenario
+	}
+
+	if err := m.addCase(registry, newScenarioStep(step)); err != nil {
+		return err
+	}
+
+	m.cases[len(m.cases)-1].scenario = &scenarioTransition{
+		name:  scenario.Name,
+		state: step.State,
+		next:  step.NextState,
+	}
+
+	return nil
+}
+
+// addContractCase adds a success or failure case, bidirectional streaming methods
+// play them as scripts.
+func (m *stubMethod) addContractCase(registry *processors.MessageRegistry, c contractCase) error {
+	if !m.desc.Desc.IsStreamingClient() || !m.desc.Desc.IsStreamingServer() {
+		return m.addCase(registry, c)
+	}
+
+	script, err := newScript(registry, m.desc, c)
+	if err != nil {
+		return err
+	}
+
+	m.scripts = append(m.scripts, script)
+
+	return nil
+}
+
+func (m *stubMethod) addCase(registry *processors.MessageRegistry, c contractCase) error {
+	played, err := newPlayedCase(registry, m.desc, c)
+	if err != nil {
+		return err
+	}
+
+	m.cases = append(m.cases, played)
+	m.candidates = append(m.candidates, runtime.Candidate{
+		Description: played.description,
+		Requests:    played.requests,
+		Matchers:    played.matchers,
+		Metadata:    played.metadata,
+	})
+
+	return nil
+}
+
+// register adds the handler of the method to the service description.
+func (m *stubMethod) register(desc *grpc.ServiceDesc) {
+	name := string(m.desc.Desc.Name())
+
+	switch {
+	case m.desc.Desc.IsStreamingClient() && m.desc.Desc.IsStreamingServer():
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    name,
+			Handler:       m.bidiStreamingHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		})
+	case m.desc.Desc.IsStreamingClient():
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    name,
+			Handler:       m.clientStreamingHandler,
+			ClientStreams: true,
+		})
+	case m.desc.Desc.IsStreamingServer():
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    name,
+			Handler:       m.serverStreamingHandler,
+			ServerStreams: true,
+		})
+	default:
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler:    m.unaryHandler,
+		})
+	}
+}
+
+func (m *stubMethod) newRequest() proto.Message {
+	return dynamicpb.NewMessage(m.desc.Input.Desc)
+}
+
+func (m *stubMethod) newResponse() proto.Message {
+	return dynamicpb.NewMessage(m.desc.Output.Desc)
+}
+
+func (m *stubMethod) unaryHandler(
+	srv interface{},
+	ctx context.Context,
+	dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	in := m.newRequest()
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if err := resolveExtensions(m.registry, in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return m.unary(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fmt.Sprintf("/%s/%s", m.desc.Parent.Desc.FullName(), m.desc.Desc.Name()),
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		request := req.(proto.Message) //nolint:errcheck // it's always the decoded request
+		return m.unary(ctx, request)
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+func (m *stubMethod) unary(ctx context.Context, in proto.Message) (interface{}, error) {
+	played, err := m.play(ctx, []proto.Message{in})
+	if err != nil {
+		return nil, err
+	}
+	if played == nil {
+		return m.newResponse(), nil
+	}
+
+	if err = runtime.SetResponseMetadata(ctx, played.header, played.trailer); err != nil {
+		return nil, err
+	}
+	if played.err != nil {
+		return nil, played.err
+	}
+
+	return played.responses[0], nil
+}
+
+func (m *stubMethod) serverStreamingHandler(_ interface{}, serverStream grpc.ServerStream) error {
+	stream := extensionStream{ServerStream: serverStream, registry: m.registry}
+
+	in := m.newRequest()
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+
+	played, err := m.play(stream.Context(), []proto.Message{in})
+	if err != nil || played == nil {
+		return err
+	}
+
+	if err = runtime.SetStreamMetadata(stream, played.header, played.trailer); err != nil {
+		return err
+	}
+
+	return runtime.SendResponses(stream, played.err, played.responses...)
+}
+
+func (m *stubMethod) clientStreamingHandler(_ interface{}, serverStream grpc.ServerStream) error {
+	stream := extensionStream{ServerStream: serverStream, registry: m.registry}
+
+	in, err := runtime.ReceiveRequests(stream, m.newRequest)
+	if err != nil {
+		return err
+	}
+
+	played, err := m.play(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	if played == nil {
+		return stream.SendMsg(m.newResponse())
+	}
+
+	if err = runtime.SetStreamMetadata(stream, played.header, played.trailer); err != nil {
+		return err
+	}
+	if played.err != nil {
+		return played.err
+	}
+
+	return stream.SendMsg(played.responses[0])
+}
+
+func (m *stubMethod) bidiStreamingHandler(_ interface{}, stream grpc.ServerStream) error {
+	return runtime.ReplayScripts(
+		extensionStream{ServerStream: stream, registry: m.registry},
+		m.newRequest,
+		m.server.calls.Recorder(m.desc.GoName),
+		runtime.ScriptsUnmatched{
+			Behavior:  m.server.unmatched,
+			Generated: runtime.UnmatchedStrict,
+			Method:    string(m.desc.Desc.FullName()),
+		},
+		m.scripts...,
+	)
+}
+
+// extensionStream resolves the extensions of every request received, see resolveExtensions.
+type extensionStream struct {
+	grpc.ServerStream
+	registry *processors.MessageRegistry
+}
+
+func (s extensionStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return resolveExtensions(s.registry, m)
+}
+
+// resolveExtensions parses the request again through the registry. The gRPC codec only
+// knows the extensions registered by the generated code, so the ones declared by the
+// descriptors are kept as unknown fields and would never match the cases setting them.
+func resolveExtensions(registry *processors.MessageRegistry, request interface{}) error {
+	message, ok := request.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	data, err := proto.MarshalOptions{AllowPartial: true}.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	proto.Reset(message)
+
+	return proto.UnmarshalOptions{AllowPartial: true, Resolver: registry}.Unmarshal(data, message)
+}
+
+// play finds the case matching the requests and records the call. When no case matches
+// the case is nil and the error is the one of the unmatched behavior.
+func (m *stubMethod) play(ctx context.Context, requests []proto.Message) (*playedCase, error) {
+	for _, played := range m.cases {
+		if played.matches(ctx, requests, &m.server.scenarios) {
+			m.server.calls.Record(m.desc.GoName, played.description, requests, played.err)
+			return played, nil
+		}
+	}
+
+	err := runtime.Unmatched(
+		ctx,
+		m.server.unmatched,
+		runtime.UnmatchedStrict,
+		string(m.desc.Desc.FullName()),
+		requests,
+		m.candidates...,
+	)
+	m.server.calls.Record(m.desc.GoName, "", requests, err)
+
+	return nil, err
+}
+
+// matches reports whether the requests and their metadata match the case, the scenario
+// transition comes last so the state only changes when everything else matches.
+func (c *playedCase) matches(
+	ctx context.Context,
+	requests []proto.Message,
+	scenarios *runtime.Scenarios,
+) bool {
+	if !runtime.MatchRequests(requests, c.requests, c.matchers...) ||
+		!runtime.MatchRequestMetadata(ctx, c.metadata) {
+		return false
+	}
+
+	return c.scenario == nil ||
+		scenarios.Transition(c.scenario.name, c.scenario.state, c.scenario.next)
+}
+
+// caseError prefixes the error with the case location, e.g.
+// `contract.yml:12:11: acme.v1.MyService.MyMethod successCases[0] 'Should do something': ...`
+func caseError(
+	method *protogen.Method,
+	path string,
+	description string,
+	source entities.Source,
+	err error,
+) error {
+	location := fmt.Sprintf("%s %s '%s'", method.Desc.FullName(), path, description)
+	if source.File != "" {
+		location = fmt.Sprintf("%s: %s", source, location)
+	}
+
+	return fmt.Errorf("%s: %w", location, err)
+}
@@ -0,0 +1,480 @@
+package stub_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/faunists/deal-go/entities"
+	"github.com/faunists/deal-go/processors"
+	"github.com/faunists/deal-go/runtime"
+	"github.com/faunists/deal-go/stub"
+)
+
+const healthService = "grpc.health.v1.Health"
+
+// readDescriptors writes a descriptor set holding the files, reading it back like
+// the `deal serve` command does.
+func readDescriptors(t *testing.T, files ...*descriptorpb.FileDescriptorProto) []*protogen.File {
+	t.Helper()
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: files})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "descriptors.pb")
+	if err = ioutil.WriteFile(filePath, data, 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	protoFiles, err := processors.ReadDescriptorSet(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return protoFiles
+}
+
+func readHealthDescriptors(t *testing.T) []*protogen.File {
+	t.Helper()
+
+	return readDescriptors(
+		t, protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto),
+	)
+}
+
+func startServer(
+	t *testing.T,
+	contract entities.Contract,
+	unmatched runtime.UnmatchedBehavior,
+) healthpb.HealthClient {
+	t.Helper()
+
+	files := readHealthDescriptors(t)
+	registry, err := processors.NewMessageRegistry(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server, err := stub.NewServer(files, registry, contract, unmatched)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if services := server.Services(); len(services) != 1 || services[0] != healthService {
+		t.Fatalf("Wrong services, given: %v expected: [%s]", services, healthService)
+	}
+
+	conn := runtime.StartStub(t, server.Register)
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestServer_Unary(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, entities.Contract{
+		Services: map[string]entities.Service{
+			healthService: {
+				"Check": {
+					SuccessCases: []entities.SuccessCase{
+						{
+							Description:     "Should be serving",
+							Request:         map[string]interface{}{"service": "users"},
+							RequestMetadata: map[string]string{"authorization": "token"},
+							Response:        map[string]interface{}{"status": "SERVING"},
+							ResponseHeaders: map[string]string{"x-region": "eu"},
+						},
+					},
+					FailureCases: []entities.FailureCase{
+						{
+							Description: "Should be down",
+							Request:     map[string]interface{}{"service": "orders"},
+							Error: entities.GRPCError{
+								ErrorCode: "Unavailable", Message: "orders are down",
+							},
+						},
+					},
+				},
+			},
+		},
+	}, runtime.UnmatchedDefault)
+
+	tests := []struct {
+		name           string
+		service        string
+		metadata       metadata.MD
+		expectedStatus healthpb.HealthCheckResponse_ServingStatus
+		expectedHeader metadata.MD
+		expectedError  string
+	}{
+		{
+			name:           "should answer with the success case",
+			service:        "users",
+			metadata:       metadata.Pairs("authorization", "token"),
+			expectedStatus: healthpb.HealthCheckResponse_SERVING,
+			expectedHeader: metadata.Pairs("x-region", "eu"),
+		},
+		{
+			name:          "should fail with the failure case",
+			service:       "orders",
+			expectedError: "rpc error: code = Unavailable desc = orders are down",
+		},
+		{
+			name:    "should describe the closest case when no case matches",
+			service: "users",
+			expectedError: "rpc error: code = Unimplemented desc = no case of " +
+				"grpc.health.v1.Health.Check matches the request, closest case " +
+				"'Should be serving': metadata: expected map[authorization:[token]]",
+		},
+	}
+
+	for _, test := range tests {
+		ctx := metadata.NewOutgoingContext(context.Background(), test.metadata)
+
+		var header metadata.MD
+		response, err := client.Check(
+			ctx, &healthpb.HealthCheckRequest{Service: test.service}, grpc.Header(&header),
+		)
+
+		if test.expectedError != "" {
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf(
+					"%s: Wrong error, given: %v expected: %s", test.name, err, test.expectedError,
+				)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", test.name, err)
+		}
+		if response.Status != test.expectedStatus {
+			t.Errorf(
+				"%s: Wrong status, given: %v expected: %v",
+				test.name, response.Status, test.expectedStatus,
+			)
+		}
+		if !runtime.MatchMetadata(header, test.expectedHeader) {
+			t.Errorf(
+				"%s: Wrong header, given: %v expected: %v", test.name, header, test.expectedHeader,
+			)
+		}
+	}
+}
+
+func TestServer_Scenarios(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, entities.Contract{
+		Scenarios: []entities.Scenario{
+			{
+				Name:    "Deploy",
+				Service: healthService,
+				Steps: []entities.ScenarioStep{
+					{
+						Description: "Should be starting",
+						Method:      "Check",
+						State:       runtime.ScenarioStarted,
+						NextState:   "Deployed",
+						Request:     map[string]interface{}{},
+						Response:    map[string]interface{}{"status": "NOT_SERVING"},
+					},
+					{
+						Description: "Should be deployed",
+						Method:      "Check",
+						State:       "Deployed",
+						Request:     map[string]interface{}{},
+						Response:    map[string]interface{}{"status": "SERVING"},
+					},
+				},
+			},
+		},
+	}, runtime.UnmatchedDefault)
+
+	expected := []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_NOT_SERVING,
+		healthpb.HealthCheckResponse_SERVING,
+		healthpb.HealthCheckResponse_SERVING,
+	}
+	for i, expectedStatus := range expected {
+		response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.Status != expectedStatus {
+			t.Errorf(
+				"Wrong status of call %d, given: %v expected: %v",
+				i, response.Status, expectedStatus,
+			)
+		}
+	}
+}
+
+func TestServer_ServerStreaming(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, entities.Contract{
+		Services: map[string]entities.Service{
+			healthService: {
+				"Watch": {
+					FailureCases: []entities.FailureCase{
+						{
+							Description: "Should stream until the service is down",
+							Request:     map[string]interface{}{"service": "users"},
+							Responses: []interface{}{
+								map[string]interface{}{"status": "SERVING"},
+								map[string]interface{}{"status": "NOT_SERVING"},
+							},
+							Error: entities.GRPCError{
+								ErrorCode: "Unavailable", Message: "users are down",
+							},
+						},
+					},
+				},
+			},
+		},
+	}, runtime.UnmatchedPermissive)
+
+	tests := []struct {
+		name             string
+		service          string
+		expectedStatuses []healthpb.HealthCheckResponse_ServingStatus
+		expectedCode     codes.Code
+	}{
+		{
+			name:    "should stream the responses before the error",
+			service: "users",
+			expectedStatuses: []healthpb.HealthCheckResponse_ServingStatus{
+				healthpb.HealthCheckResponse_SERVING,
+				healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+			expectedCode: codes.Unavailable,
+		},
+		{
+			name:         "should finish the stream when no case matches and it's permissive",
+			service:      "orders",
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		stream, err := client.Watch(
+			context.Background(), &healthpb.HealthCheckRequest{Service: test.service},
+		)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", test.name, err)
+		}
+
+		var statuses []healthpb.HealthCheckResponse_ServingStatus
+		for {
+			response, recvErr := stream.Recv()
+			if errors.Is(recvErr, io.EOF) {
+				break
+			}
+			if recvErr != nil {
+				err = recvErr
+				break
+			}
+
+			statuses = append(statuses, response.Status)
+		}
+
+		if status.Code(err) != test.expectedCode {
+			t.Errorf("%s: Wrong error, given: %v expected: %v", test.name, err, test.expectedCode)
+		}
+		if len(statuses) != len(test.expectedStatuses) {
+			t.Fatalf(
+				"%s: Wrong responses, given: %v expected: %v",
+				test.name, statuses, test.expectedStatuses,
+			)
+		}
+		for i := range statuses {
+			if statuses[i] != test.expectedStatuses[i] {
+				t.Errorf(
+					"%s: Wrong response %d, given: %v expected: %v",
+					test.name, i, statuses[i], test.expectedStatuses[i],
+				)
+			}
+		}
+	}
+}
+
+// legacyFile is a proto2 file whose extension isn't registered by any generated code.
+func legacyFile() *descriptorpb.FileDescriptorProto {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	stringType := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("legacy/ping.proto"),
+		Package: proto.String("acme.legacy.v1"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Ping"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name: proto.String("id"), Number: proto.Int32(1), Label: optional, Type: stringType,
+			}},
+			ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{{
+				Start: proto.Int32(100), End: proto.Int32(200), //nolint:revive // range
+			}},
+		}},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("label"),
+			Number:   proto.Int32(100), //nolint:revive // extension number
+			Label:    optional,
+			Type:     stringType,
+			Extendee: proto.String(".acme.legacy.v1.Ping"),
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Pinger"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Ping"),
+				InputType:  proto.String(".acme.legacy.v1.Ping"),
+				OutputType: proto.String(".acme.legacy.v1.Ping"),
+			}},
+		}},
+	}
+}
+
+func TestServer_Extensions(t *testing.T) {
+	t.Parallel()
+
+	files := readDescriptors(t, legacyFile())
+	registry, err := processors.NewMessageRegistry(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server, err := stub.NewServer(files, registry, entities.Contract{
+		Services: map[string]entities.Service{
+			"acme.legacy.v1.Pinger": {
+				"Ping": {
+					SuccessCases: []entities.SuccessCase{
+						{
+							Description: "Should ping the urgent ones",
+							Request: map[string]interface{}{
+								"id": "1", "[acme.legacy.v1.label]": "urgent",
+							},
+							Response: map[string]interface{}{"id": "2"},
+						},
+					},
+				},
+			},
+		},
+	}, runtime.UnmatchedStrict)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	conn := runtime.StartStub(t, server.Register)
+
+	message := files[0].Messages[0].Desc
+	label := dynamicpb.NewExtensionType(files[0].Extensions[0].Desc)
+
+	tests := []struct {
+		name         string
+		label        string
+		expectedID   string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "should match the extension set in the case",
+			label:        "urgent",
+			expectedID:   "2",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "should not match another value of the extension",
+			label:        "late",
+			expectedCode: codes.Unimplemented,
+		},
+	}
+
+	for _, test := range tests {
+		request := dynamicpb.NewMessage(message)
+		request.Set(message.Fields().ByName("id"), protoreflect.ValueOfString("1"))
+		request.Set(label.TypeDescriptor(), protoreflect.ValueOfString(test.label))
+
+		response := dynamicpb.NewMessage(message)
+		err = conn.Invoke(context.Background(), "/acme.legacy.v1.Pinger/Ping", request, response)
+		if status.Code(err) != test.expectedCode {
+			t.Fatalf("%s: Wrong error, given: %v expected: %v", test.name, err, test.expectedCode)
+		}
+
+		if id := response.Get(message.Fields().ByName("id")).String(); id != test.expectedID {
+			t.Errorf("%s: Wrong id, given: %s expected: %s", test.name, id, test.expectedID)
+		}
+	}
+}
+
+func TestNewServer_InvalidCase(t *testing.T) {
+	t.Parallel()
+
+	files := readHealthDescriptors(t)
+	registry, err := processors.NewMessageRegistry(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		method        entities.Method
+		expectedError string
+	}{
+		{
+			name: "should reject responses in unary methods",
+			method: entities.Method{
+				SuccessCases: []entities.SuccessCase{
+					{
+						Description: "Should be serving",
+						Request:     map[string]interface{}{},
+						Responses:   []interface{}{map[string]interface{}{}},
+					},
+				},
+			},
+			expectedError: "grpc.health.v1.Health.Check successCases[0] 'Should be serving': " +
+				"'responses' can only be used with streaming methods",
+		},
+		{
+			name: "should reject unknown fields",
+			method: entities.Method{
+				FailureCases: []entities.FailureCase{
+					{
+						Description: "Should fail",
+						Request:     map[string]interface{}{"name": "users"},
+						Error:       entities.GRPCError{ErrorCode: "Internal"},
+					},
+				},
+			},
+			expectedError: "grpc.health.v1.Health.Check failureCases[0] 'Should fail': " +
+				"failed to build a grpc.health.v1.HealthCheckRequest",
+		},
+	}
+
+	for _, test := range tests {
+		contract := entities.Contract{
+			Services: map[string]entities.Service{healthService: {"Check": test.method}},
+		}
+
+		_, err = stub.NewServer(files, registry, contract, runtime.UnmatchedDefault)
+		if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+			t.Errorf("%s: Wrong error, given: %v expected: %s", test.name, err, test.expectedError)
+		}
+	}
+}